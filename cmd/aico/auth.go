@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/providers/anthropic"
+	"micheam.com/aico/internal/providers/cerebras"
+	"micheam.com/aico/internal/providers/groq"
+	"micheam.com/aico/internal/providers/openai"
+)
+
+var CmdAuth = &cli.Command{
+	Name:  "auth",
+	Usage: "manage API keys",
+	Description: "An API key is taken from the first of:\n" +
+		"  1. its flag or environment variable, e.g. $AICO_ANTHROPIC_API_KEY\n" +
+		"  2. api_key_cmd or api_key_file of [providers.NAME] in config.toml\n" +
+		"  3. the credentials file written by `aico auth set`",
+	Commands: []*cli.Command{
+		{
+			Name:      "set",
+			Usage:     "store the API key of a provider in the credentials file",
+			ArgsUsage: "PROVIDER",
+			Description: "The key is read from stdin, without echo on a terminal. The credentials\n" +
+				"file is only readable by you.",
+			Action: runSetAuth,
+		},
+		{
+			Name:      "status",
+			Usage:     "show the API key of each provider and where it comes from",
+			ArgsUsage: "[PROVIDER]",
+			Action:    runShowAuth,
+		},
+		{
+			Name:      "rm",
+			Usage:     "remove the API key of a provider from the credentials file",
+			ArgsUsage: "PROVIDER",
+			Action:    runRemoveAuth,
+		},
+	},
+}
+
+// apiKeyFlags are the flags holding the API key of each provider.
+var apiKeyFlags = map[string]*cli.StringFlag{
+	anthropic.ProviderName: flagAPIKeyAnthropic,
+	openai.ProviderName:    flagAPIKeyOpenAI,
+	groq.ProviderName:      flagAPIKeyGroq,
+	cerebras.ProviderName:  flagAPIKeyCerebras,
+}
+
+// -----------------------------------------------------------------------------
+// Actions
+// -----------------------------------------------------------------------------
+
+func runSetAuth(ctx context.Context, cmd *cli.Command) error {
+	provider, err := providerArg(cmd)
+	if err != nil {
+		return err
+	}
+	var key string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(cmd.Root().ErrWriter, "API key for %s: ", provider)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(cmd.Root().ErrWriter)
+		if err != nil {
+			return fmt.Errorf("read API key: %w", err)
+		}
+		key = string(b)
+	} else {
+		key, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && key == "" {
+			return fmt.Errorf("read API key: %w", err)
+		}
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return errors.New("empty API key")
+	}
+	if err := config.SetCredential(provider, key); err != nil {
+		return fmt.Errorf("store API key: %w", err)
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Stored the API key for %s in %s\n", provider, config.CredentialsFilePath())
+	return nil
+}
+
+func runShowAuth(ctx context.Context, cmd *cli.Command) error {
+	providers := config.ProviderNames
+	if cmd.Args().Present() {
+		provider, err := providerArg(cmd)
+		if err != nil {
+			return err
+		}
+		providers = []string{provider}
+	}
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+	for _, provider := range providers {
+		key, source, err := resolveAPIKey(cmd, conf, provider)
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", provider, "(error)", err)
+		case key == "":
+			fmt.Fprintf(w, "%s\t%s\t%s\n", provider, maskAPIKey(key), missingAPIKeyMessage(provider))
+		default:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", provider, maskAPIKey(key), source)
+		}
+	}
+	return w.Flush()
+}
+
+func runRemoveAuth(ctx context.Context, cmd *cli.Command) error {
+	provider, err := providerArg(cmd)
+	if err != nil {
+		return err
+	}
+	if err := config.RemoveCredential(provider); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Removed the API key for %s\n", provider)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// providerArg returns the provider named by the only argument of cmd.
+func providerArg(cmd *cli.Command) (string, error) {
+	if cmd.Args().Len() != 1 {
+		return "", fmt.Errorf("want a provider: %s", strings.Join(config.ProviderNames, ", "))
+	}
+	provider := cmd.Args().First()
+	if !slices.Contains(config.ProviderNames, provider) {
+		return "", fmt.Errorf("unknown provider %q: want %s", provider, strings.Join(config.ProviderNames, ", "))
+	}
+	return provider, nil
+}
+
+// resolveAPIKey returns the API key of provider and where it comes from:
+// its flag or environment variable, else the key configured in conf or
+// stored with `aico auth set` (see [config.Config.APIKey]). conf may be nil.
+func resolveAPIKey(cmd *cli.Command, conf *config.Config, provider string) (key, source string, err error) {
+	flag, ok := apiKeyFlags[provider]
+	if !ok {
+		return "", "", nil
+	}
+	if key := cmd.String(flag.Name); key != "" {
+		if env := apiKeyEnvVar(provider); os.Getenv(env) == key {
+			return key, "$" + env, nil
+		}
+		return key, "--" + flag.Name, nil
+	}
+	if conf == nil {
+		conf = &config.Config{}
+	}
+	return conf.APIKey(context.Background(), provider)
+}
+
+// apiKeyEnvVar returns the environment variable of the API key of provider.
+func apiKeyEnvVar(provider string) string {
+	return envKeyWithPrefix(appname, provider+"_api_key")
+}
+
+// missingAPIKeyMessage tells how to set the API key of provider.
+func missingAPIKeyMessage(provider string) string {
+	return fmt.Sprintf("not set; use `aico auth set %s`, --%s or $%s",
+		provider, apiKeyFlags[provider].Name, apiKeyEnvVar(provider))
+}
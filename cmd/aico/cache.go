@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/config"
+)
+
+var CmdCache = &cli.Command{
+	Name:  "cache",
+	Usage: "Manage the local response cache",
+	Description: "When [response_cache] is enabled in config.toml, identical requests are\n" +
+		"answered from responses stored on disk instead of calling the provider.",
+
+	// default action: show statistics
+	Action: runCacheStats,
+	Commands: []*cli.Command{
+		{
+			Name:   "stats",
+			Usage:  "Show the number, size and hits of cached responses",
+			Action: runCacheStats,
+		},
+		{
+			Name:   "clear",
+			Usage:  "Remove cached responses",
+			Action: runCacheClear,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "expired",
+					Usage: "only remove responses older than the TTL",
+				},
+			},
+		},
+	},
+}
+
+// -----------------------------------------------------------------------------
+// Actions
+// -----------------------------------------------------------------------------
+
+func runCacheStats(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	cache, err := responseCache(conf)
+	if err != nil {
+		return err
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		return fmt.Errorf("cache stats: %w", err)
+	}
+
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Root().Writer, jsonTypeCacheStats, stats)
+	}
+	w := cmd.Root().Writer
+	ttl, _ := conf.ResponseCache.GetTTL()
+	fmt.Fprintf(w, "Enabled:   %t\n", conf.ResponseCache.Enabled)
+	fmt.Fprintf(w, "TTL:       %s\n", formatTTL(ttl))
+	fmt.Fprintf(w, "Directory: %s\n", cache.Dir())
+	fmt.Fprintf(w, "Entries:   %d (%d expired)\n", stats.Entries, stats.Expired)
+	fmt.Fprintf(w, "Size:      %s\n", formatBytes(stats.Bytes))
+	fmt.Fprintf(w, "Hits:      %d\n", stats.Hits)
+	fmt.Fprintf(w, "Saved:     %s\n", formatCost(stats.Saved))
+	return nil
+}
+
+func runCacheClear(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	cache, err := responseCache(conf)
+	if err != nil {
+		return err
+	}
+	n, err := cache.Clear(cmd.Bool("expired"))
+	if err != nil {
+		return fmt.Errorf("clear cache: %w", err)
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Removed %d cached responses.\n", n)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// responseCache returns the response cache configured in conf.
+func responseCache(conf *config.Config) (*assistant.ResponseCache, error) {
+	ttl, err := conf.ResponseCache.GetTTL()
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewResponseCache(config.DefaultResponseCacheDir(), ttl), nil
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl == 0 {
+		return "forever"
+	}
+	return ttl.String()
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"runtime"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/urfave/cli/v3"
 
@@ -21,6 +23,54 @@ var CmdConfig = &cli.Command{
 			Usage:  "Show the path to the configuration file",
 			Action: runShowConfigPath,
 		},
+		{
+			Name:  "show",
+			Usage: "Show the settings of the configuration file",
+			Description: "With --resolved, show the effective settings instead: those of the\n" +
+				"project config (.aico.toml or .aico/config.toml in the working directory\n" +
+				"or a parent) merged over the configuration file, then the --profile,\n" +
+				"with the file setting each value.",
+			Action: runShowConfig,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "resolved",
+					Usage: "show the merged settings and their sources",
+				},
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Show the value of a setting",
+			ArgsUsage: "KEY",
+			Description: "KEY is a dotted TOML key, e.g. \"model\" or \"providers.openai.base_url\".\n" +
+				"A string is printed as is, other values in TOML syntax. For a table, all\n" +
+				"settings below it are printed as `key = value` lines. The value is that of\n" +
+				"the effective configuration, or the default if it is not set.",
+			Action: runGetConfig,
+		},
+		{
+			Name:      "set",
+			Usage:     "Set a setting in the configuration file",
+			ArgsUsage: "KEY VALUE",
+			Description: "VALUE is parsed as a TOML value (e.g. true, 0.5 or '[\"a\", \"b\"]') and\n" +
+				"taken as a string otherwise. The file is rewritten, without its comments.\n" +
+				"Unknown keys and invalid values are rejected.",
+			Action: runSetConfig,
+		},
+		{
+			Name:      "unset",
+			Usage:     "Remove a setting from the configuration file",
+			ArgsUsage: "KEY",
+			Action:    runUnsetConfig,
+		},
+		{
+			Name:  "validate",
+			Usage: "Check the configuration for mistakes",
+			Description: "Reports unknown keys, invalid values, models that do not resolve to a\n" +
+				"provider and references to personas that do not exist, in the\n" +
+				"configuration file and the project config merged over it.",
+			Action: runValidateConfig,
+		},
 		{
 			Name:   "init",
 			Usage:  "Initialize the configuration file",
@@ -44,6 +94,107 @@ func runShowConfigPath(ctx context.Context, cmd *cli.Command) error {
 	return err
 }
 
+func runShowConfig(ctx context.Context, cmd *cli.Command) error {
+	resolved := cmd.Bool("resolved")
+	load := func() (*config.Config, error) { return config.LoadFile(config.ConfigFilePath()) }
+	if resolved {
+		load = config.Load
+	}
+	conf, err := load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	view := configView{
+		Location: conf.Location(),
+		Project:  conf.ProjectLocation(),
+		Profile:  conf.Profile(),
+		Settings: conf.Settings(),
+	}
+	if view.Settings == nil {
+		view.Settings = []config.Setting{}
+	}
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Root().Writer, jsonTypeConfig, view)
+	}
+
+	w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 1, ' ', 0)
+	if resolved {
+		fmt.Fprintf(w, "# user:    %s\n", view.Location)
+		if view.Project != "" {
+			fmt.Fprintf(w, "# project: %s\n", view.Project)
+		}
+		if view.Profile != "" {
+			fmt.Fprintf(w, "# profile: %s\n", view.Profile)
+		}
+	}
+	for _, s := range view.Settings {
+		if resolved {
+			fmt.Fprintf(w, "%s = %s\t# %s\n", s.Key, formatTOMLValue(s.Value), s.Source)
+		} else {
+			fmt.Fprintf(w, "%s = %s\n", s.Key, formatTOMLValue(s.Value))
+		}
+	}
+	return w.Flush()
+}
+
+func runGetConfig(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("usage: config get KEY")
+	}
+	key := cmd.Args().First()
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	settings, err := conf.Lookup(key)
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return fmt.Errorf("%s is not set", key)
+	}
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Root().Writer, jsonTypeConfig, configView{
+			Location: conf.Location(),
+			Project:  conf.ProjectLocation(),
+			Profile:  conf.Profile(),
+			Settings: settings,
+		})
+	}
+
+	w := cmd.Root().Writer
+	if s := settings[0]; len(settings) == 1 && s.Key != key {
+		_, err = fmt.Fprintf(w, "%s = %s\n", s.Key, formatTOMLValue(s.Value))
+		return err
+	}
+	if len(settings) == 1 {
+		if v, ok := settings[0].Value.(string); ok {
+			_, err = fmt.Fprintln(w, v)
+		} else {
+			_, err = fmt.Fprintln(w, formatTOMLValue(settings[0].Value))
+		}
+		return err
+	}
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s = %s\n", s.Key, formatTOMLValue(s.Value))
+	}
+	return nil
+}
+
+func runSetConfig(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return fmt.Errorf("usage: config set KEY VALUE")
+	}
+	return config.SetValue(config.ConfigFilePath(), cmd.Args().Get(0), cmd.Args().Get(1))
+}
+
+func runUnsetConfig(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("usage: config unset KEY")
+	}
+	return config.UnsetValue(config.ConfigFilePath(), cmd.Args().First())
+}
+
 func runInitConfig(ctx context.Context, cmd *cli.Command) error {
 	conf, err := config.InitAndLoad()
 	if err != nil {
@@ -63,13 +214,6 @@ func runInitConfig(ctx context.Context, cmd *cli.Command) error {
 }
 
 func runEditConfig(ctx context.Context, cmd *cli.Command) error {
-	editor, ok := os.LookupEnv("EDITOR")
-	if !ok {
-		editor = "vim"
-		if runtime.GOOS == "windows" {
-			editor = "notepad.exe"
-		}
-	}
 	conf, err := loadConfig(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("load config: %w", err)
@@ -82,14 +226,59 @@ func runEditConfig(ctx context.Context, cmd *cli.Command) error {
 	}
 	defer cleanup()
 
-	cmdExec := exec.Command(editor, conf.Location())
-	cmdExec.Stdin = os.Stdin
-	cmdExec.Stdout = os.Stdout
-	cmdExec.Stderr = os.Stderr
-	if err := cmdExec.Run(); err != nil {
+	if err := runEditor(conf.Location()); err != nil {
 		return fmt.Errorf("edit configuration: %w", err)
 	}
 
 	logger.Debug("Configuration file edited")
 	return nil
 }
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// formatTOMLValue formats a value decoded from TOML in TOML syntax, with
+// tables inline.
+func formatTOMLValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatTOMLValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []map[string]any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatTOMLValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		items := make([]string, 0, len(v))
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			items = append(items, k+" = "+formatTOMLValue(v[k]))
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// configView is the JSON output shape of `config show`.
+type configView struct {
+	// Location is the configuration file.
+	Location string `json:"location"`
+
+	// Project is the project config merged over it, empty without one or
+	// without --resolved.
+	Project string `json:"project"`
+
+	// Profile is the profile merged over both, empty without one or
+	// without --resolved.
+	Profile string `json:"profile"`
+
+	Settings []config.Setting `json:"settings"`
+}
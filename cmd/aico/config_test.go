@@ -3,6 +3,9 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -55,3 +58,68 @@ func TestConfigCommand_Initialize(t *testing.T) {
 	require.FileExists(configPath)
 	require.Contains(buf.String(), "Configuration file initialized\n")
 }
+
+func TestConfigCommand_Validate(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+model = "claude-haiku-4-5"
+modle = "gpt-4.1"
+
+[persona.default]
+description = "Default"
+message = "Hi."
+model = "no-such-model"
+
+[prompt.review]
+template = "Review this."
+persona = "reviewer"
+`), 0644))
+	t.Setenv(config.EnvKeyConfigPath, configPath)
+	t.Chdir(t.TempDir())
+
+	var buf bytes.Buffer
+	app := &cli.Command{
+		Writer:   &buf,
+		Flags:    []cli.Flag{flagJSON},
+		Commands: []*cli.Command{CmdConfig},
+	}
+	err := app.Run(context.Background(), []string{"aico", "--json", "config", "validate"})
+	require.ErrorContains(t, err, "3 of 4 checks failed")
+
+	var doc struct{ Data []checkView }
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	var failed []string
+	for _, c := range doc.Data {
+		if c.Status == checkError {
+			failed = append(failed, c.Name)
+		}
+	}
+	require.Equal(t, []string{"modle", "persona.default.model", "prompt.review.persona"}, failed)
+}
+
+func TestRunCommand_Profile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[prompt.greet]
+template = "Hello."
+
+[profile.work.prompt.greet]
+template = "Hello, {{.Vars.who}}."
+args = [{ name = "who", required = true }]
+`), 0644))
+	t.Setenv(config.EnvKeyConfigPath, configPath)
+	t.Setenv(config.EnvKeyProfile, "")
+	t.Chdir(t.TempDir())
+
+	// The template is looked up after --profile applies.
+	app := &cli.Command{
+		Writer:   io.Discard,
+		Flags:    []cli.Flag{flagProfile},
+		Commands: []*cli.Command{CmdRun},
+	}
+	err := app.Run(context.Background(), []string{"aico", "--profile", "work", "run", "greet"})
+	require.EqualError(t, err, "greet: missing argument WHO")
+
+	err = app.Run(context.Background(), []string{"aico", "run", "nope"})
+	require.ErrorContains(t, err, `prompt template "nope" not found`)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/providers/anthropic"
+	"micheam.com/aico/internal/providers/cerebras"
+	"micheam.com/aico/internal/providers/groq"
+	"micheam.com/aico/internal/providers/openai"
+)
+
+var CmdDoctor = &cli.Command{
+	Name:  "doctor",
+	Usage: "check the configuration, API keys and directories",
+	Description: "Runs the checks of `aico config validate`, then checks that an API key is\n" +
+		"set for each provider and that the session directory and the logfile are\n" +
+		"writable. With --online, each API key is also tried by listing the models\n" +
+		"of its provider, which costs no tokens.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "online",
+			Usage: "check the API keys with a request to each provider",
+		},
+	},
+	Action: runDoctor,
+}
+
+// Statuses of a check
+const (
+	checkOK      = "ok"
+	checkWarning = "warning"
+	checkError   = "error"
+)
+
+// pingTimeout limits the requests of `doctor --online` to providers without
+// a timeout of their own.
+const pingTimeout = 10 * time.Second
+
+// -----------------------------------------------------------------------------
+// Actions
+// -----------------------------------------------------------------------------
+
+func runValidateConfig(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return writeChecks(cmd, []checkView{{Name: "config", Status: checkError, Message: err.Error()}})
+	}
+	return writeChecks(cmd, configChecks(conf))
+}
+
+func runDoctor(ctx context.Context, cmd *cli.Command) error {
+	var checks []checkView
+	conf, err := config.Load()
+	if err != nil {
+		conf = &config.Config{}
+		checks = append(checks, checkView{Name: "config", Status: checkError, Message: err.Error()})
+	} else {
+		checks = append(checks, configChecks(conf)...)
+	}
+
+	// A missing API key is only an error for the providers of the models
+	// the configuration uses.
+	used := make(map[string]bool)
+	for _, m := range modelSettings(conf) {
+		if provider, _, found := detectProviderByModelSpec(m.Value.(string), conf.DefaultProvider); found {
+			used[provider] = true
+		}
+	}
+	for _, provider := range config.ProviderNames {
+		name := "api_key." + provider
+		apiKey, source, err := resolveAPIKey(cmd, conf, provider)
+		switch {
+		case err != nil:
+			checks = append(checks, checkView{Name: name, Status: checkError, Message: err.Error()})
+		case apiKey == "" && used[provider]:
+			checks = append(checks, checkView{Name: name, Status: checkError, Message: missingAPIKeyMessage(provider)})
+		case apiKey == "":
+			checks = append(checks, checkView{Name: name, Status: checkWarning, Message: missingAPIKeyMessage(provider)})
+		case cmd.Bool("online"):
+			if err := pingProvider(ctx, conf, provider, apiKey); err != nil {
+				checks = append(checks, checkView{Name: name, Status: checkError, Message: err.Error()})
+			} else {
+				checks = append(checks, checkView{Name: name, Status: checkOK, Message: "from " + source + ", accepted by " + provider})
+			}
+		default:
+			checks = append(checks, checkView{Name: name, Status: checkOK, Message: "from " + source})
+		}
+	}
+
+	dir := conf.GetSessionDir()
+	if err := checkWritableDir(dir); err != nil {
+		checks = append(checks, checkView{Name: "session_dir", Status: checkError, Message: err.Error()})
+	} else {
+		checks = append(checks, checkView{Name: "session_dir", Status: checkOK, Message: dir + " is writable"})
+	}
+	if f, err := conf.OpenLogfile(); err != nil {
+		checks = append(checks, checkView{Name: "logfile", Status: checkError, Message: err.Error()})
+	} else {
+		f.Close()
+		checks = append(checks, checkView{Name: "logfile", Status: checkOK, Message: f.Name() + " is writable"})
+	}
+	return writeChecks(cmd, checks)
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// configChecks checks conf for unknown keys, models that do not resolve and
+// references to personas that do not exist.
+func configChecks(conf *config.Config) []checkView {
+	location := conf.Location()
+	if conf.ProjectLocation() != "" {
+		location += ", " + conf.ProjectLocation()
+	}
+	checks := []checkView{{Name: "config", Status: checkOK, Message: "loaded " + location}}
+	if _, err := os.Stat(conf.Location()); errors.Is(err, os.ErrNotExist) {
+		checks[0] = checkView{Name: "config", Status: checkWarning,
+			Message: fmt.Sprintf("%s not found; create it with `aico config init`", conf.Location())}
+	}
+
+	for _, s := range conf.UnknownKeys() {
+		checks = append(checks, checkView{Name: s.Key, Status: checkError, Message: "unknown key in " + s.Source})
+	}
+	for _, m := range modelSettings(conf) {
+		if _, _, found := detectProviderByModelSpec(m.Value.(string), conf.DefaultProvider); !found {
+			checks = append(checks, checkView{Name: m.Key, Status: checkError,
+				Message: fmt.Sprintf("unknown model %q; see `aico models ls`", m.Value)})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.PersonaMap)) {
+		if _, err := conf.ResolvePersona(name); err != nil {
+			checks = append(checks, checkView{Name: "persona." + name + ".extends", Status: checkError, Message: err.Error()})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.PromptMap)) {
+		p := conf.PromptMap[name]
+		if _, ok := conf.PersonaMap[p.Persona]; p.Persona != "" && !ok {
+			checks = append(checks, checkView{Name: "prompt." + name + ".persona", Status: checkError,
+				Message: fmt.Sprintf("unknown persona %q", p.Persona)})
+		}
+	}
+	return checks
+}
+
+// modelSettings returns the model specs set in conf, by key.
+func modelSettings(conf *config.Config) []config.Setting {
+	settings := []config.Setting{{Key: "model", Value: cmp.Or(conf.Model, config.DefaultModel)}}
+	if title := conf.GetTitleModel(); title != "" {
+		settings = append(settings, config.Setting{Key: "title_model", Value: title})
+	}
+	if conf.Compaction.SummaryModel != "" {
+		settings = append(settings, config.Setting{Key: "compaction.summary_model", Value: conf.Compaction.SummaryModel})
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.PersonaMap)) {
+		if m := conf.PersonaMap[name].Model; m != "" {
+			settings = append(settings, config.Setting{Key: "persona." + name + ".model", Value: m})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.PromptMap)) {
+		if m := conf.PromptMap[name].Model; m != "" {
+			settings = append(settings, config.Setting{Key: "prompt." + name + ".model", Value: m})
+		}
+	}
+	return settings
+}
+
+// checkWritableDir reports whether files can be created in dir, creating it
+// if needed.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".aico-doctor-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// pingProvider lists the models of provider with apiKey, which fails if the
+// key is not accepted.
+func pingProvider(ctx context.Context, conf *config.Config, provider, apiKey string) error {
+	settings := conf.Providers[provider]
+	timeout, err := settings.GetTimeout()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(timeout, pingTimeout))
+	defer cancel()
+
+	var url string
+	header := make(http.Header)
+	switch provider {
+	case anthropic.ProviderName:
+		url = strings.TrimSuffix(cmp.Or(settings.BaseURL, "https://api.anthropic.com"), "/") + "/v1/models"
+		header.Set("X-Api-Key", apiKey)
+		header.Set("Anthropic-Version", "2023-06-01")
+	case openai.ProviderName, groq.ProviderName, cerebras.ProviderName:
+		defaults := map[string]string{
+			openai.ProviderName:   openai.DefaultBaseURL,
+			groq.ProviderName:     groq.DefaultBaseURL,
+			cerebras.ProviderName: cerebras.DefaultBaseURL,
+		}
+		url = strings.TrimSuffix(cmp.Or(settings.BaseURL, defaults[provider]), "/") + "/models"
+		header.Set("Authorization", "Bearer "+apiKey)
+	default:
+		return fmt.Errorf("unknown provider %q", provider)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// writeChecks prints checks, and returns an error if any failed.
+func writeChecks(cmd *cli.Command, checks []checkView) error {
+	if cmd.Bool(flagJSON.Name) {
+		if err := writeJSONDocument(cmd.Root().Writer, jsonTypeChecks, checks); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+		for _, c := range checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Status, c.Name, strings.ReplaceAll(c.Message, "\n", "; "))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	failed := 0
+	for _, c := range checks {
+		if c.Status == checkError {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// checkView is the JSON output shape of a check of `config validate` and
+// `doctor`.
+type checkView struct {
+	// Name is the setting or resource checked, e.g. "persona.review.model".
+	Name string `json:"name"`
+
+	// Status is "ok", "warning" or "error".
+	Status string `json:"status"`
+
+	Message string `json:"message"`
+}
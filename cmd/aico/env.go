@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/urfave/cli/v3"
 
@@ -13,7 +14,7 @@ import (
 
 var CmdEnv = &cli.Command{
 	Name:   "env",
-	Usage:  "show environment information",
+	Usage:  "show the effective settings and where they come from",
 	Action: runShowEnv,
 }
 
@@ -22,20 +23,76 @@ var CmdEnv = &cli.Command{
 // -----------------------------------------------------------------------------
 
 func runShowEnv(ctx context.Context, cmd *cli.Command) error {
-	var model string
-	if conf, err := config.Load(); err != nil {
-		model = "Not-loaded"
-	} else {
-		model = conf.Model
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
 	}
 
-	fmt.Printf("Default Model: %s\n", model)
-	fmt.Printf("Config file: %s\n", config.ConfigFilePath())
+	// Every setting, with its value if set or the default otherwise.
+	var settings []config.Setting
+	for _, v := range config.EnvVars() {
+		if found, err := conf.Lookup(v.Key); err == nil && len(found) > 0 {
+			settings = append(settings, found[0])
+		} else {
+			settings = append(settings, config.Setting{Key: v.Key})
+		}
+	}
+	persona := config.Setting{Key: "persona", Value: cmd.String(flagPersona.Name), Source: "default"}
+	if env := envKeyWithPrefix(appname, "persona"); os.Getenv(env) == persona.Value {
+		persona.Source = "$" + env
+	} else if cmd.IsSet(flagPersona.Name) {
+		persona.Source = "--" + flagPersona.Name
+	}
 
-	fmt.Printf("AICO_ANTHROPIC_API_KEY: %s\n", maskAPIKey(os.Getenv("AICO_ANTHROPIC_API_KEY")))
-	fmt.Printf("AICO_OPENAI_API_KEY: %s\n", maskAPIKey(os.Getenv("AICO_OPENAI_API_KEY")))
-	fmt.Printf("AICO_CEREBRAS_API_KEY: %s\n", maskAPIKey(os.Getenv("AICO_CEREBRAS_API_KEY")))
-	return nil
+	if cmd.Bool(flagJSON.Name) {
+		view := configView{
+			Location: conf.Location(),
+			Project:  conf.ProjectLocation(),
+			Profile:  conf.Profile(),
+			Settings: []config.Setting{persona},
+		}
+		for _, s := range settings {
+			if s.Value != nil {
+				view.Settings = append(view.Settings, s)
+			}
+		}
+		return writeJSONDocument(cmd.Root().Writer, jsonTypeConfig, view)
+	}
+
+	w := cmd.Root().Writer
+	fmt.Fprintf(w, "Config file: %s\n", config.ConfigFilePath())
+	if project := conf.ProjectLocation(); project != "" {
+		fmt.Fprintf(w, "Project config file: %s\n", project)
+	}
+	if profile := conf.Profile(); profile != "" {
+		fmt.Fprintf(w, "Profile: %s\n", profile)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tVARIABLE")
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", persona.Key, formatTOMLValue(persona.Value), persona.Source, envKeyWithPrefix(appname, "persona"))
+	for i, v := range config.EnvVars() {
+		s := settings[i]
+		value := "(not set)"
+		if s.Value != nil {
+			value = formatTOMLValue(s.Value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Key, value, s.Source, v.Name)
+	}
+	for _, provider := range config.ProviderNames {
+		// Secret commands are not run just to show their output.
+		key, source := "", ""
+		if cmd.String(apiKeyFlags[provider].Name) == "" && conf.Providers[provider].APIKeyCmd != "" {
+			key, source = "(not run)", config.APIKeySourceCmd
+		} else if key, source, err = resolveAPIKey(cmd, conf, provider); err != nil {
+			key, source = "(error)", err.Error()
+		} else {
+			key = maskAPIKey(key)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "api_key."+provider, key, source, apiKeyEnvVar(provider))
+	}
+	return tw.Flush()
 }
 
 func maskAPIKey(key string) string {
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 	"golang.org/x/term"
@@ -15,6 +19,7 @@ import (
 	"micheam.com/aico/internal/assistant"
 	"micheam.com/aico/internal/config"
 	"micheam.com/aico/internal/logging"
+	"micheam.com/aico/internal/prompt"
 )
 
 // -----------------------------------------------------------------------------
@@ -26,13 +31,33 @@ func runGenerate(ctx context.Context, cmd *cli.Command) error {
 }
 
 func doGenerate(ctx context.Context, cmd *cli.Command, prompt string) error {
+	source, err := detectSource(cmd.String(flagSource.Name), os.Stdin)
+	if err != nil {
+		return err
+	}
+	return generateWithSource(ctx, cmd, source, prompt)
+}
+
+// generateWithSource sends source, then prompt, as the next user message of
+// the session selected by the flags and writes the reply.
+func generateWithSource(ctx context.Context, cmd *cli.Command, source, prompt string) error {
 	logger, cleanup, err := initializeLogger(ctx, cmd)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	sess, err := loadSession(cmd)
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("can't load config: %w", err)
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer store.Close()
+
+	sess, err := loadSession(ctx, cmd, conf, store)
 	if err != nil {
 		return fmt.Errorf("failed to load session: %w", err)
 	}
@@ -42,38 +67,64 @@ func doGenerate(ctx context.Context, cmd *cli.Command, prompt string) error {
 
 	{
 		userContents := []assistant.MessageContent{}
-		source, err := detectSource(cmd.String(flagSource.Name), os.Stdin)
-		if err != nil {
-			return err
-		}
 		if source != "" {
 			userContents = append(userContents, assistant.NewTextContent(source))
 		}
-		if prompt := cmd.Args().First(); prompt != "" {
+		if prompt != "" {
 			userContents = append(userContents, assistant.NewTextContent(prompt))
 		}
 		userMsg := assistant.NewUserMessage(userContents...)
 		sess.AddMessage(userMsg)
 	}
 
-	model, err := modelByName(cmd, sess.Model)
+	return generateReply(ctx, cmd, conf, sess, detectWriter(cmd))
+}
+
+// generateReply streams the model's reply to the conversation in sess to
+// writer, appends it to the session and saves the session. writer is closed
+// when the reply is complete. If writer is an eventWriter, it also receives
+// the start, end, usage, thinking and tool calls of the reply.
+//
+// The session is saved even if generation fails, so that the prompt is not
+// lost.
+func generateReply(ctx context.Context, cmd *cli.Command, conf *config.Config, sess *assistant.Session, writer io.WriteCloser) (err error) {
+	defer writer.Close()
+	logger := logging.LoggerFrom(ctx)
+	if err := writeEvent(writer, jsonEvent{Type: jsonEventStart, Session: sess.ID, Model: sess.Model}); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	defer func() {
+		ev := jsonEvent{Type: jsonEventStop, Session: sess.ID, Model: sess.Model}
+		if err != nil {
+			ev = jsonEvent{Type: jsonEventError, Error: err.Error()}
+		}
+		if evErr := writeEvent(writer, ev); evErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to write event: %w", evErr))
+		}
+	}()
+	model, err := prepareModel(ctx, cmd, conf, sess.Model, sess.Persona)
 	if err != nil {
-		return fmt.Errorf("model by name: %w", err)
+		return err
 	}
 	model.SetSystemInstruction(sess.SystemInstruction...)
-	defer sess.Save(ctx, model)
+	defer func() {
+		if saveErr := sess.Save(ctx); saveErr != nil {
+			err = errors.Join(err, fmt.Errorf("save session %s: %w", sess.ID, saveErr))
+		}
+	}()
 
-	iter, err := model.GenerateContentStream(ctx, sess.GetMessages()...)
+	msgs, err := fitContext(ctx, cmd, conf, model, sess)
+	if err != nil {
+		return err
+	}
+
+	iter, err := model.GenerateContentStream(ctx, msgs...)
 	if err != nil {
 		return fmt.Errorf("failed to generate content: %w", err)
 	}
 
 	// Stream content and accumulate text for session history
-	var (
-		acc    = new(strings.Builder)
-		writer = detectWriter(cmd, *sess)
-	)
-	defer writer.Close()
+	acc := new(strings.Builder)
 	var usage *assistant.Usage
 	for resp, err := range iter {
 		if err != nil {
@@ -91,6 +142,14 @@ func doGenerate(ctx context.Context, cmd *cli.Command, prompt string) error {
 				return fmt.Errorf("failed to write content: %w", err)
 			}
 			acc.WriteString(content.Text)
+		case *assistant.ThinkingContent:
+			if err := writeEvent(writer, jsonEvent{Type: jsonEventThinking, Text: content.Text}); err != nil {
+				return fmt.Errorf("failed to write event: %w", err)
+			}
+		case *assistant.ToolCallContent:
+			if err := writeEvent(writer, jsonEvent{Type: jsonEventToolCall, ToolCall: content}); err != nil {
+				return fmt.Errorf("failed to write event: %w", err)
+			}
 		default:
 			// Ignore other content types for now
 			logger.Warn("ignore unsupported content type",
@@ -98,25 +157,175 @@ func doGenerate(ctx context.Context, cmd *cli.Command, prompt string) error {
 		}
 	}
 	if usage != nil {
+		sess.AddUsage(model, usage)
 		logger.Debug("prompt cache usage",
 			"input_tokens", usage.InputTokens,
 			"cached_input_tokens", usage.CachedInputTokens,
-			"cache_hit_rate", fmt.Sprintf("%.1f%%", usage.CacheHitRate()))
-		if jw, ok := writer.(*JSONLineStreamWriter); ok {
-			jw.SetUsage(usage)
+			"cache_write_tokens", usage.CacheWriteTokens,
+			"cache_hit_rate", fmt.Sprintf("%.1f%%", usage.CacheHitRate()),
+			"cache_savings", formatCost(usage.CacheSavings))
+		if err := writeEvent(writer, jsonEvent{Type: jsonEventUsage, Usage: toUsageInfo(usage)}); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
 		}
 	}
 	if acc.Len() > 0 {
-		sess.AddMessage(assistant.NewAssistantMessage(assistant.NewTextContent(acc.String())))
+		reply := assistant.NewAssistantMessage(assistant.NewTextContent(acc.String()))
+		reply.Model = sess.Model
+		reply.Usage = usage
+		sess.AddMessage(reply)
+	}
+	if sess.NeedsTitle() {
+		generateTitle(ctx, cmd, conf, sess)
 	}
 	return nil
 }
 
-// generateView is the JSON output shape for the generate action when --json is set.
-type generateView struct {
-	Session string `json:"session"`
-	Model   string `json:"model"`
-	Content string `json:"content"`
+// prepareModel returns the model for name, set up with the prompt cache
+// mode of persona and the response cache, after checking the budget.
+func prepareModel(ctx context.Context, cmd *cli.Command, conf *config.Config, name, persona string) (assistant.GenerativeModel, error) {
+	model, err := modelByName(cmd, name)
+	if err != nil {
+		return nil, fmt.Errorf("model by name: %w", err)
+	}
+	if pc, ok := model.(assistant.PromptCacher); ok {
+		mode, err := promptCacheMode(cmd, conf, persona)
+		if err != nil {
+			return nil, err
+		}
+		pc.SetPromptCache(mode)
+	}
+	if conf.ResponseCache.Enabled && !cmd.Bool(flagNoCache.Name) {
+		cache, err := responseCache(conf)
+		if err != nil {
+			return nil, err
+		}
+		model = cache.Wrap(model)
+	}
+	if ps, ok := model.(assistant.ParamSetter); ok {
+		params, err := generationParams(conf, persona)
+		if err != nil {
+			return nil, err
+		}
+		ps.SetGenerationParams(params)
+	}
+	if err := checkBudget(ctx, cmd, conf, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// promptCacheMode resolves the prompt cache mode from the --cache flag, then
+// the setting of the persona, and defaults to auto.
+func promptCacheMode(cmd *cli.Command, conf *config.Config, persona string) (assistant.CacheMode, error) {
+	name := cmd.String(flagCache.Name)
+	if p, err := conf.ResolvePersona(persona); name == "" && err == nil {
+		name = p.Cache
+	}
+	mode, err := assistant.ParseCacheMode(name)
+	if err != nil {
+		return "", fmt.Errorf("prompt cache: %w", err)
+	}
+	return mode, nil
+}
+
+// generationParams returns the generation parameters set by the persona.
+func generationParams(conf *config.Config, persona string) (assistant.GenerationParams, error) {
+	p, err := conf.ResolvePersona(persona)
+	if err != nil {
+		// Sessions may outlive their persona; use the model defaults.
+		return assistant.GenerationParams{}, nil
+	}
+	effort, err := assistant.ParseEffort(p.Effort)
+	if err != nil {
+		return assistant.GenerationParams{}, fmt.Errorf("persona %q: %w", persona, err)
+	}
+	return assistant.GenerationParams{
+		Temperature: p.Temperature,
+		MaxTokens:   p.MaxTokens,
+		Effort:      effort,
+	}, nil
+}
+
+// fitContext returns the messages of the session to send to model,
+// compacted according to the configured strategy if they no longer fit into
+// its context window.
+func fitContext(ctx context.Context, cmd *cli.Command, conf *config.Config, model assistant.ModelDescriptor, sess *assistant.Session) ([]assistant.Message, error) {
+	policy, err := contextPolicy(cmd, conf, "")
+	if err != nil {
+		return nil, err
+	}
+	msgs, res, err := policy.Fit(ctx, model, sess)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case res == nil:
+	case res.Summarized:
+		fmt.Fprintf(cmd.ErrWriter, "Note: %s to fit the context window of %s.\n", describeCompaction(res), model.Name())
+	default:
+		fmt.Fprintf(cmd.ErrWriter, "Note: left %d earlier messages out of the request (about %d -> %d tokens) to fit the context window of %s; the session keeps them.\n",
+			res.Removed, res.Before, res.After, model.Name())
+	}
+	return msgs, nil
+}
+
+// contextPolicy builds the compaction policy from conf. A non-empty strategy
+// overrides the configured one.
+func contextPolicy(cmd *cli.Command, conf *config.Config, strategy string) (assistant.ContextPolicy, error) {
+	st, err := assistant.ParseCompactionStrategy(cmp.Or(strategy, conf.Compaction.Strategy))
+	if err != nil {
+		return assistant.ContextPolicy{}, err
+	}
+	policy := assistant.ContextPolicy{
+		Strategy:  st,
+		Threshold: conf.Compaction.GetThreshold(),
+	}
+	if st == assistant.CompactionSummarize {
+		policy.Summarizer, err = modelByName(cmd, conf.Compaction.GetSummaryModel())
+		if err != nil {
+			return assistant.ContextPolicy{}, fmt.Errorf("summary model: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+func describeCompaction(res *assistant.CompactionResult) string {
+	verb := "dropped"
+	if res.Summarized {
+		verb = "summarized"
+	}
+	return fmt.Sprintf("%s %d earlier messages (about %d -> %d tokens)", verb, res.Removed, res.Before, res.After)
+}
+
+// titleTimeout bounds the title request, which delays the first reply of a
+// session.
+const titleTimeout = 10 * time.Second
+
+// generateTitle titles the session with the configured title model, subject
+// to the budget like any other request. Failures are logged, and the session
+// is titled with a preview of the first prompt instead, so that the request
+// is not repeated on every later turn.
+func generateTitle(ctx context.Context, cmd *cli.Command, conf *config.Config, sess *assistant.Session) {
+	spec := conf.GetTitleModel()
+	if spec == "" {
+		return
+	}
+	logger := logging.LoggerFrom(ctx)
+	ctx, cancel := context.WithTimeout(ctx, titleTimeout)
+	defer cancel()
+	title, err := func() (string, error) {
+		model, err := prepareModel(ctx, cmd, conf, spec, "")
+		if err != nil {
+			return "", err
+		}
+		return assistant.GenerateTitle(ctx, model, sess)
+	}()
+	if err != nil {
+		logger.Warn("failed to generate session title", "model", spec, "error", err)
+		title = sess.Preview(80)
+	}
+	sess.Title = title
+	logger.Debug("session titled", "title", title)
 }
 
 // -----------------------------------------------------------------------------
@@ -240,12 +449,7 @@ func detectSessionMode(cmd *cli.Command) (SessionMode, error) {
 	return SessionModeNew, nil
 }
 
-func loadSession(cmd *cli.Command) (*assistant.Session, error) {
-	conf, err := config.Load()
-	if err != nil {
-		return nil, fmt.Errorf("can't load config: %w", err)
-	}
-
+func loadSession(ctx context.Context, cmd *cli.Command, conf *config.Config, store assistant.SessionStore) (*assistant.Session, error) {
 	sessMode, err := detectSessionMode(cmd)
 	if err != nil {
 		return nil, err
@@ -254,60 +458,74 @@ func loadSession(cmd *cli.Command) (*assistant.Session, error) {
 
 	switch sessMode {
 	case SessionModeLast:
-		return assistant.LoadLatestSession(conf.GetSessionDir())
+		return assistant.LoadLatestSession(ctx, store)
 	case SessionModeExisting:
-		return assistant.LoadSession(conf.GetSessionDir(), givenSessionID)
+		return store.Load(ctx, givenSessionID)
 	case SessionModeNew:
-		sess := assistant.NewSession(conf.GetSessionDir())
-		{ // Model
-			model, err := detectModel(cmd)
-			if err != nil {
-				return nil, fmt.Errorf("detect model: %w", err)
-			}
-			sess.Model = QualifiedName(model.Provider(), model.Name())
-		}
-		{ // Persona
-			personaName := cmd.String(flagPersona.Name)
-			persona, ok := conf.PersonaMap[personaName]
-			if !ok {
-				return nil, fmt.Errorf("persona %q not found", cmd.String(flagPersona.Name))
-			}
-			sess.SystemInstruction = append(sess.SystemInstruction, assistant.NewTextContent(persona.Message))
+		model, err := detectModel(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("detect model: %w", err)
 		}
-		{ // Contexts
-			contexts := cmd.StringSlice(flagContext.Name)
-			instructions := make([]*assistant.TextContent, 0)
-			if len(contexts) > 0 {
-				instructions = append(instructions,
-					assistant.NewTextContent("The following context is provided for the prompt."))
-			}
-			for _, ctx := range contexts {
-				content, err := resolveContext(ctx)
-				if err != nil {
-					return nil, fmt.Errorf("failed to resolve context %q: %w", ctx, err)
-				}
-				instructions = append(instructions, assistant.NewTextContent(content))
-			}
-			sess.SystemInstruction = append(sess.SystemInstruction, instructions...)
+		vars, err := prompt.ParseVars(cmd.StringSlice(flagVar.Name))
+		if err != nil {
+			return nil, err
 		}
-		return sess, nil
+		return newSession(store, conf, model, cmd.String(flagPersona.Name), vars, cmd.StringSlice(flagContext.Name))
 	default:
 		return nil, fmt.Errorf("unsupported session_mode(%v)", sessMode)
 	}
 }
 
-func detectWriter(cmd *cli.Command, sess assistant.Session) io.WriteCloser {
-	if cmd.Bool(flagJSON.Name) {
-		return &JSONLineStreamWriter{
-			enc: json.NewEncoder(cmd.Writer),
-			metaData: struct {
-				Session string
-				Model   string
-			}{
-				Session: sess.ID,
-				Model:   sess.Model,
-			},
+// newSession creates an unsaved session for model, led by the system prompt
+// of persona rendered with vars and followed by the context of the config,
+// of the persona and contexts (strings or @file paths).
+func newSession(store assistant.SessionStore, conf *config.Config, model assistant.ModelDescriptor, personaName string, vars map[string]string, contexts []string) (*assistant.Session, error) {
+	sess := assistant.NewSession(store)
+	sess.Model = QualifiedName(model.Provider(), model.Name())
+	sess.Profile = conf.Profile()
+	{ // Persona
+		persona, err := renderPersona(conf, personaName, vars)
+		if err != nil {
+			return nil, err
 		}
+		sess.Persona = personaName
+		sess.SystemInstruction = append(sess.SystemInstruction, assistant.NewTextContent(persona.Message))
+		contexts = slices.Concat(conf.Context, personaContext(conf, persona), contexts)
+	}
+	{ // Contexts
+		instructions := make([]*assistant.TextContent, 0)
+		if len(contexts) > 0 {
+			instructions = append(instructions,
+				assistant.NewTextContent("The following context is provided for the prompt."))
+		}
+		for _, ctx := range contexts {
+			content, err := resolveContext(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve context %q: %w", ctx, err)
+			}
+			instructions = append(instructions, assistant.NewTextContent(content))
+		}
+		sess.SystemInstruction = append(sess.SystemInstruction, instructions...)
+	}
+	return sess, nil
+}
+
+// personaContext returns the context of persona p with relative "@path"
+// entries resolved against its persona directory.
+func personaContext(conf *config.Config, p *config.Personality) []string {
+	contexts := make([]string, 0, len(p.Context))
+	for _, c := range p.Context {
+		if path, ok := strings.CutPrefix(c, "@"); ok && !filepath.IsAbs(path) {
+			c = "@" + filepath.Join(cmp.Or(p.Dir(), conf.PersonaDir()), path)
+		}
+		contexts = append(contexts, c)
+	}
+	return contexts
+}
+
+func detectWriter(cmd *cli.Command) io.WriteCloser {
+	if cmd.Bool(flagJSON.Name) {
+		return NewJSONEventWriter(cmd.Writer)
 	}
 	return &ConsoleLineStreamWriter{
 		out: cmd.Writer,
@@ -2,12 +2,12 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 
 	"github.com/urfave/cli/v3"
 
+	"micheam.com/aico/internal/config"
 	"micheam.com/aico/internal/logging"
 )
 
@@ -34,14 +34,18 @@ func run(args []string) error {
 			flagDebug,
 			flagJSON,
 			flagModel,
+			flagProfile,
 
 			flagSessionID,
 			flagLast,
 			flagNoStream,
+			flagCache,
+			flagNoCache,
 			flagPersona,
 			flagSystemPrompt,
 			flagSource,
 			flagContext,
+			flagVar,
 
 			flagAPIKeyAnthropic,
 			flagAPIKeyOpenAI,
@@ -53,9 +57,18 @@ func run(args []string) error {
 		Commands: []*cli.Command{
 			CmdEnv,
 			CmdConfig,
+			CmdAuth,
+			CmdDoctor,
 			CmdModels,
 			CmdPersona,
+			CmdPrompts,
+			CmdRun,
 			CmdSession,
+			CmdUsage,
+			CmdCache,
+			CmdServe,
+			CmdRPC,
+			CmdSchema,
 		},
 	}
 	return app.Run(context.Background(), args)
@@ -73,6 +86,10 @@ var (
 		Aliases: []string{"c"},
 		Usage:   "context string or @file path (e.g., --context 'text' or --context @file.txt)",
 	}
+	flagVar = &cli.StringSliceFlag{
+		Name:  "var",
+		Usage: "variable `NAME=VALUE` for the persona template, available as {{.Vars.NAME}}",
+	}
 	flagDebug = &cli.BoolFlag{
 		Name:  "debug",
 		Usage: "Enable debug logging",
@@ -86,15 +103,34 @@ var (
 		Aliases: []string{"m"},
 		Usage:   "Model to use (e.g., 'gpt-4o' or 'openai:gpt-4o' for explicit provider)",
 	}
+	flagProfile = &cli.StringFlag{
+		Name:    "profile",
+		Usage:   "config profile to use (see [profile.NAME] in config.toml)",
+		Sources: cli.EnvVars(config.EnvKeyProfile),
+		// The config is loaded in many places; they all see the profile
+		// through the environment.
+		Action: func(ctx context.Context, cmd *cli.Command, name string) error {
+			return os.Setenv(config.EnvKeyProfile, name)
+		},
+	}
 	flagNoStream = &cli.BoolFlag{
 		Name:  "no-stream",
 		Usage: "disable streaming output",
 	}
+	flagCache = &cli.StringFlag{
+		Name:  "cache",
+		Usage: "prompt caching for Anthropic models: off, auto, 1h (default: persona setting, then auto)",
+	}
+	flagNoCache = &cli.BoolFlag{
+		Name:  "no-cache",
+		Usage: "bypass the local response cache (see [response_cache] in config.toml)",
+	}
 	flagPersona = &cli.StringFlag{
 		Name:    "persona",
 		Aliases: []string{"p"},
 		Usage:   "The persona to use",
 		Value:   "default",
+		Sources: cli.EnvVars(envKeyWithPrefix(appname, "persona")),
 	}
 	flagSystemPrompt = &cli.StringFlag{
 		Name:  "system",
@@ -143,11 +179,6 @@ var (
 	}
 )
 
-// Common errors
-var (
-	ErrConfigFileNotFound = errors.New("config file not found")
-)
-
 func handleExitError(ctx context.Context, cmd *cli.Command, err error) {
 	logging.LoggerFrom(ctx).
 		With("cmd", cmd.Name).
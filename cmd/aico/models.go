@@ -2,10 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 
+	anthropicopt "github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/urfave/cli/v3"
 
 	"micheam.com/aico/internal/assistant"
@@ -13,6 +14,7 @@ import (
 	"micheam.com/aico/internal/providers/anthropic"
 	"micheam.com/aico/internal/providers/cerebras"
 	"micheam.com/aico/internal/providers/groq"
+	"micheam.com/aico/internal/providers/mock"
 	"micheam.com/aico/internal/providers/openai"
 	"micheam.com/aico/internal/theme"
 )
@@ -28,25 +30,13 @@ var CmdModels = &cli.Command{
 			Name:    "list",
 			Aliases: []string{"ls"},
 			Usage:   "list available models",
-			Flags: []cli.Flag{
-				&cli.BoolFlag{
-					Name:  "json",
-					Usage: "output in JSON format",
-				},
-			},
-			Action: runListModels,
+			Action:  runListModels,
 		},
 		{
 			Name:      "describe",
 			Aliases:   []string{"desc"},
 			Usage:     "show model information",
 			ArgsUsage: "MODEL",
-			Flags: []cli.Flag{
-				&cli.BoolFlag{
-					Name:  "json",
-					Usage: "output in JSON format",
-				},
-			},
 			ShellComplete: func(ctx context.Context, cmd *cli.Command) {
 				// Output both simple and qualified names as completion candidates
 				for _, model := range allAvailableModels() {
@@ -88,9 +78,7 @@ func runListModels(ctx context.Context, cmd *cli.Command) error {
 		})
 	}
 	if cmd.Bool(flagJSON.Name) {
-		encoder := json.NewEncoder(cmd.Root().Writer)
-		encoder.SetIndent("", "  ")
-		return encoder.Encode(models)
+		return writeJSONDocument(cmd.Root().Writer, jsonTypeModels, models)
 	}
 	for _, model := range models {
 		fmt.Fprintln(cmd.Root().Writer, model.String())
@@ -104,6 +92,7 @@ func allAvailableModels() []assistant.ModelDescriptor {
 	models = append(models, openai.AvailableModels()...)
 	models = append(models, groq.AvailableModels()...)
 	models = append(models, cerebras.AvailableModels()...)
+	models = append(models, mock.AvailableModels()...)
 	return models
 }
 
@@ -115,6 +104,25 @@ type listItemView struct {
 	Selected      bool   `json:"selected"`
 }
 
+// modelInfoView is the JSON output of `models describe`.
+type modelInfoView struct {
+	Name            string       `json:"name"`
+	QualifiedName   string       `json:"qualified_name"`
+	Provider        string       `json:"provider"`
+	Description     string       `json:"description"`
+	ContextWindow   int          `json:"context_window"`
+	MaxOutputTokens int          `json:"max_output_tokens"`
+	Pricing         *pricingView `json:"pricing,omitempty"`
+}
+
+// pricingView holds list prices in USD per million tokens.
+type pricingView struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
 func (m *listItemView) String() string {
 	if m.Selected {
 		return fmt.Sprintf("%s *", m.QualifiedName)
@@ -139,20 +147,33 @@ func runDescribeModel(ctx context.Context, cmd *cli.Command) error {
 		if matchesQualified || matchesSimple {
 			qualifiedName := QualifiedName(model.Provider(), model.Name())
 			if cmd.Bool(flagJSON.Name) {
-				info := map[string]string{
-					"name":           model.Name(),
-					"qualified_name": qualifiedName,
-					"provider":       model.Provider(),
-					"description":    model.Description(),
+				info := modelInfoView{
+					Name:            model.Name(),
+					QualifiedName:   qualifiedName,
+					Provider:        model.Provider(),
+					Description:     model.Description(),
+					ContextWindow:   model.ContextWindow(),
+					MaxOutputTokens: model.MaxOutputTokens(),
+				}
+				if p := model.Pricing(); !p.IsZero() {
+					info.Pricing = &pricingView{
+						Input:      p.Input,
+						Output:     p.Output,
+						CacheRead:  p.CacheRead,
+						CacheWrite: p.CacheWrite,
+					}
 				}
-				encoder := json.NewEncoder(cmd.Root().Writer)
-				encoder.SetIndent("", "  ")
-				return encoder.Encode(info)
+				return writeJSONDocument(cmd.Root().Writer, jsonTypeModel, info)
 			}
 
 			fmt.Fprintf(cmd.Root().Writer, "%s %s\n", theme.Bold("Model:"), model.Name())
 			fmt.Fprintf(cmd.Root().Writer, "%s %s\n", theme.Bold("Qualified Name:"), qualifiedName)
 			fmt.Fprintf(cmd.Root().Writer, "%s %s\n", theme.Bold("Provider:"), model.Provider())
+			fmt.Fprintf(cmd.Root().Writer, "%s %d tokens\n", theme.Bold("Context Window:"), model.ContextWindow())
+			fmt.Fprintf(cmd.Root().Writer, "%s %d tokens\n", theme.Bold("Max Output:"), model.MaxOutputTokens())
+			if p := model.Pricing(); !p.IsZero() {
+				fmt.Fprintf(cmd.Root().Writer, "%s %s\n", theme.Bold("Pricing:"), formatPricing(p))
+			}
 			fmt.Fprintf(cmd.Root().Writer, "%s %s\n", theme.Bold("Description:"), model.Description())
 			return nil
 		}
@@ -164,6 +185,18 @@ func runDescribeModel(ctx context.Context, cmd *cli.Command) error {
 // Helpers
 // -----------------------------------------------------------------------------
 
+// formatPricing formats list prices per million tokens.
+func formatPricing(p assistant.Pricing) string {
+	s := fmt.Sprintf("$%.2f input, $%.2f output", p.Input, p.Output)
+	if p.CacheRead != 0 {
+		s += fmt.Sprintf(", $%.3f cache read", p.CacheRead)
+	}
+	if p.CacheWrite != 0 {
+		s += fmt.Sprintf(", $%.2f cache write", p.CacheWrite)
+	}
+	return s + " per MTok"
+}
+
 type model struct {
 	Name        string
 	provider    string
@@ -177,7 +210,11 @@ type model struct {
 //	Currently, only **Anthropic models** are supported as default model.
 //	So, if an API key for Anthropic is not provided, it returns an error.
 func DefaultModel(cmd *cli.Command) (assistant.GenerativeModel, error) {
-	apikey := cmd.String(flagAPIKeyAnthropic.Name)
+	conf, _ := config.Load()
+	apikey, _, err := resolveAPIKey(cmd, conf, anthropic.ProviderName)
+	if err != nil {
+		return nil, err
+	}
 	if apikey == "" {
 		return nil, errors.New(flagAPIKeyAnthropic.Name + " is required for default model, but not provided")
 	}
@@ -204,6 +241,9 @@ func detectModel(cmd *cli.Command) (assistant.GenerativeModel, error) {
 	}
 
 	modelSpec := cmd.String(flagModel.Name)
+	if p, err := conf.ResolvePersona(cmd.String(flagPersona.Name)); modelSpec == "" && err == nil {
+		modelSpec = p.Model
+	}
 	if modelSpec == "" {
 		modelSpec = conf.Model
 	}
@@ -223,19 +263,50 @@ func modelByName(cmd *cli.Command, name string) (assistant.GenerativeModel, erro
 	if !found {
 		return DefaultModel(cmd)
 	}
+	settings := conf.Providers[provider]
+	timeout, err := settings.GetTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("providers.%s.%w", provider, err)
+	}
+	apikey, _, err := resolveAPIKey(cmd, conf, provider)
+	if err != nil {
+		return nil, err
+	}
+	var clientOpts []openai.ClientOption
+	if provider != anthropic.ProviderName {
+		clientOpts = append(clientOpts, openai.WithBaseURL(settings.BaseURL))
+		if timeout > 0 {
+			clientOpts = append(clientOpts, openai.WithHTTPClient(&http.Client{Timeout: timeout}))
+		}
+		if settings.MaxRetries != nil {
+			clientOpts = append(clientOpts, openai.WithMaxRetries(*settings.MaxRetries))
+		}
+	}
 	switch provider {
 	case anthropic.ProviderName:
-		apikey := cmd.String(flagAPIKeyAnthropic.Name)
-		return anthropic.NewGenerativeModel(modelName, apikey)
+		var opts []anthropicopt.RequestOption
+		if settings.BaseURL != "" {
+			opts = append(opts, anthropicopt.WithBaseURL(settings.BaseURL))
+		}
+		if timeout > 0 {
+			opts = append(opts, anthropicopt.WithRequestTimeout(timeout))
+		}
+		if settings.MaxRetries != nil {
+			opts = append(opts, anthropicopt.WithMaxRetries(*settings.MaxRetries))
+		}
+		return anthropic.NewGenerativeModel(modelName, apikey, opts...)
 	case openai.ProviderName:
-		apikey := cmd.String(flagAPIKeyOpenAI.Name)
-		return openai.NewGenerativeModel(modelName, apikey)
+		return openai.NewGenerativeModel(modelName, apikey, clientOpts...)
 	case groq.ProviderName:
-		apikey := cmd.String(flagAPIKeyGroq.Name)
-		return groq.NewGenerativeModel(modelName, apikey)
+		return groq.NewGenerativeModel(modelName, apikey, clientOpts...)
 	case cerebras.ProviderName:
-		apikey := cmd.String(flagAPIKeyCerebras.Name)
-		return cerebras.NewGenerativeModel(modelName, apikey)
+		return cerebras.NewGenerativeModel(modelName, apikey, clientOpts...)
+	case mock.ProviderName:
+		opts, err := mock.OptionsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return mock.NewGenerativeModel(modelName, opts)
 	default:
 		return DefaultModel(cmd)
 	}
@@ -277,6 +348,8 @@ func QualifiedName(provider, modelName string) string {
 //  1. If the spec contains an explicit provider (e.g., "groq:llama-3.3-70b"), use that.
 //  2. If defaultProvider is set and supports the model, use that.
 //  3. Otherwise, search providers in order: anthropic, openai, groq, cerebras.
+//     The mock provider is never searched; its models must be qualified
+//     (e.g., "mock:echo").
 //
 // Returns the provider name, the actual model name, and whether the model was found.
 func detectProviderByModelSpec(spec string, defaultProvider string) (provider string, modelName string, found bool) {
@@ -330,6 +403,9 @@ func validateProviderModel(provider, modelName string) bool {
 	case cerebras.ProviderName:
 		_, found := cerebras.DescribeModel(modelName)
 		return found
+	case mock.ProviderName:
+		_, found := mock.DescribeModel(modelName)
+		return found
 	default:
 		return false
 	}
@@ -117,6 +117,22 @@ func TestDetectProviderByModelSpec(t *testing.T) {
 			wantModelName:   "gpt-4.1",
 			wantFound:       true,
 		},
+		{
+			name:            "explicit provider mock",
+			spec:            "mock:echo",
+			defaultProvider: "",
+			wantProvider:    "mock",
+			wantModelName:   "echo",
+			wantFound:       true,
+		},
+		{
+			name:            "mock models are not auto-detected",
+			spec:            "echo",
+			defaultProvider: "",
+			wantProvider:    "",
+			wantModelName:   "",
+			wantFound:       false,
+		},
 		{
 			name:            "invalid provider in spec",
 			spec:            "invalid:gpt-4.1",
@@ -49,41 +49,140 @@ func (w *ConsoleLineStreamWriter) Close() error {
 	return nil
 }
 
-type JSONLineStreamWriter struct {
-	b        bytes.Buffer
-	mu       sync.Mutex
-	enc      *json.Encoder
-	metaData JSONOutputMetaData
-	usage    *assistant.Usage
+// -----------------------------------------------------------------------------
+// JSON output
+// -----------------------------------------------------------------------------
+
+// jsonSchemaVersion is the version of every JSON document and event written
+// by --json. It is increased on incompatible changes, i.e. when a field is
+// removed, renamed or changes its type. See `aico schema`.
+const jsonSchemaVersion = 1
+
+// jsonDocument wraps the output of non-streaming commands.
+type jsonDocument struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	Data          any    `json:"data"`
+}
+
+// Types of [jsonDocument]
+const (
+	jsonTypeModels     = "models"
+	jsonTypeModel      = "model"
+	jsonTypePersonas   = "personas"
+	jsonTypePersona    = "persona"
+	jsonTypePrompts    = "prompts"
+	jsonTypeSessions   = "sessions"
+	jsonTypeSession    = "session"
+	jsonTypeUsage      = "usage_report"
+	jsonTypeCacheStats = "cache_stats"
+	jsonTypeConfig     = "config"
+	jsonTypeChecks     = "checks"
+)
+
+// writeJSONDocument writes data as an indented document of the given type.
+func writeJSONDocument(w io.Writer, typ string, data any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonDocument{
+		SchemaVersion: jsonSchemaVersion,
+		Type:          typ,
+		Data:          data,
+	})
+}
+
+// jsonEvent is a line of a streamed reply.
+//
+// A successful reply is a "start" event, any number of "delta", "thinking"
+// and "tool_call" events, at most one "usage" event and a "stop" event. A
+// failed reply ends with an "error" event instead of "stop".
+type jsonEvent struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+
+	// start, stop
+	Session string `json:"session,omitempty"`
+	Model   string `json:"model,omitempty"`
+
+	// delta, thinking
+	Text string `json:"text,omitempty"`
+
+	// tool_call
+	ToolCall *assistant.ToolCallContent `json:"tool_call,omitempty"`
+
+	// usage
+	Usage *usageInfo `json:"usage,omitempty"`
+
+	// error
+	Error string `json:"error,omitempty"`
 }
-type JSONOutputMetaData struct {
-	Session string
-	Model   string
+
+// Types of [jsonEvent]
+const (
+	jsonEventStart    = "start"
+	jsonEventDelta    = "delta"
+	jsonEventThinking = "thinking"
+	jsonEventToolCall = "tool_call"
+	jsonEventUsage    = "usage"
+	jsonEventStop     = "stop"
+	jsonEventError    = "error"
+)
+
+// eventWriter is implemented by reply writers that report more than the
+// text of a reply.
+type eventWriter interface {
+	WriteEvent(ev jsonEvent) error
+}
+
+// writeEvent writes ev if w is an eventWriter, and does nothing otherwise.
+func writeEvent(w io.Writer, ev jsonEvent) error {
+	if ew, ok := w.(eventWriter); ok {
+		return ew.WriteEvent(ev)
+	}
+	return nil
+}
+
+// JSONEventWriter writes a reply as JSON events, one per line. Each write
+// becomes a "delta" event.
+type JSONEventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+var _ eventWriter = (*JSONEventWriter)(nil)
+
+func NewJSONEventWriter(w io.Writer) *JSONEventWriter {
+	return &JSONEventWriter{enc: json.NewEncoder(w)}
 }
 
-// SetUsage attaches usage info to be emitted with the next flushed line.
-// Intended to be called once, after the generation stream has completed.
-func (w *JSONLineStreamWriter) SetUsage(usage *assistant.Usage) {
+func (w *JSONEventWriter) WriteEvent(ev jsonEvent) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.usage = usage
+	ev.SchemaVersion = jsonSchemaVersion
+	return w.enc.Encode(ev)
 }
 
-type jsonlModel struct {
-	Content string     `json:"content"`
-	Session string     `json:"session"`
-	Model   string     `json:"model"`
-	Usage   *usageInfo `json:"usage,omitempty"`
+func (w *JSONEventWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.WriteEvent(jsonEvent{Type: jsonEventDelta, Text: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
+func (w *JSONEventWriter) Close() error { return nil }
+
 // usageInfo is the JSON-facing shape of assistant.Usage, adding a
 // human-readable cache hit rate alongside the raw token counts.
 type usageInfo struct {
-	InputTokens       int    `json:"input_tokens"`
-	OutputTokens      int    `json:"output_tokens"`
-	CachedInputTokens int    `json:"cached_input_tokens"`
-	CacheWriteTokens  int    `json:"cache_write_tokens"`
-	CacheHitRate      string `json:"cache_hit_rate"`
+	InputTokens       int     `json:"input_tokens"`
+	OutputTokens      int     `json:"output_tokens"`
+	CachedInputTokens int     `json:"cached_input_tokens"`
+	CacheWriteTokens  int     `json:"cache_write_tokens"`
+	CacheHitRate      string  `json:"cache_hit_rate"`
+	Cost              float64 `json:"cost"`
 }
 
 func toUsageInfo(u *assistant.Usage) *usageInfo {
@@ -96,45 +195,6 @@ func toUsageInfo(u *assistant.Usage) *usageInfo {
 		CachedInputTokens: u.CachedInputTokens,
 		CacheWriteTokens:  u.CacheWriteTokens,
 		CacheHitRate:      fmt.Sprintf("%.1f%%", u.CacheHitRate()),
+		Cost:              u.Cost,
 	}
 }
-
-func (w *JSONLineStreamWriter) Write(p []byte) (n int, err error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	n, err = w.b.Write(p)
-	if err != nil {
-		return n, err
-	}
-	for {
-		line, err := w.b.ReadBytes('\n')
-		if err != nil {
-			// 改行がない場合は、バッファに残しておきます。
-			w.b.Write(line)
-			break
-		}
-		if err := w.enc.Encode(jsonlModel{
-			Content: string(line),
-			Session: w.metaData.Session,
-			Model:   w.metaData.Model,
-		}); err != nil {
-			return n, err
-		}
-	}
-	return n, nil
-}
-
-func (w *JSONLineStreamWriter) Close() error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	defer w.b.Reset()
-	if w.b.Len() == 0 && w.usage == nil {
-		return nil
-	}
-	return w.enc.Encode(jsonlModel{
-		Content: w.b.String(),
-		Session: w.metaData.Session,
-		Model:   w.metaData.Model,
-		Usage:   toUsageInfo(w.usage),
-	})
-}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// schema is the subset of a JSON Schema checked by the tests.
+type schema struct {
+	Required   []string `json:"required"`
+	Properties map[string]struct {
+		Const any   `json:"const"`
+		Enum  []any `json:"enum"`
+	} `json:"properties"`
+	OneOf []schema `json:"oneOf"`
+}
+
+func loadSchema(t *testing.T, name string) schema {
+	t.Helper()
+	b, err := fs.ReadFile(schemaFS, schemaPath(name))
+	require.NoError(t, err)
+	var s schema
+	require.NoError(t, json.Unmarshal(b, &s))
+	return s
+}
+
+func requireKeys(t *testing.T, s schema, obj map[string]any) {
+	t.Helper()
+	for _, key := range s.Required {
+		require.Contains(t, obj, key)
+	}
+}
+
+func TestJSONEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONEventWriter(&buf)
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventStart, Session: "s1", Model: "mock:echo"}))
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventThinking, Text: "hmm"}))
+	_, err := w.Write([]byte("Hello"))
+	require.NoError(t, err)
+	_, err = w.Write(nil)
+	require.NoError(t, err)
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventToolCall, ToolCall: &assistant.ToolCallContent{ID: "1", Name: "ls"}}))
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventUsage, Usage: toUsageInfo(&assistant.Usage{InputTokens: 3})}))
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventStop, Session: "s1", Model: "mock:echo"}))
+	require.NoError(t, writeEvent(w, jsonEvent{Type: jsonEventError, Error: "boom"}))
+	require.NoError(t, w.Close())
+
+	events := loadSchema(t, "events")
+	branches := map[any]schema{}
+	for _, b := range events.OneOf {
+		branches[b.Properties["type"].Const] = b
+	}
+	require.Len(t, branches, len(events.Properties["type"].Enum))
+
+	var types []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var ev map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+		requireKeys(t, events, ev)
+		require.EqualValues(t, jsonSchemaVersion, ev["schema_version"])
+		branch, ok := branches[ev["type"]]
+		require.True(t, ok, "unknown event type %v", ev["type"])
+		requireKeys(t, branch, ev)
+		types = append(types, ev["type"].(string))
+	}
+	require.Equal(t, []string{"start", "thinking", "delta", "tool_call", "usage", "stop", "error"}, types)
+}
+
+func TestWriteJSONDocument(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeJSONDocument(&buf, jsonTypeSessions, []sessionListItemView{
+		newSessionListItemView(assistant.SessionSummary{ID: "s1"}),
+	}))
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	s := loadSchema(t, jsonTypeSessions)
+	requireKeys(t, s, doc)
+	require.Equal(t, jsonTypeSessions, doc["type"])
+	require.Equal(t, s.Properties["type"].Const, doc["type"])
+	require.Equal(t, []any{}, doc["data"].([]any)[0].(map[string]any)["tags"])
+}
+
+func TestSchemas(t *testing.T) {
+	names, err := schemaNames()
+	require.NoError(t, err)
+	for _, typ := range []string{
+		jsonTypeModels, jsonTypeModel, jsonTypePersonas, jsonTypePersona, jsonTypePrompts, jsonTypeSessions,
+		jsonTypeSession, jsonTypeUsage, jsonTypeCacheStats, jsonTypeConfig,
+		jsonTypeChecks,
+	} {
+		require.Contains(t, names, typ)
+		require.Equal(t, typ, loadSchema(t, typ).Properties["type"].Const)
+	}
+	require.Contains(t, names, "events")
+}
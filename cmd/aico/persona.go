@@ -1,19 +1,26 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 
 	"github.com/urfave/cli/v3"
 
 	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/prompt"
 )
 
 var CmdPersona = &cli.Command{
 	Name:  "persona",
 	Usage: "manage personas",
+	Description: "Personas are defined as [persona.NAME] tables in config.toml, or as\n" +
+		"Markdown files NAME.md in the personas directory next to it. The commands\n" +
+		"below that modify personas work on those files.",
 
 	// default action: list personas
 	Action: runListPersonas,
@@ -24,6 +31,62 @@ var CmdPersona = &cli.Command{
 			Usage:   "list available personas",
 			Action:  runListPersonas,
 		},
+		{
+			Name:      "show",
+			Usage:     "show the description and system message of a persona",
+			ArgsUsage: "NAME",
+			Action:    runShowPersona,
+		},
+		{
+			Name:      "render",
+			Usage:     "print the system message of a persona as a new session would get it",
+			ArgsUsage: "NAME",
+			Description: "Expands the template of the message, after prepending the messages of\n" +
+				"the personas it extends. Pass template variables with --var NAME=VALUE.",
+			Action: runRenderPersona,
+		},
+		{
+			Name:      "add",
+			Usage:     "create a persona file",
+			ArgsUsage: "NAME",
+			Description: "Without --message, the new persona file is opened in $EDITOR first.\n" +
+				"Set --message to @file to read the system message from a file.",
+			Action: runAddPersona,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "description",
+					Aliases: []string{"d"},
+					Usage:   "one-line description of the persona",
+				},
+				&cli.StringFlag{
+					Name:    "message",
+					Aliases: []string{"m"},
+					Usage:   "system message, or @file path",
+				},
+			},
+		},
+		{
+			Name:      "edit",
+			Usage:     "edit a persona file in $EDITOR",
+			ArgsUsage: "NAME",
+			Action:    runEditPersona,
+		},
+		{
+			Name:      "rm",
+			Aliases:   []string{"remove"},
+			Usage:     "delete a persona file",
+			ArgsUsage: "NAME",
+			Action:    runRemovePersona,
+		},
+		{
+			Name:      "copy",
+			Aliases:   []string{"cp"},
+			Usage:     "copy a persona into a new persona file",
+			ArgsUsage: "SOURCE NEW",
+			Description: "SOURCE may also be a persona of config.toml, which makes copy the way\n" +
+				"to move it into a file.",
+			Action: runCopyPersona,
+		},
 	},
 }
 
@@ -54,9 +117,7 @@ func runListPersonas(ctx context.Context, cmd *cli.Command) error {
 	})
 
 	if cmd.Bool(flagJSON.Name) {
-		encoder := json.NewEncoder(cmd.Root().Writer)
-		encoder.SetIndent("", "  ")
-		return encoder.Encode(personas)
+		return writeJSONDocument(cmd.Root().Writer, jsonTypePersonas, personas)
 	}
 
 	for _, p := range personas {
@@ -65,6 +126,238 @@ func runListPersonas(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+func runShowPersona(ctx context.Context, cmd *cli.Command) error {
+	conf, name, err := loadPersonaArg(cmd)
+	if err != nil {
+		return err
+	}
+	p := conf.PersonaMap[name]
+	view := personaView{
+		Name:        name,
+		Description: p.Description,
+		Message:     p.Message,
+		Cache:       p.Cache,
+		Extends:     p.Extends,
+		Model:       p.Model,
+		Temperature: p.Temperature,
+		MaxTokens:   p.MaxTokens,
+		Effort:      p.Effort,
+		Context:     p.Context,
+		Path:        p.Path(),
+	}
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Root().Writer, jsonTypePersona, view)
+	}
+
+	w := cmd.Root().Writer
+	fmt.Fprintf(w, "Name: %s\n", view.Name)
+	if view.Description != "" {
+		fmt.Fprintf(w, "Description: %s\n", view.Description)
+	}
+	if view.Extends != "" {
+		fmt.Fprintf(w, "Extends: %s\n", view.Extends)
+	}
+	if view.Model != "" {
+		fmt.Fprintf(w, "Model: %s\n", view.Model)
+	}
+	if view.Temperature != nil {
+		fmt.Fprintf(w, "Temperature: %g\n", *view.Temperature)
+	}
+	if view.MaxTokens != 0 {
+		fmt.Fprintf(w, "Max tokens: %d\n", view.MaxTokens)
+	}
+	if view.Effort != "" {
+		fmt.Fprintf(w, "Effort: %s\n", view.Effort)
+	}
+	if view.Cache != "" {
+		fmt.Fprintf(w, "Cache: %s\n", view.Cache)
+	}
+	if len(view.Context) > 0 {
+		fmt.Fprintf(w, "Context: %s\n", strings.Join(view.Context, ", "))
+	}
+	fmt.Fprintf(w, "Defined in: %s\n", personaSource(conf, p))
+	fmt.Fprintf(w, "\n%s\n", view.Message)
+	return nil
+}
+
+func runRenderPersona(ctx context.Context, cmd *cli.Command) error {
+	conf, name, err := loadPersonaArg(cmd)
+	if err != nil {
+		return err
+	}
+	vars, err := prompt.ParseVars(cmd.StringSlice(flagVar.Name))
+	if err != nil {
+		return err
+	}
+	p, err := renderPersona(conf, name, vars)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.Root().Writer, p.Message)
+	return err
+}
+
+func runAddPersona(ctx context.Context, cmd *cli.Command) error {
+	name := cmd.Args().First()
+	if name == "" {
+		return errors.New("persona name is required")
+	}
+	if err := config.ValidatePersonaName(name); err != nil {
+		return err
+	}
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if _, ok := conf.PersonaMap[name]; ok {
+		return fmt.Errorf("persona %q already exists", name)
+	}
+
+	p := config.Personality{Description: cmd.String("description")}
+	if msg := cmd.String("message"); msg != "" {
+		p.Message, err = readFileRef(msg)
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+	} else {
+		p.Message = "You are ..."
+		template, err := config.FormatPersona(p)
+		if err != nil {
+			return err
+		}
+		edited, err := editText(string(template), "aico-persona-*"+config.PersonaFileExt)
+		if err != nil {
+			return err
+		}
+		parsed, err := config.ParsePersona([]byte(edited))
+		if err != nil {
+			return err
+		}
+		if parsed.Message == "" || parsed.Message == p.Message {
+			return errors.New("empty system message, persona not created")
+		}
+		p = *parsed
+	}
+
+	path, err := conf.WritePersona(name, p)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Created persona %s at %s\n", name, path)
+	return nil
+}
+
+func runEditPersona(ctx context.Context, cmd *cli.Command) error {
+	conf, name, err := loadPersonaArg(cmd)
+	if err != nil {
+		return err
+	}
+	path := conf.PersonaMap[name].Path()
+	if path == "" {
+		return fmt.Errorf("persona %q is defined in %s: edit it with `aico config edit`, "+
+			"or move it into a file with `aico persona copy %s NEW`", name, conf.Location(), name)
+	}
+	if err := runEditor(path); err != nil {
+		return fmt.Errorf("edit persona: %w", err)
+	}
+	if _, err := config.ReadPersonaFile(path); err != nil {
+		return fmt.Errorf("the persona file is now invalid: %w", err)
+	}
+	return nil
+}
+
+func runRemovePersona(ctx context.Context, cmd *cli.Command) error {
+	conf, name, err := loadPersonaArg(cmd)
+	if err != nil {
+		return err
+	}
+	path := conf.PersonaMap[name].Path()
+	if err := conf.RemovePersona(name); err != nil {
+		if errors.Is(err, config.ErrPersonaNotInFile) {
+			return fmt.Errorf("persona %q is defined in %s: remove it with `aico config edit`", name, conf.Location())
+		}
+		return err
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Removed persona %s (%s)\n", name, path)
+	return nil
+}
+
+func runCopyPersona(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return errors.New("usage: aico persona copy SOURCE NEW")
+	}
+	conf, src, err := loadPersonaArg(cmd)
+	if err != nil {
+		return err
+	}
+	dst := cmd.Args().Get(1)
+	path, err := conf.WritePersona(dst, conf.PersonaMap[src])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.Root().Writer, "Copied persona %s to %s at %s\n", src, dst, path)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// loadPersonaArg loads the config and returns it with the name of the
+// persona given as the first argument, which must exist.
+func loadPersonaArg(cmd *cli.Command) (*config.Config, string, error) {
+	name := cmd.Args().First()
+	if name == "" {
+		return nil, "", errors.New("persona name is required")
+	}
+	conf, err := config.Load()
+	if err != nil {
+		return nil, "", fmt.Errorf("load config: %w", err)
+	}
+	if _, ok := conf.PersonaMap[name]; !ok {
+		return nil, "", fmt.Errorf("persona %q not found", name)
+	}
+	return conf, name, nil
+}
+
+// renderPersona resolves the persona of name and renders its message with
+// vars. Include paths are relative to the personas directory.
+func renderPersona(conf *config.Config, name string, vars map[string]string) (*config.Personality, error) {
+	p, err := conf.ResolvePersona(name)
+	if errors.Is(err, config.ErrPersonaNotFound) {
+		return nil, fmt.Errorf("persona %q not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Message, err = prompt.Render(p.Message, prompt.Options{Dir: cmp.Or(p.Dir(), conf.PersonaDir()), Vars: vars, Sandbox: p.Sandbox()})
+	if err != nil {
+		return nil, fmt.Errorf("render persona %q: %w", name, err)
+	}
+	return p, nil
+}
+
+// readFileRef returns s, or the contents of the file if s is "@path".
+func readFileRef(s string) (string, error) {
+	path, ok := strings.CutPrefix(s, "@")
+	if !ok {
+		return s, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	return string(b), nil
+}
+
+// personaSource describes where p is defined.
+func personaSource(conf *config.Config, p config.Personality) string {
+	if p.Path() != "" {
+		return p.Path()
+	}
+	return conf.Location()
+}
+
 type personaListItemView struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -77,3 +370,20 @@ func (p *personaListItemView) String() string {
 	}
 	return p.Name
 }
+
+// personaView is the JSON output shape of `persona show`.
+type personaView struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Message     string   `json:"message"`
+	Cache       string   `json:"cache,omitempty"`
+	Extends     string   `json:"extends,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Effort      string   `json:"effort,omitempty"`
+	Context     []string `json:"context,omitempty"`
+
+	// Path is the persona file, empty for personas of config.toml.
+	Path string `json:"path"`
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/jsonrpc"
+	"micheam.com/aico/internal/logging"
+)
+
+// rpcProtocolVersion is bumped on incompatible changes of the RPC methods.
+const rpcProtocolVersion = 1
+
+// rpcProgressMethod is the notification sent for each delta of a reply.
+const rpcProgressMethod = "$/progress"
+
+var CmdRPC = &cli.Command{
+	Name:  "rpc",
+	Usage: "Speak JSON-RPC 2.0 over stdio, for editor integrations",
+	Description: "Reads requests from stdin and writes responses and notifications to stdout,\n" +
+		"one JSON message per line, until stdin is closed. See the README for the\n" +
+		"methods.",
+	Action: runRPC,
+}
+
+func runRPC(ctx context.Context, cmd *cli.Command) error {
+	logger, cleanup, err := initializeLogger(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	ctx = logging.ContextWith(ctx, logger)
+
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer store.Close()
+
+	srv := jsonrpc.NewServer()
+	h := &rpcHandler{cmd: cmd, conf: conf, store: store, srv: srv}
+	srv.Handle("initialize", h.initialize)
+	srv.Handle("models/list", h.listModels)
+	srv.Handle("personas/list", h.listPersonas)
+	srv.Handle("sessions/list", h.listSessions)
+	srv.Handle("sessions/get", h.getSession)
+	srv.Handle("generate", h.generate)
+
+	logger.Info("rpc server started")
+	defer logger.Info("rpc server stopped")
+	return srv.Serve(ctx, cmd.Root().Reader, cmd.Root().Writer)
+}
+
+type rpcHandler struct {
+	cmd   *cli.Command
+	conf  *config.Config
+	store assistant.SessionStore
+	srv   *jsonrpc.Server
+
+	// locks serializes generations per session, so that replies are
+	// appended in order.
+	locks sync.Map // session ID -> *sync.Mutex
+}
+
+func (h *rpcHandler) lock(sessionID string) func() {
+	mu, _ := h.locks.LoadOrStore(sessionID, new(sync.Mutex))
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// -----------------------------------------------------------------------------
+// Methods
+// -----------------------------------------------------------------------------
+
+func (h *rpcHandler) initialize(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{
+		"name":             appname,
+		"version":          version,
+		"protocol_version": rpcProtocolVersion,
+	}, nil
+}
+
+func (h *rpcHandler) listModels(ctx context.Context, _ json.RawMessage) (any, error) {
+	models := []listItemView{}
+	for _, m := range allAvailableModels() {
+		qualified := QualifiedName(m.Provider(), m.Name())
+		models = append(models, listItemView{
+			Name:          m.Name(),
+			QualifiedName: qualified,
+			Provider:      m.Provider(),
+			Description:   m.Description(),
+			Selected:      h.conf.Model == m.Name() || h.conf.Model == qualified,
+		})
+	}
+	return models, nil
+}
+
+func (h *rpcHandler) listPersonas(ctx context.Context, _ json.RawMessage) (any, error) {
+	current := h.cmd.String(flagPersona.Name)
+	personas := []personaListItemView{}
+	for name, p := range h.conf.PersonaMap {
+		personas = append(personas, personaListItemView{
+			Name:        name,
+			Description: p.Description,
+			Selected:    name == current,
+		})
+	}
+	sort.Slice(personas, func(i, j int) bool {
+		return personas[i].Name < personas[j].Name
+	})
+	return personas, nil
+}
+
+func (h *rpcHandler) listSessions(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Limit int    `json:"limit"`
+		Tag   string `json:"tag"`
+	}
+	if err := jsonrpc.Bind(params, &p); err != nil {
+		return nil, err
+	}
+	summaries, err := h.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	if p.Tag != "" {
+		summaries = slices.DeleteFunc(summaries, func(s assistant.SessionSummary) bool {
+			return !slices.Contains(s.Tags, p.Tag)
+		})
+	}
+	if p.Limit > 0 && p.Limit < len(summaries) {
+		summaries = summaries[:p.Limit]
+	}
+	sessions := []sessionListItemView{}
+	for _, s := range summaries {
+		sessions = append(sessions, newSessionListItemView(s))
+	}
+	return sessions, nil
+}
+
+func (h *rpcHandler) getSession(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Session string `json:"session"`
+	}
+	if err := jsonrpc.Bind(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Session == "" {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "session is required")
+	}
+	return h.store.Load(ctx, p.Session)
+}
+
+type rpcGenerateParams struct {
+	Prompt string `json:"prompt"`
+
+	// Session continues an existing session. If empty, a new session is
+	// started with Model, Persona and Context.
+	Session string   `json:"session"`
+	Model   string   `json:"model"`
+	Persona string   `json:"persona"`
+	Context []string `json:"context"`
+
+	// Vars are the variables of the persona template, as with --var.
+	Vars map[string]string `json:"vars"`
+
+	// Source is the primary subject of the prompt, as with --source.
+	Source string `json:"source"`
+}
+
+type rpcGenerateResult struct {
+	Session string     `json:"session"`
+	Model   string     `json:"model"`
+	Content string     `json:"content"`
+	Usage   *usageInfo `json:"usage,omitempty"`
+}
+
+type rpcProgress struct {
+	ID      json.RawMessage `json:"id"` // of the generate request
+	Session string          `json:"session"`
+	Delta   string          `json:"delta"`
+}
+
+func (h *rpcHandler) generate(ctx context.Context, params json.RawMessage) (any, error) {
+	var p rpcGenerateParams
+	if err := jsonrpc.Bind(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Prompt == "" && p.Source == "" {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "prompt or source is required")
+	}
+
+	var sess *assistant.Session
+	if p.Session != "" {
+		unlock := h.lock(p.Session)
+		defer unlock()
+		loaded, err := h.store.Load(ctx, p.Session)
+		if err != nil {
+			return nil, fmt.Errorf("load session: %w", err)
+		}
+		sess = loaded
+	} else {
+		created, err := h.newSession(p)
+		if err != nil {
+			return nil, err
+		}
+		sess = created
+	}
+	logger := logging.LoggerFrom(ctx).With("session_id", sess.ID)
+	ctx = logging.ContextWith(ctx, logger)
+
+	contents := []assistant.MessageContent{}
+	if p.Source != "" {
+		source, err := resolveSource(p.Source)
+		if err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "source: %v", err)
+		}
+		contents = append(contents, assistant.NewTextContent(source))
+	}
+	if p.Prompt != "" {
+		contents = append(contents, assistant.NewTextContent(p.Prompt))
+	}
+	sess.AddMessage(assistant.NewUserMessage(contents...))
+
+	w := &rpcProgressWriter{srv: h.srv, id: jsonrpc.RequestID(ctx), session: sess.ID}
+	if err := generateReply(ctx, h.cmd, h.conf, sess, w); err != nil {
+		return nil, err
+	}
+	result := rpcGenerateResult{
+		Session: sess.ID,
+		Model:   sess.Model,
+		Content: w.acc.String(),
+	}
+	if msgs := sess.GetMessages(); len(msgs) > 0 {
+		if reply, ok := msgs[len(msgs)-1].(*assistant.AssistantMessage); ok {
+			result.Usage = toUsageInfo(reply.Usage)
+		}
+	}
+	return result, nil
+}
+
+func (h *rpcHandler) newSession(p rpcGenerateParams) (*assistant.Session, error) {
+	persona := cmp.Or(p.Persona, flagPersona.Value)
+	spec := p.Model
+	if resolved, err := h.conf.ResolvePersona(persona); spec == "" && err == nil {
+		spec = resolved.Model
+	}
+	spec = cmp.Or(spec, h.conf.Model)
+	if p.Model != "" {
+		if _, _, found := detectProviderByModelSpec(p.Model, h.conf.DefaultProvider); !found {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "unknown model %q", p.Model)
+		}
+	}
+	model, err := modelByName(h.cmd, spec)
+	if err != nil {
+		return nil, fmt.Errorf("model by name: %w", err)
+	}
+	sess, err := newSession(h.store, h.conf, model, persona, p.Vars, p.Context)
+	if err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+	return sess, nil
+}
+
+// rpcProgressWriter sends each write as a progress notification.
+type rpcProgressWriter struct {
+	srv     *jsonrpc.Server
+	id      json.RawMessage
+	session string
+	acc     strings.Builder
+}
+
+func (w *rpcProgressWriter) Write(p []byte) (int, error) {
+	w.acc.Write(p)
+	err := w.srv.Notify(rpcProgressMethod, rpcProgress{ID: w.id, Session: w.session, Delta: string(p)})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *rpcProgressWriter) Close() error { return nil }
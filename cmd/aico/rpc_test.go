@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/config"
+)
+
+func TestRPCCommand_ListPersonas(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, config.ConfigFileName)
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+model = "mock:echo"
+logfile = "aico.log"
+session_dir = "sessions"
+
+[persona.default]
+message = "Hi."
+
+[persona.reviewer]
+description = "Reviews code"
+message = "Review."
+`), 0644))
+	t.Setenv(config.EnvKeyConfigPath, configPath)
+	t.Setenv("AICO_PERSONA", "reviewer")
+
+	var buf bytes.Buffer
+	app := &cli.Command{
+		Reader:   strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "personas/list"}` + "\n"),
+		Writer:   &buf,
+		Flags:    []cli.Flag{flagDebug, flagPersona},
+		Commands: []*cli.Command{CmdRPC},
+	}
+	require.NoError(t, app.Run(context.Background(), []string{"aico", "rpc"}))
+
+	var resp struct {
+		ID     int                   `json:"id"`
+		Result []personaListItemView `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &resp))
+	require.Equal(t, 1, resp.ID)
+	require.Len(t, resp.Result, 2)
+	require.Equal(t, "default", resp.Result[0].Name)
+	require.False(t, resp.Result[0].Selected)
+	require.Equal(t, "reviewer", resp.Result[1].Name)
+	require.True(t, resp.Result[1].Selected)
+}
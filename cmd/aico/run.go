@@ -0,0 +1,236 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/prompt"
+)
+
+// CmdRun runs a prompt template. Templates are looked up when the command
+// runs, so that --profile applies to them, and each gets a command of its
+// own so that its arguments get flags and help.
+var CmdRun = &cli.Command{
+	Name:      "run",
+	Usage:     "generate with a prompt template",
+	ArgsUsage: "TEMPLATE [ARGS...]",
+	Description: "Prompt templates are defined as [prompt.NAME] tables in config.toml, or\n" +
+		"as Markdown files NAME.md in the prompts directory next to it. List them\n" +
+		"with `aico prompts ls`, and show the arguments of one with\n" +
+		"`aico run TEMPLATE --help`.",
+	// The flags after TEMPLATE are those of the template.
+	SkipFlagParsing: true,
+	ShellComplete:   completePromptNames,
+	Action:          runTemplate,
+}
+
+func newCmdRunTemplate(conf *config.Config, name string, p config.Prompt) *cli.Command {
+	var flags []cli.Flag
+	for _, a := range p.Flags {
+		flags = append(flags, &cli.StringFlag{
+			Name:      a.Name,
+			Usage:     a.Description,
+			Value:     a.Default,
+			Required:  a.Required,
+			TakesFile: a.File,
+		})
+	}
+	return &cli.Command{
+		Name:      name,
+		Usage:     p.Description,
+		ArgsUsage: promptArgsUsage(p),
+		Flags:     flags,
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			return runPrompt(ctx, cmd, conf, name, p)
+		},
+	}
+}
+
+var CmdPrompts = &cli.Command{
+	Name:  "prompts",
+	Usage: "list prompt templates",
+
+	// default action: list templates
+	Action: runListPrompts,
+	Commands: []*cli.Command{
+		{
+			Name:    "list",
+			Aliases: []string{"ls"},
+			Usage:   "list available prompt templates",
+			Action:  runListPrompts,
+		},
+	},
+}
+
+// -----------------------------------------------------------------------------
+// Actions
+// -----------------------------------------------------------------------------
+
+func runTemplate(ctx context.Context, cmd *cli.Command) error {
+	name := cmd.Args().First()
+	if name == "" || name == "help" || strings.HasPrefix(name, "-") {
+		return cli.ShowSubcommandHelp(cmd)
+	}
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	p, ok := conf.PromptMap[name]
+	if !ok {
+		return fmt.Errorf("prompt template %q not found; see `aico prompts ls`", name)
+	}
+	// Run the template as a subcommand of cmd, which takes it from ctx.
+	return newCmdRunTemplate(conf, name, p).Run(ctx, cmd.Args().Slice())
+}
+
+func runPrompt(ctx context.Context, cmd *cli.Command, conf *config.Config, name string, p config.Prompt) error {
+	vars, err := prompt.ParseVars(cmd.StringSlice(flagVar.Name))
+	if err != nil {
+		return err
+	}
+	args := cmd.Args().Slice()
+	if len(args) > len(p.Args) {
+		return fmt.Errorf("%s: too many arguments: want %s", name, promptArgsUsage(p))
+	}
+	// Defaults come from the config, and are confined to the sandbox of
+	// the template; the values given are up to the user.
+	for i, a := range p.Args {
+		value, sandbox := a.Default, p.Sandbox()
+		if i < len(args) {
+			value, sandbox = args[i], ""
+		} else if a.Required {
+			return fmt.Errorf("%s: missing argument %s", name, strings.ToUpper(a.Name))
+		}
+		if vars[a.Name], err = promptArgValue(a, value, sandbox); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.Flags {
+		sandbox := p.Sandbox()
+		if cmd.IsSet(a.Name) {
+			sandbox = ""
+		}
+		if vars[a.Name], err = promptArgValue(a, cmd.String(a.Name), sandbox); err != nil {
+			return err
+		}
+	}
+	text, err := prompt.Render(p.Template, prompt.Options{Dir: cmp.Or(p.Dir(), conf.PromptDir()), Vars: vars, Sandbox: p.Sandbox()})
+	if err != nil {
+		return fmt.Errorf("render prompt %q: %w", name, err)
+	}
+
+	source, err := detectSource(cmd.String(flagSource.Name), os.Stdin)
+	if err != nil {
+		return err
+	}
+	if source == "" && p.Stdin == config.StdinRequired {
+		return fmt.Errorf("%s: input is required on stdin or with --source", name)
+	}
+
+	root := cmd.Root()
+	if p.Persona != "" && !root.IsSet(flagPersona.Name) {
+		if err := root.Set(flagPersona.Name, p.Persona); err != nil {
+			return fmt.Errorf("set persona flag: %w", err)
+		}
+	}
+	if p.Model != "" && !root.IsSet(flagModel.Name) {
+		if err := root.Set(flagModel.Name, p.Model); err != nil {
+			return fmt.Errorf("set model flag: %w", err)
+		}
+	}
+	return generateWithSource(ctx, cmd, source, text)
+}
+
+func runListPrompts(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	prompts := []promptListItemView{}
+	for _, name := range slices.Sorted(maps.Keys(conf.PromptMap)) {
+		p := conf.PromptMap[name]
+		prompts = append(prompts, promptListItemView{
+			Name:        name,
+			Description: p.Description,
+			Usage:       strings.TrimSpace(name + " " + promptArgsUsage(p)),
+			Path:        p.Path(),
+		})
+	}
+
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Root().Writer, jsonTypePrompts, prompts)
+	}
+
+	w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+	for _, p := range prompts {
+		fmt.Fprintf(w, "%s\t%s\n", p.Usage, p.Description)
+	}
+	return w.Flush()
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// completePromptNames prints the names of the prompt templates for shell
+// completion.
+func completePromptNames(ctx context.Context, cmd *cli.Command) {
+	conf, err := config.Load()
+	if err != nil {
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.PromptMap)) {
+		fmt.Fprintln(cmd.Root().Writer, name)
+	}
+}
+
+// promptArgValue returns the template value of argument a given as s: the
+// contents of the file for file arguments, else s itself. The file must be
+// inside of sandbox unless that is empty (see [prompt.ReadFile]).
+func promptArgValue(a config.PromptArg, s, sandbox string) (string, error) {
+	if !a.File || s == "" {
+		return s, nil
+	}
+	path := strings.TrimPrefix(s, "@")
+	b, err := prompt.ReadFile(sandbox, path)
+	if err != nil {
+		return "", fmt.Errorf("argument %s: %w", a.Name, err)
+	}
+	return string(b), nil
+}
+
+// promptArgsUsage describes the positional arguments of p, e.g.
+// "FILE [LANG]".
+func promptArgsUsage(p config.Prompt) string {
+	var usage []string
+	for _, a := range p.Args {
+		if a.Required {
+			usage = append(usage, strings.ToUpper(a.Name))
+		} else {
+			usage = append(usage, "["+strings.ToUpper(a.Name)+"]")
+		}
+	}
+	return strings.Join(usage, " ")
+}
+
+// promptListItemView is the JSON output shape of an item of `prompts ls`.
+type promptListItemView struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Usage shows the positional arguments, e.g. "explain FILE".
+	Usage string `json:"usage"`
+
+	// Path is the prompt file, empty for templates of config.toml.
+	Path string `json:"path"`
+}
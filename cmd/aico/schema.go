@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// schemaFS holds the JSON Schemas of the --json output, one directory per
+// schema version.
+//
+//go:embed schema
+var schemaFS embed.FS
+
+const schemaSuffix = ".schema.json"
+
+var CmdSchema = &cli.Command{
+	Name:      "schema",
+	Usage:     "Print the JSON Schemas of the --json output",
+	ArgsUsage: "[NAME]",
+	Description: "Without NAME, lists the available schemas. \"events\" describes the lines\n" +
+		"streamed by a reply; the others describe the document of the command of\n" +
+		"the same type, e.g. \"sessions\" for `aico session list --json`.",
+	Action: runSchema,
+}
+
+func runSchema(ctx context.Context, cmd *cli.Command) error {
+	w := cmd.Root().Writer
+	name := cmd.Args().First()
+	if name == "" {
+		names, err := schemaNames()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			fmt.Fprintln(w, n)
+		}
+		return nil
+	}
+	b, err := fs.ReadFile(schemaFS, schemaPath(name))
+	if err != nil {
+		return fmt.Errorf("schema %q not found, see `aico schema` for the list", name)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// schemaNames returns the names of the schemas of the current version.
+func schemaNames() ([]string, error) {
+	entries, err := fs.ReadDir(schemaFS, path.Dir(schemaPath("")))
+	if err != nil {
+		return nil, fmt.Errorf("read schemas: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), schemaSuffix); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func schemaPath(name string) string {
+	return fmt.Sprintf("schema/v%d/%s%s", jsonSchemaVersion, name, schemaSuffix)
+}
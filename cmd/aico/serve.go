@@ -0,0 +1,154 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/gateway"
+	"micheam.com/aico/internal/logging"
+)
+
+var CmdServe = &cli.Command{
+	Name:  "serve",
+	Usage: "Serve the models over an OpenAI-compatible HTTP API",
+	Description: "Exposes /v1/chat/completions (streaming and non-streaming) and /v1/models,\n" +
+		"so that tools built on an OpenAI SDK can reach every provider through one\n" +
+		"local endpoint. Models are named as with --model, e.g. \"anthropic:claude-haiku-4-5\".\n" +
+		"The persona of a request can be chosen with the " + gateway.PersonaHeader + " header.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "addr",
+			Usage: "`ADDRESS` to listen on",
+			Value: "localhost:8080",
+		},
+		&cli.StringFlag{
+			Name:  "token",
+			Usage: "require this bearer `TOKEN` on every request",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar(envKeyWithPrefix(appname, "serve_token")),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "persona",
+			Usage: "persona whose system prompt leads every request (default: none)",
+		},
+	},
+	Action: runServe,
+}
+
+func runServe(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	persona := cmd.String("persona")
+	if _, ok := conf.PersonaMap[persona]; persona != "" && !ok {
+		return fmt.Errorf("persona %q not found", persona)
+	}
+	// Requests are logged to the logfile and to stderr.
+	f, err := conf.OpenLogfile()
+	if err != nil {
+		return fmt.Errorf("open logfile: %w", err)
+	}
+	defer f.Close()
+	logLevel, err := conf.GetLogLevel()
+	if err != nil {
+		return err
+	}
+	if cmd.Bool(flagDebug.Name) {
+		logLevel = logging.LevelDebug
+	}
+	logger := logging.New(io.MultiWriter(f, cmd.Root().ErrWriter), &logging.Options{Level: logLevel})
+
+	// Persona templates are rendered once, without variables. Those that
+	// fail to render, e.g. for want of a --var, cannot be selected.
+	personas := make(map[string]string, len(conf.PersonaMap))
+	for name := range conf.PersonaMap {
+		p, err := renderPersona(conf, name, nil)
+		if err != nil {
+			if name == persona {
+				return err
+			}
+			logger.Warn("skip persona", "persona", name, "error", err)
+			continue
+		}
+		personas[name] = p.Message
+	}
+
+	handler := gateway.NewHandler(gateway.Options{
+		Models: allAvailableModels(),
+		Resolve: func(ctx context.Context, req gateway.ModelRequest) (assistant.GenerativeModel, error) {
+			// Like --model, the model of the request takes precedence over
+			// that of the persona.
+			name := req.Model
+			if p, err := conf.ResolvePersona(req.Persona); name == "" && err == nil {
+				name = p.Model
+			}
+			provider, modelName, found := detectProviderByModelSpec(cmp.Or(name, conf.Model), conf.DefaultProvider)
+			if !found {
+				return nil, gateway.ErrUnknownModel
+			}
+			model, err := prepareModel(ctx, cmd, conf, QualifiedName(provider, modelName), req.Persona)
+			if err != nil {
+				return nil, err
+			}
+			if ps, ok := model.(assistant.ParamSetter); ok && !req.Params.IsZero() {
+				params, err := generationParams(conf, req.Persona)
+				if err != nil {
+					return nil, err
+				}
+				if req.Params.Temperature != nil {
+					params.Temperature = req.Params.Temperature
+				}
+				params.MaxTokens = cmp.Or(req.Params.MaxTokens, params.MaxTokens)
+				params.Effort = cmp.Or(req.Params.Effort, params.Effort)
+				ps.SetGenerationParams(params)
+			}
+			return model, nil
+		},
+		Personas: personas,
+		Persona:  persona,
+		Token:    cmd.String("token"),
+		Logger:   logger,
+	})
+
+	ln, err := net.Listen("tcp", cmd.String("addr"))
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	fmt.Fprintf(cmd.Root().ErrWriter, "Serving on http://%s/v1\n", ln.Addr())
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
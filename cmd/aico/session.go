@@ -1,14 +1,24 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/urfave/cli/v3"
 
 	"micheam.com/aico/internal/assistant"
 	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/importer"
+	"micheam.com/aico/internal/logging"
 )
 
 var CmdSession = &cli.Command{
@@ -27,6 +37,128 @@ var CmdSession = &cli.Command{
 					Usage:   "Maximum number of sessions to show",
 					Value:   20,
 				},
+				&cli.StringFlag{
+					Name:  "tag",
+					Usage: "Only show sessions with the given tag",
+				},
+				&cli.BoolFlag{
+					Name:  "cost",
+					Usage: "Show token usage and cost of each session",
+				},
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Show the messages of a session",
+			ArgsUsage: "<session-id>",
+			Action:    runSessionShow,
+		},
+		{
+			Name:      "retry",
+			Usage:     "Regenerate the last assistant reply of a session",
+			ArgsUsage: "<session-id>",
+			Description: "The assistant messages after the last user message are discarded and\n" +
+				"the reply is generated again. With --model, the session switches to\n" +
+				"the given model.",
+			Action: runSessionRetry,
+			Flags: []cli.Flag{
+				flagModel,
+				flagNoStream,
+				flagCache,
+				flagDebug,
+			},
+		},
+		{
+			Name:      "undo",
+			Usage:     "Remove the last exchange (prompt and reply) from a session",
+			ArgsUsage: "<session-id>",
+			Action:    runSessionUndo,
+		},
+		{
+			Name:      "edit",
+			Usage:     "Edit a message in $EDITOR and drop every message after it",
+			ArgsUsage: "<session-id>",
+			Description: "Message numbers are shown by `aico session show`. After editing a\n" +
+				"user message, run `aico session retry` to get a new reply.",
+			Action: runSessionEdit,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:     "message",
+					Aliases:  []string{"n"},
+					Usage:    "number of the message to edit (1-based)",
+					Required: true,
+				},
+			},
+		},
+		{
+			Name:      "tag",
+			Usage:     "Add or remove tags of a session",
+			ArgsUsage: "<session-id> <tag>...",
+			Action:    runSessionTag,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "remove",
+					Aliases: []string{"d"},
+					Usage:   "Remove the given tags instead of adding them",
+				},
+			},
+		},
+		{
+			Name:      "compact",
+			Usage:     "Drop or summarize older turns of a session",
+			ArgsUsage: "<session-id>",
+			Action:    runSessionCompact,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "strategy",
+					Usage: "compaction strategy (summarize, drop)",
+					Value: string(assistant.CompactionSummarize),
+				},
+				&cli.IntFlag{
+					Name:  "keep",
+					Usage: "number of most recent turns to keep verbatim",
+					Value: 1,
+				},
+			},
+		},
+		{
+			Name:  "migrate",
+			Usage: "Copy sessions from one session store backend to another",
+			Description: "Sessions that already exist in the destination are skipped.\n" +
+				"Set session_store in config.toml afterwards to switch backends.",
+			Action: runSessionMigrate,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "from",
+					Usage: "source backend (json, sqlite); defaults to the configured session_store",
+				},
+				&cli.StringFlag{
+					Name:     "to",
+					Usage:    "destination backend (json, sqlite)",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "delete",
+					Usage: "delete migrated sessions from the source backend",
+				},
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "Import conversations exported from ChatGPT or Claude.ai",
+			ArgsUsage: "<file>",
+			Description: "Reads conversations.json from a ChatGPT or Claude.ai data export, or a\n" +
+				"plain OpenAI `messages` array (--from openai-messages). Use - for stdin.\n" +
+				"Imported sessions are tagged with the source format and keep their\n" +
+				"original IDs, so importing the same export again skips them.",
+			Action: runSessionImport,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "from",
+					Usage:    "export format (chatgpt, claude, openai-messages)",
+					Required: true,
+				},
+				flagModel,
 			},
 		},
 		{
@@ -39,6 +171,8 @@ var CmdSession = &cli.Command{
 				flagContext,
 				flagModel,
 				flagNoStream,
+				flagCache,
+				flagNoCache,
 				flagDebug,
 				flagPersona,
 			},
@@ -47,37 +181,330 @@ var CmdSession = &cli.Command{
 }
 
 func runSessionList(ctx context.Context, cmd *cli.Command) error {
-	conf, err := config.Load()
+	store, err := sessionStoreFromConfig()
 	if err != nil {
-		conf = config.DefaultConfig()
+		return err
 	}
-	dir := conf.GetSessionDir()
+	defer store.Close()
 
-	summaries, err := assistant.ListSessions(dir)
+	summaries, err := store.List(ctx)
 	if err != nil {
 		return fmt.Errorf("list sessions: %w", err)
 	}
 
+	if tag := cmd.String("tag"); tag != "" {
+		summaries = slices.DeleteFunc(summaries, func(s assistant.SessionSummary) bool {
+			return !slices.Contains(s.Tags, tag)
+		})
+	}
+
+	limit := min(int(cmd.Int("limit")), len(summaries))
+	if cmd.Bool(flagJSON.Name) {
+		sessions := []sessionListItemView{}
+		for _, s := range summaries[:limit] {
+			sessions = append(sessions, newSessionListItemView(s))
+		}
+		return writeJSONDocument(cmd.Writer, jsonTypeSessions, sessions)
+	}
+
 	if len(summaries) == 0 {
 		fmt.Fprintln(cmd.Writer, "No sessions found.")
 		return nil
 	}
 
-	limit := min(int(cmd.Int("limit")), len(summaries))
+	withCost := cmd.Bool("cost")
 
 	w := tabwriter.NewWriter(cmd.Writer, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "ID\tUPDATED\tMSGS\tPREVIEW\n")
+	if withCost {
+		fmt.Fprintf(w, "ID\tUPDATED\tMSGS\tTOKENS\tCOST\tTITLE\tTAGS\n")
+	} else {
+		fmt.Fprintf(w, "ID\tUPDATED\tMSGS\tTITLE\tTAGS\n")
+	}
+	var total assistant.Usage
 	for _, s := range summaries[:limit] {
-		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
-			s.ID,
-			s.ModTime.Format("2006-01-02 15:04"),
-			s.MsgCount,
-			s.Preview,
-		)
+		fmt.Fprintf(w, "%s\t%s\t%d\t", s.ID, s.UpdatedAt.Local().Format("2006-01-02 15:04"), s.MsgCount)
+		if withCost {
+			fmt.Fprintf(w, "%d\t%s\t", s.Usage.InputTokens+s.Usage.OutputTokens, formatCost(s.Usage.Cost))
+			total.Add(&s.Usage)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", s.DisplayTitle(), strings.Join(s.Tags, ","))
+	}
+	if withCost {
+		fmt.Fprintf(w, "TOTAL\t\t\t%d\t%s\t\t\n", total.InputTokens+total.OutputTokens, formatCost(total.Cost))
 	}
 	return w.Flush()
 }
 
+// sessionListItemView is the JSON shape of a session in `session list`.
+type sessionListItemView struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	Tags         []string   `json:"tags"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	MessageCount int        `json:"message_count"`
+	Usage        *usageInfo `json:"usage"`
+}
+
+func newSessionListItemView(s assistant.SessionSummary) sessionListItemView {
+	tags := s.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return sessionListItemView{
+		ID:           s.ID,
+		Title:        s.DisplayTitle(),
+		Tags:         tags,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+		MessageCount: s.MsgCount,
+		Usage:        toUsageInfo(&s.Usage),
+	}
+}
+
+func runSessionShow(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required: aico session show <session-id>")
+	}
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess, err := store.Load(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+
+	if cmd.Bool(flagJSON.Name) {
+		return writeJSONDocument(cmd.Writer, jsonTypeSession, sess)
+	}
+
+	w := cmd.Writer
+	fmt.Fprintf(w, "ID:      %s\n", sess.ID)
+	fmt.Fprintf(w, "Title:   %s\n", sess.Summary().DisplayTitle())
+	fmt.Fprintf(w, "Model:   %s\n", sess.Model)
+	if sess.Profile != "" {
+		fmt.Fprintf(w, "Profile: %s\n", sess.Profile)
+	}
+	if !sess.CreatedAt.IsZero() {
+		fmt.Fprintf(w, "Created: %s\n", sess.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if !sess.UpdatedAt.IsZero() {
+		fmt.Fprintf(w, "Updated: %s\n", sess.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if len(sess.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:    %s\n", strings.Join(sess.Tags, ","))
+	}
+	if u := sess.Usage; u != (assistant.Usage{}) {
+		fmt.Fprintf(w, "Usage:   %s\n", formatUsage(u))
+	}
+	for i, msg := range sess.Messages {
+		fmt.Fprintf(w, "\n[%d] %s%s\n", i+1, msg.GetAuthor(), messageMeta(msg))
+		fmt.Fprintln(w, assistant.MessageText(msg))
+	}
+	return nil
+}
+
+// messageMeta formats the model and time of msg for `session show`.
+func messageMeta(msg assistant.Message) string {
+	var parts []string
+	var at time.Time
+	switch m := msg.(type) {
+	case *assistant.UserMessage:
+		at = m.CreatedAt
+	case *assistant.AssistantMessage:
+		if m.Model != "" {
+			parts = append(parts, m.Model)
+		}
+		if m.Usage != nil {
+			parts = append(parts, fmt.Sprintf("%d in / %d out, %s", m.Usage.InputTokens, m.Usage.OutputTokens, formatCost(m.Usage.Cost)))
+		}
+		at = m.CreatedAt
+	}
+	if !at.IsZero() {
+		parts = append(parts, at.Local().Format("2006-01-02 15:04"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// formatUsage formats token totals and cost for display.
+func formatUsage(u assistant.Usage) string {
+	s := fmt.Sprintf("%d input", u.InputTokens)
+	if u.CachedInputTokens > 0 || u.CacheWriteTokens > 0 {
+		s += fmt.Sprintf(" (%d cache read, %d cache write)", u.CachedInputTokens, u.CacheWriteTokens)
+	}
+	s += fmt.Sprintf(" + %d output tokens, %s", u.OutputTokens, formatCost(u.Cost))
+	if u.CacheSavings != 0 {
+		s += fmt.Sprintf(" (%s saved by prompt caching)", formatCost(u.CacheSavings))
+	}
+	return s
+}
+
+// formatCost formats a cost in USD. Unknown (zero) costs are shown as "-".
+func formatCost(usd float64) string {
+	if usd == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("$%.4f", usd)
+}
+
+func runSessionRetry(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required: aico session retry <session-id>")
+	}
+
+	logger, cleanup, err := initializeLogger(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("can't load config: %w", err)
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer store.Close()
+
+	sess, unlock, err := assistant.LoadSessionLocked(ctx, store, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	defer unlock()
+	ctx = logging.ContextWith(ctx, logger.With(slog.String("session_id", sess.ID)))
+
+	if cmd.String(flagModel.Name) != "" {
+		model, err := detectModel(cmd)
+		if err != nil {
+			return fmt.Errorf("detect model: %w", err)
+		}
+		sess.Model = QualifiedName(model.Provider(), model.Name())
+	}
+
+	replies := sess.TrimTrailingReplies()
+	if last := sess.LastMessage(); last == nil || last.GetAuthor() != assistant.MessageAuthorUser {
+		return fmt.Errorf("session %s has no prompt to retry", sess.ID)
+	}
+	// A cached reply would only repeat the one being retried.
+	ctx = assistant.WithFreshResponse(ctx)
+	if err := generateReply(ctx, cmd, conf, sess, detectWriter(cmd)); err != nil {
+		// Keep the previous reply rather than leaving the prompt unanswered.
+		if last := sess.LastMessage(); len(replies) > 0 && last.GetAuthor() == assistant.MessageAuthorUser {
+			sess.AddMessages(replies...)
+			err = errors.Join(err, sess.Save(ctx))
+		}
+		return err
+	}
+	return nil
+}
+
+func runSessionUndo(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required: aico session undo <session-id>")
+	}
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess, unlock, err := assistant.LoadSessionLocked(ctx, store, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	defer unlock()
+	removed := sess.DropLastExchange()
+	if removed == 0 {
+		return fmt.Errorf("session %s has no messages to undo", sess.ID)
+	}
+	if err := sess.Save(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	fmt.Fprintf(cmd.Writer, "Session %s: removed %d messages.\n", sess.ID, removed)
+	return nil
+}
+
+func runSessionEdit(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required: aico session edit <session-id> --message N")
+	}
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess, unlock, err := assistant.LoadSessionLocked(ctx, store, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	defer unlock()
+	n := int(cmd.Int("message"))
+	if n < 1 || n > len(sess.Messages) {
+		return fmt.Errorf("message number must be between 1 and %d, got %d", len(sess.Messages), n)
+	}
+
+	msg := sess.Messages[n-1]
+	edited, err := editText(assistant.MessageText(msg), "aico-message-*.md")
+	if err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+	edited = strings.TrimRight(edited, "\n")
+	if strings.TrimSpace(edited) == "" {
+		return errors.New("edited message is empty; session left unchanged")
+	}
+
+	sess.ReplaceMessage(n-1, assistant.WithText(msg, edited))
+	removed := sess.Truncate(n)
+	if err := sess.Save(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	fmt.Fprintf(cmd.Writer, "Session %s: edited message %d, removed %d later messages.\n", sess.ID, n, removed)
+	return nil
+}
+
+func runSessionTag(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	tags := cmd.Args().Tail()
+	if sessionID == "" || len(tags) == 0 {
+		return fmt.Errorf("session ID and tags are required: aico session tag <session-id> <tag>...")
+	}
+
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess, unlock, err := assistant.LoadSessionLocked(ctx, store, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	defer unlock()
+	if cmd.Bool("remove") {
+		sess.RemoveTags(tags...)
+	} else {
+		sess.AddTags(tags...)
+	}
+	if err := sess.Save(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	fmt.Fprintln(cmd.Writer, strings.Join(sess.Tags, ","))
+	return nil
+}
+
 func runSessionResume(ctx context.Context, cmd *cli.Command) error {
 	sessionID := cmd.Args().First()
 	if sessionID == "" {
@@ -94,3 +521,163 @@ func runSessionResume(ctx context.Context, cmd *cli.Command) error {
 
 	return doGenerate(ctx, cmd, prompt)
 }
+
+func runSessionCompact(ctx context.Context, cmd *cli.Command) error {
+	sessionID := cmd.Args().First()
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required: aico session compact <session-id>")
+	}
+	conf, err := config.Load()
+	if err != nil {
+		conf = config.DefaultConfig()
+	}
+	policy, err := contextPolicy(cmd, conf, cmd.String("strategy"))
+	if err != nil {
+		return err
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer store.Close()
+
+	sess, unlock, err := assistant.LoadSessionLocked(ctx, store, sessionID)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	defer unlock()
+	res, err := policy.Compact(ctx, sess, int(cmd.Int("keep")))
+	if err != nil {
+		return fmt.Errorf("compact session: %w", err)
+	}
+	if err := sess.Save(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	fmt.Fprintf(cmd.Writer, "Session %s: %s.\n", sess.ID, describeCompaction(res))
+	return nil
+}
+
+func runSessionMigrate(ctx context.Context, cmd *cli.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		conf = config.DefaultConfig()
+	}
+	from, to := cmp.Or(cmd.String("from"), conf.GetSessionStore()), cmd.String("to")
+	if from == to {
+		return fmt.Errorf("source and destination backends are the same: %s", from)
+	}
+
+	src, err := openSessionStore(conf, from)
+	if err != nil {
+		return fmt.Errorf("open %s store: %w", from, err)
+	}
+	defer src.Close()
+	dst, err := openSessionStore(conf, to)
+	if err != nil {
+		return fmt.Errorf("open %s store: %w", to, err)
+	}
+	defer dst.Close()
+
+	summaries, err := src.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list %s sessions: %w", from, err)
+	}
+	var migrated, skipped int
+	for _, s := range summaries {
+		if _, err := dst.Load(ctx, s.ID); err == nil {
+			skipped++
+			continue
+		} else if !errors.Is(err, assistant.ErrSessionNotFound) {
+			return fmt.Errorf("check %s in %s store: %w", s.ID, to, err)
+		}
+		sess, err := src.Load(ctx, s.ID)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", s.ID, err)
+		}
+		// Keep the listing order of sessions saved before timestamps existed.
+		if sess.UpdatedAt.IsZero() {
+			sess.UpdatedAt = s.UpdatedAt
+		}
+		if sess.CreatedAt.IsZero() {
+			sess.CreatedAt = s.CreatedAt
+		}
+		if err := dst.Save(ctx, sess); err != nil {
+			return fmt.Errorf("save %s: %w", s.ID, err)
+		}
+		if cmd.Bool("delete") {
+			if err := src.Delete(ctx, s.ID); err != nil {
+				return fmt.Errorf("delete %s from %s store: %w", s.ID, from, err)
+			}
+		}
+		migrated++
+	}
+	fmt.Fprintf(cmd.Writer, "Migrated %d sessions from %s to %s (%d already present).\n", migrated, from, to, skipped)
+	return nil
+}
+
+func runSessionImport(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.Args().First()
+	if path == "" {
+		return fmt.Errorf("file is required: aico session import --from <format> <file>")
+	}
+	format := importer.Format(cmd.String("from"))
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open export: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	sessions, err := importer.Import(format, r)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", path, err)
+	}
+
+	// Imported sessions are resumed with the model aico would use for a new
+	// session; the original model is kept on each reply.
+	model, err := detectModel(cmd)
+	if err != nil {
+		return fmt.Errorf("detect model: %w", err)
+	}
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var imported, skipped int
+	for _, sess := range sessions {
+		if _, err := store.Load(ctx, sess.ID); err == nil {
+			skipped++
+			continue
+		} else if !errors.Is(err, assistant.ErrSessionNotFound) {
+			return fmt.Errorf("check %s: %w", sess.ID, err)
+		}
+		sess.Model = QualifiedName(model.Provider(), model.Name())
+		if err := store.Save(ctx, sess); err != nil {
+			return fmt.Errorf("save %s: %w", sess.ID, err)
+		}
+		imported++
+	}
+	fmt.Fprintf(cmd.Writer, "Imported %d sessions (%d already present).\n", imported, skipped)
+	return nil
+}
+
+// sessionStoreFromConfig opens the configured session store, falling back
+// to the default configuration if the config file cannot be loaded.
+//
+// Make sure to close the returned store when done.
+func sessionStoreFromConfig() (assistant.SessionStore, error) {
+	conf, err := config.Load()
+	if err != nil {
+		conf = config.DefaultConfig()
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return nil, fmt.Errorf("open session store: %w", err)
+	}
+	return store, nil
+}
@@ -3,26 +3,23 @@ package main
 import (
 	"bufio"
 	"context"
-	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
 
 	"github.com/urfave/cli/v3"
 
+	"micheam.com/aico/internal/assistant"
 	"micheam.com/aico/internal/config"
 	"micheam.com/aico/internal/logging"
 )
 
 // loadConfig is a helper function to load the configuration and attach it to the context.
-//
-// errors:
-//
-// - [ErrConfigFileNotFound]: The config file was not found.
 func loadConfig(ctx context.Context, cmd *cli.Command) (*config.Config, error) {
 	conf, err := config.Load()
-	if errors.Is(err, config.ErrConfigFileNotFound) {
-		return nil, ErrConfigFileNotFound
-	}
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
@@ -32,6 +29,69 @@ func loadConfig(ctx context.Context, cmd *cli.Command) (*config.Config, error) {
 	return conf, nil
 }
 
+// openSessionStore opens the session store backend of the given kind.
+// An empty kind selects the backend configured in conf.
+//
+// Make sure to close the returned store when done.
+func openSessionStore(conf *config.Config, kind string) (assistant.SessionStore, error) {
+	if kind == "" {
+		kind = conf.GetSessionStore()
+	}
+	switch kind {
+	case config.SessionStoreJSON:
+		return assistant.NewJSONStore(conf.GetSessionDir()), nil
+	case config.SessionStoreSQLite:
+		return assistant.OpenSQLiteStore(conf.SessionDBPath())
+	default:
+		return nil, fmt.Errorf("unknown session store %q (valid: %s, %s)",
+			kind, config.SessionStoreJSON, config.SessionStoreSQLite)
+	}
+}
+
+// runEditor opens path in the user's editor ($EDITOR, falling back to vim,
+// or notepad on Windows) and waits for it to exit.
+func runEditor(path string) error {
+	editor, ok := os.LookupEnv("EDITOR")
+	if !ok || strings.TrimSpace(editor) == "" {
+		editor = "vim"
+		if runtime.GOOS == "windows" {
+			editor = "notepad.exe"
+		}
+	}
+	// Allow editors with arguments, e.g. EDITOR="code --wait".
+	args := strings.Fields(editor)
+	cmdExec := exec.Command(args[0], append(args[1:], path)...)
+	cmdExec.Stdin = os.Stdin
+	cmdExec.Stdout = os.Stdout
+	cmdExec.Stderr = os.Stderr
+	return cmdExec.Run()
+}
+
+// editText lets the user edit text in their editor and returns the result.
+// pattern names the temporary file, see [os.CreateTemp].
+func editText(text, pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("close temp file: %w", err)
+	}
+	if err := runEditor(f.Name()); err != nil {
+		return "", fmt.Errorf("run editor: %w", err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("read temp file: %w", err)
+	}
+	return string(data), nil
+}
+
 // readLines reads lines from the given reader.
 func readLines(r io.Reader) ([]string, error) {
 	var lines []string
@@ -51,7 +111,10 @@ func initializeLogger(ctx context.Context, cmd *cli.Command) (*logging.Logger, f
 	if err != nil {
 		return nil, nil, fmt.Errorf("load config: %w", err)
 	}
-	logLevel := logging.LevelInfo
+	logLevel, err := conf.GetLogLevel()
+	if err != nil {
+		return nil, nil, err
+	}
 	if cmd.Bool(flagDebug.Name) {
 		logLevel = logging.LevelDebug
 	}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/config"
+	"micheam.com/aico/internal/logging"
+)
+
+var CmdUsage = &cli.Command{
+	Name:  "usage",
+	Usage: "Report token usage and cost across sessions",
+	Description: "Aggregates the usage recorded in sessions by period and by model, provider\n" +
+		"or persona. Costs are computed from list prices at the time of each request.",
+	Action: runUsage,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "period",
+			Usage: "aggregation period (day, week, month, all)",
+			Value: "month",
+		},
+		&cli.StringFlag{
+			Name:  "by",
+			Usage: "group by model, provider, persona or none",
+			Value: "model",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only count usage on or after this date (YYYY-MM-DD, or e.g. 30d for 30 days ago)",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only count usage on or before this date (YYYY-MM-DD, or e.g. 7d for 7 days ago)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "output format (table, json, csv); --json is a shorthand for json",
+			Value: "table",
+		},
+	},
+}
+
+// -----------------------------------------------------------------------------
+// Actions
+// -----------------------------------------------------------------------------
+
+func runUsage(ctx context.Context, cmd *cli.Command) error {
+	period, by := cmd.String("period"), cmd.String("by")
+	if !slices.Contains([]string{"day", "week", "month", "all"}, period) {
+		return fmt.Errorf("unknown period %q (valid: day, week, month, all)", period)
+	}
+	if !slices.Contains([]string{"model", "provider", "persona", "none"}, by) {
+		return fmt.Errorf("unknown grouping %q (valid: model, provider, persona, none)", by)
+	}
+	format := cmd.String("format")
+	if cmd.Bool(flagJSON.Name) {
+		format = "json"
+	}
+
+	now := time.Now()
+	var since, until time.Time
+	var err error
+	if s := cmd.String("since"); s != "" {
+		if since, err = parseDate(s, now); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if s := cmd.String("until"); s != "" {
+		if until, err = parseDate(s, now); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		until = until.AddDate(0, 0, 1) // inclusive
+	}
+
+	store, err := sessionStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	records, err := loadUsageRecords(ctx, store, since, until)
+	if err != nil {
+		return err
+	}
+	rows := aggregateUsage(records, period, by)
+
+	switch format {
+	case "table":
+		return writeUsageTable(cmd.Writer, rows)
+	case "json":
+		return writeJSONDocument(cmd.Writer, jsonTypeUsage, rows)
+	case "csv":
+		return writeUsageCSV(cmd.Writer, rows)
+	default:
+		return fmt.Errorf("unknown format %q (valid: table, json, csv)", format)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// usageRecord is a generation together with the session it was made for.
+type usageRecord struct {
+	assistant.UsageRecord
+	Persona string
+}
+
+// usageRow is a line of the usage report.
+type usageRow struct {
+	Period   string  `json:"period"`
+	Key      string  `json:"key"`
+	Requests int     `json:"requests"`
+	Input    int     `json:"input_tokens"`
+	Cached   int     `json:"cached_input_tokens"`
+	Output   int     `json:"output_tokens"`
+	Cost     float64 `json:"cost"`
+	Saved    float64 `json:"cache_savings"`
+}
+
+// loadUsageRecords collects the usage records made in [since, until) from
+// every session in store. Zero times leave the range open.
+func loadUsageRecords(ctx context.Context, store assistant.SessionStore, since, until time.Time) ([]usageRecord, error) {
+	summaries, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var records []usageRecord
+	for _, s := range summaries {
+		if s.Usage == (assistant.Usage{}) {
+			continue
+		}
+		if !since.IsZero() && s.UpdatedAt.Before(since) {
+			continue // nothing was generated after the last update
+		}
+		sess, err := store.Load(ctx, s.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load session %s: %w", s.ID, err)
+		}
+		for _, r := range sess.UsageRecords() {
+			if (!since.IsZero() && r.At.Before(since)) || (!until.IsZero() && !r.At.Before(until)) {
+				continue
+			}
+			records = append(records, usageRecord{UsageRecord: r, Persona: sess.Persona})
+		}
+	}
+	return records, nil
+}
+
+// aggregateUsage sums records by period (day, week, month or all) and key
+// (model, provider, persona or none). Rows are sorted by period, then by
+// descending cost.
+func aggregateUsage(records []usageRecord, period, by string) []usageRow {
+	type key struct{ period, key string }
+	sums := make(map[key]*usageRow)
+	for _, r := range records {
+		k := key{periodOf(r.At, period), groupKey(r, by)}
+		row, ok := sums[k]
+		if !ok {
+			row = &usageRow{Period: k.period, Key: k.key}
+			sums[k] = row
+		}
+		row.Requests++
+		row.Input += r.InputTokens
+		row.Cached += r.CachedInputTokens
+		row.Output += r.OutputTokens
+		row.Cost += r.Cost
+		row.Saved += r.CacheSavings
+	}
+
+	rows := make([]usageRow, 0, len(sums))
+	for _, row := range sums {
+		rows = append(rows, *row)
+	}
+	slices.SortFunc(rows, func(a, b usageRow) int {
+		return cmp.Or(
+			cmp.Compare(a.Period, b.Period),
+			cmp.Compare(b.Cost, a.Cost),
+			cmp.Compare(a.Key, b.Key),
+		)
+	})
+	return rows
+}
+
+func periodOf(t time.Time, period string) string {
+	t = t.Local()
+	switch period {
+	case "day":
+		return t.Format("2006-01-02")
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return t.Format("2006-01")
+	default:
+		return "all"
+	}
+}
+
+func groupKey(r usageRecord, by string) string {
+	var k string
+	switch by {
+	case "model":
+		k = r.Model
+	case "provider":
+		k, _, _ = strings.Cut(r.Model, ":")
+	case "persona":
+		k = r.Persona
+	default:
+		return "-"
+	}
+	return cmp.Or(k, "(unknown)")
+}
+
+// parseDate parses an absolute date (YYYY-MM-DD) in local time, or a
+// number of days before now such as "30d".
+func parseDate(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a number of days", s)
+		}
+		y, m, d := now.Date()
+		return time.Date(y, m, d-n, 0, 0, 0, 0, now.Location()), nil
+	}
+	return time.ParseInLocation("2006-01-02", s, now.Location())
+}
+
+func writeUsageTable(w io.Writer, rows []usageRow) error {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No usage recorded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "PERIOD\tKEY\tREQUESTS\tINPUT\tCACHED\tOUTPUT\tCOST\tSAVED\t\n")
+	var total usageRow
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t\n", r.Period, r.Key, r.Requests, r.Input, r.Cached, r.Output, formatCost(r.Cost), formatCost(r.Saved))
+		total.Requests += r.Requests
+		total.Input += r.Input
+		total.Cached += r.Cached
+		total.Output += r.Output
+		total.Cost += r.Cost
+		total.Saved += r.Saved
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%d\t%d\t%s\t%s\t\n", total.Requests, total.Input, total.Cached, total.Output, formatCost(total.Cost), formatCost(total.Saved))
+	return tw.Flush()
+}
+
+func writeUsageCSV(w io.Writer, rows []usageRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "key", "requests", "input_tokens", "cached_input_tokens", "output_tokens", "cost", "cache_savings"})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Period, r.Key,
+			strconv.Itoa(r.Requests), strconv.Itoa(r.Input), strconv.Itoa(r.Cached), strconv.Itoa(r.Output),
+			strconv.FormatFloat(r.Cost, 'f', 6, 64),
+			strconv.FormatFloat(r.Saved, 'f', 6, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// checkBudget compares this month's recorded spending with the budget in
+// conf. Once the budget is exceeded, it warns on stderr and, if configured,
+// refuses models at or above the configured output price.
+//
+// Stores that implement [assistant.CostReporter] total the spending in one
+// query; others load the sessions updated this month.
+func checkBudget(ctx context.Context, cmd *cli.Command, conf *config.Config, model assistant.ModelDescriptor) error {
+	budget := conf.Budget
+	if budget.Monthly <= 0 {
+		return nil
+	}
+	store, err := openSessionStore(conf, "")
+	if err != nil {
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	spent, err := monthlySpending(ctx, store, monthStart)
+	if err != nil {
+		// Never block generation because the budget cannot be computed.
+		logging.LoggerFrom(ctx).Warn("skip budget check", "error", err)
+		return nil
+	}
+	if spent < budget.Monthly {
+		return nil
+	}
+
+	if limit := budget.RefuseOutputPrice; limit > 0 && model.Pricing().Output >= limit {
+		return fmt.Errorf("monthly budget exceeded ($%.2f of $%.2f); refusing %s (output $%.2f per MTok, limit $%.2f); use a cheaper model or raise budget.monthly",
+			spent, budget.Monthly, model.Name(), model.Pricing().Output, limit)
+	}
+	fmt.Fprintf(cmd.ErrWriter, "Warning: monthly budget exceeded ($%.2f of $%.2f spent this month).\n",
+		spent, budget.Monthly)
+	return nil
+}
+
+// monthlySpending returns the cost of the generations recorded in store
+// since monthStart.
+func monthlySpending(ctx context.Context, store assistant.SessionStore, monthStart time.Time) (float64, error) {
+	if cr, ok := store.(assistant.CostReporter); ok {
+		return cr.CostSince(ctx, monthStart)
+	}
+	records, err := loadUsageRecords(ctx, store, monthStart, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	var spent float64
+	for _, r := range records {
+		spent += r.Cost
+	}
+	return spent, nil
+}
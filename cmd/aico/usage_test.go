@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+func TestAggregateUsage(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 12, 0, 0, 0, time.Local) }
+	rec := func(model, persona string, day int, cost float64) usageRecord {
+		return usageRecord{
+			UsageRecord: assistant.UsageRecord{
+				Model: model,
+				At:    at(day),
+				Usage: assistant.Usage{InputTokens: 100, OutputTokens: 10, Cost: cost, CacheSavings: cost / 10},
+			},
+			Persona: persona,
+		}
+	}
+	records := []usageRecord{
+		rec("anthropic:claude-haiku-4-5", "default", 1, 0.01),
+		rec("anthropic:claude-opus-4-8", "coding", 1, 0.50),
+		rec("openai:gpt-4.1", "", 2, 0.10),
+		rec("anthropic:claude-haiku-4-5", "default", 2, 0.01),
+	}
+
+	t.Run("month by provider", func(t *testing.T) {
+		rows := aggregateUsage(records, "month", "provider")
+		require.Len(t, rows, 2)
+		require.Equal(t, "2026-03", rows[0].Period)
+		require.Equal(t, "anthropic", rows[0].Key)
+		require.Equal(t, 3, rows[0].Requests)
+		require.Equal(t, 300, rows[0].Input)
+		require.InDelta(t, 0.52, rows[0].Cost, 1e-9)
+		require.InDelta(t, 0.052, rows[0].Saved, 1e-9)
+		require.Equal(t, "openai", rows[1].Key)
+	})
+
+	t.Run("day by persona", func(t *testing.T) {
+		rows := aggregateUsage(records, "day", "persona")
+		var keys []string
+		for _, r := range rows {
+			keys = append(keys, r.Period+" "+r.Key)
+		}
+		require.Equal(t, []string{
+			"2026-03-01 coding",
+			"2026-03-01 default",
+			"2026-03-02 (unknown)",
+			"2026-03-02 default",
+		}, keys)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		rows := aggregateUsage(records, "all", "none")
+		require.Len(t, rows, 1)
+		require.Equal(t, 4, rows[0].Requests)
+	})
+}
+
+func TestPeriodOf_Week(t *testing.T) {
+	require.Equal(t, "2026-W01", periodOf(time.Date(2025, 12, 29, 12, 0, 0, 0, time.Local), "week"))
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 4, 5, 0, time.Local)
+
+	got, err := parseDate("2026-01-31", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local), got)
+
+	got, err = parseDate("30d", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 2, 8, 0, 0, 0, 0, time.Local), got)
+
+	_, err = parseDate("xd", now)
+	require.Error(t, err)
+}
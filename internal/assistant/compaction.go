@@ -0,0 +1,277 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompactionStrategy determines what happens when a conversation does not
+// fit into the model's context window.
+type CompactionStrategy string
+
+const (
+	// CompactionFail refuses to send the request.
+	CompactionFail CompactionStrategy = "fail"
+
+	// CompactionDrop drops the oldest turns until the conversation fits.
+	CompactionDrop CompactionStrategy = "drop"
+
+	// CompactionSummarize replaces the oldest turns with a summary written
+	// by a (cheap) summarizer model.
+	CompactionSummarize CompactionStrategy = "summarize"
+)
+
+// ParseCompactionStrategy parses a strategy name. An empty name yields
+// [CompactionFail].
+func ParseCompactionStrategy(s string) (CompactionStrategy, error) {
+	switch st := CompactionStrategy(s); st {
+	case "":
+		return CompactionFail, nil
+	case CompactionFail, CompactionDrop, CompactionSummarize:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown compaction strategy %q (valid: %s, %s, %s)",
+			s, CompactionFail, CompactionDrop, CompactionSummarize)
+	}
+}
+
+// ErrContextWindowExceeded is returned when a conversation does not fit into
+// the model's context window and cannot be compacted.
+var ErrContextWindowExceeded = errors.New("context window exceeded")
+
+// outputReserve caps the number of tokens reserved for the response when
+// computing the prompt budget. Reserving the full MaxOutputTokens would
+// waste most of the window on models with very large output limits.
+const outputReserve = 8_192
+
+// summaryTag marks the synthetic message holding a conversation summary.
+const summaryTag = "conversation_summary"
+
+const summaryInstruction = `You summarize chat conversations so that they can be continued later.
+Write a concise summary of the conversation below, keeping every fact, decision,
+code identifier, file name and open question needed to continue it.
+Use the language of the conversation. Reply with the summary only.`
+
+// PromptBudget returns the estimated number of prompt tokens that can be
+// sent to model, leaving room for the response. threshold (0, 1] scales the
+// budget down to absorb estimation errors.
+func PromptBudget(model ModelDescriptor, threshold float64) int {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 1
+	}
+	usable := model.ContextWindow() - min(model.MaxOutputTokens(), outputReserve)
+	return int(float64(usable) * threshold)
+}
+
+// EstimateTokens returns a rough, tokenizer-independent estimate of the
+// number of tokens in text: about four ASCII characters per token, and one
+// token per non-ASCII character (which over-estimates, erring on the safe
+// side for CJK text).
+func EstimateTokens(text string) int {
+	var ascii, other int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimatePromptTokens estimates the prompt size of a request made of the
+// given system instruction and messages.
+func EstimatePromptTokens(system []*TextContent, msgs []Message) int {
+	const perMessageOverhead = 4
+	n := 0
+	for _, c := range system {
+		n += EstimateTokens(c.Text)
+	}
+	for _, m := range msgs {
+		n += perMessageOverhead + EstimateTokens(MessageText(m))
+	}
+	return n
+}
+
+// ContextPolicy fits conversations into a model's context window.
+type ContextPolicy struct {
+	Strategy CompactionStrategy
+
+	// Threshold is the fraction of the usable context window that may be
+	// filled, see [PromptBudget].
+	Threshold float64
+
+	// Summarizer writes summaries for [CompactionSummarize]. Its system
+	// instruction is overwritten.
+	Summarizer GenerativeModel
+}
+
+// CompactionResult describes what a compaction did.
+type CompactionResult struct {
+	Removed    int  // number of original messages removed
+	Summarized bool // whether the removed messages were replaced by a summary
+	Before     int  // estimated prompt tokens before compaction
+	After      int  // estimated prompt tokens after compaction
+}
+
+// Fit returns the messages of sess to send to model, compacted if their
+// estimated prompt size exceeds the budget of model. The result is nil if
+// the session already fits.
+//
+// [CompactionDrop] only leaves the oldest turns out of the returned
+// messages; the session keeps them. [CompactionSummarize] modifies the
+// session in place, so that the summarized turns are not summarized again
+// on the next turn.
+func (p ContextPolicy) Fit(ctx context.Context, model ModelDescriptor, sess *Session) ([]Message, *CompactionResult, error) {
+	budget := PromptBudget(model, p.Threshold)
+	before := EstimatePromptTokens(sess.SystemInstruction, sess.Messages)
+	if before <= budget {
+		return sess.GetMessages(), nil, nil
+	}
+	exceeded := fmt.Errorf("%w: about %d tokens, but %s allows about %d",
+		ErrContextWindowExceeded, before, model.Name(), budget)
+
+	if p.Strategy == CompactionFail || p.Strategy == "" {
+		return nil, nil, fmt.Errorf("%w; run `aico session compact %s` or set compaction.strategy in config.toml", exceeded, sess.ID)
+	}
+
+	// For summaries, keep the recent turns within half the budget so that
+	// the summary itself has room.
+	tailBudget := budget
+	if p.Strategy == CompactionSummarize {
+		tailBudget = budget / 2
+	}
+	starts := turnStarts(sess.Messages)
+	keepFrom := -1
+	for _, i := range starts {
+		if EstimatePromptTokens(sess.SystemInstruction, sess.Messages[i:]) <= tailBudget {
+			keepFrom = i
+			break
+		}
+	}
+	if keepFrom < 0 && len(starts) > 0 {
+		// Fall back to keeping only the latest turn, if that fits at all.
+		if last := starts[len(starts)-1]; EstimatePromptTokens(sess.SystemInstruction, sess.Messages[last:]) <= budget {
+			keepFrom = last
+		}
+	}
+	if keepFrom <= 0 {
+		return nil, nil, fmt.Errorf("%w; the latest message alone is too large", exceeded)
+	}
+
+	if p.Strategy == CompactionDrop {
+		msgs := sess.GetMessages()[keepFrom:]
+		return msgs, &CompactionResult{
+			Removed: keepFrom,
+			Before:  before,
+			After:   EstimatePromptTokens(sess.SystemInstruction, msgs),
+		}, nil
+	}
+	res, err := p.compact(ctx, sess, keepFrom)
+	if err != nil {
+		return nil, nil, err
+	}
+	res.Before = before
+	if res.After > budget {
+		return nil, nil, fmt.Errorf("%w even after compaction (about %d tokens)", ErrContextWindowExceeded, res.After)
+	}
+	return sess.GetMessages(), res, nil
+}
+
+// Compact compacts sess unconditionally, keeping the last keepTurns turns
+// (a turn starts with a user message) verbatim.
+func (p ContextPolicy) Compact(ctx context.Context, sess *Session, keepTurns int) (*CompactionResult, error) {
+	if p.Strategy != CompactionDrop && p.Strategy != CompactionSummarize {
+		return nil, fmt.Errorf("compaction strategy must be %s or %s, got %q", CompactionDrop, CompactionSummarize, p.Strategy)
+	}
+	starts := turnStarts(sess.Messages) // oldest first
+	keepTurns = max(keepTurns, 0)
+	if len(starts) <= keepTurns {
+		return nil, errors.New("nothing to compact")
+	}
+	keepFrom := len(sess.Messages)
+	if keepTurns > 0 {
+		keepFrom = starts[len(starts)-keepTurns]
+	}
+	if keepFrom == 0 {
+		return nil, errors.New("nothing to compact")
+	}
+	before := EstimatePromptTokens(sess.SystemInstruction, sess.Messages)
+	res, err := p.compact(ctx, sess, keepFrom)
+	if err != nil {
+		return nil, err
+	}
+	res.Before = before
+	return res, nil
+}
+
+func (p ContextPolicy) compact(ctx context.Context, sess *Session, keepFrom int) (*CompactionResult, error) {
+	old, kept := sess.Messages[:keepFrom], sess.GetMessages()[keepFrom:]
+	res := &CompactionResult{Removed: len(old)}
+
+	if p.Strategy == CompactionSummarize {
+		if p.Summarizer == nil {
+			return nil, errors.New("no summarizer model configured")
+		}
+		summary, usage, err := summarize(ctx, p.Summarizer, old)
+		if err != nil {
+			return nil, err
+		}
+		sess.AddUsage(p.Summarizer, usage)
+		kept = append([]Message{
+			NewUserMessage(NewTextContent(fmt.Sprintf(
+				"<%s>\nThe earlier part of this conversation was summarized as follows.\n\n%s\n</%s>",
+				summaryTag, summary, summaryTag))),
+			NewAssistantMessage(NewTextContent("Understood. Let's continue from there.")),
+		}, kept...)
+		res.Summarized = true
+	}
+	sess.setMessages(kept)
+	res.After = EstimatePromptTokens(sess.SystemInstruction, sess.Messages)
+	return res, nil
+}
+
+// summarize asks model for a summary of msgs. The oldest messages are left
+// out if the transcript does not fit into the summarizer's own budget.
+func summarize(ctx context.Context, model GenerativeModel, msgs []Message) (string, *Usage, error) {
+	budget := PromptBudget(model, 0.9) - EstimateTokens(summaryInstruction)
+	var parts []string
+	total := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		part := fmt.Sprintf("<%s>\n%s\n</%s>", msgs[i].GetAuthor(), MessageText(msgs[i]), msgs[i].GetAuthor())
+		total += EstimateTokens(part)
+		if total > budget {
+			break
+		}
+		parts = append([]string{part}, parts...)
+	}
+	if len(parts) == 0 {
+		return "", nil, errors.New("conversation is too large to summarize")
+	}
+
+	model.SetSystemInstruction(NewTextContent(summaryInstruction))
+	resp, err := model.GenerateContent(ctx, NewUserMessage(NewTextContent(strings.Join(parts, "\n"))))
+	if err != nil {
+		return "", nil, fmt.Errorf("summarize conversation: %w", err)
+	}
+	tc, ok := resp.Content.(*TextContent)
+	if !ok || strings.TrimSpace(tc.Text) == "" {
+		return "", nil, errors.New("summarizer returned no text")
+	}
+	return strings.TrimSpace(tc.Text), resp.Usage, nil
+}
+
+// turnStarts returns the indexes of user messages, oldest first. Cutting the
+// history at these indexes keeps user and assistant messages alternating.
+func turnStarts(msgs []Message) []int {
+	var idx []int
+	for i, m := range msgs {
+		if m.GetAuthor() == MessageAuthorUser {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
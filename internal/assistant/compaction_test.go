@@ -0,0 +1,125 @@
+package assistant
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModel is a GenerativeModel with a tiny context window that replies
+// with a fixed text, reporting 1,000 input and 100 output tokens.
+type fakeModel struct {
+	reply  string
+	window int
+	system []*TextContent
+	got    []Message
+	calls  int
+	params GenerationParams
+}
+
+var _ GenerativeModel = (*fakeModel)(nil)
+
+func (m *fakeModel) Name() string                           { return "fake" }
+func (m *fakeModel) Description() string                    { return "fake model for tests" }
+func (m *fakeModel) Provider() string                       { return "test" }
+func (m *fakeModel) ContextWindow() int                     { return m.window }
+func (m *fakeModel) MaxOutputTokens() int                   { return 100 }
+func (m *fakeModel) Pricing() Pricing                       { return Pricing{Input: 1, Output: 10} }
+func (m *fakeModel) SetSystemInstruction(c ...*TextContent) { m.system = c }
+func (m *fakeModel) SetGenerationParams(p GenerationParams) { m.params = p }
+func (m *fakeModel) GenerateContentStream(ctx context.Context, msgs ...Message) (iter.Seq2[*GenerateContentResponse, error], error) {
+	resp, _ := m.GenerateContent(ctx, msgs...)
+	return func(yield func(*GenerateContentResponse, error) bool) {
+		for _, word := range strings.SplitAfter(m.reply, " ") {
+			if !yield(&GenerateContentResponse{Content: NewTextContent(word)}, nil) {
+				return
+			}
+		}
+		yield(&GenerateContentResponse{Usage: resp.Usage}, nil)
+	}, nil
+}
+
+func (m *fakeModel) GenerateContent(_ context.Context, msgs ...Message) (*GenerateContentResponse, error) {
+	m.calls++
+	m.got = msgs
+	return &GenerateContentResponse{
+		Content: NewTextContent(m.reply),
+		Usage:   &Usage{InputTokens: 1_000, OutputTokens: 100},
+	}, nil
+}
+
+// longSession returns a session of n exchanges of roughly 100 tokens each.
+func longSession(n int) *Session {
+	sess := NewSession(NewJSONStore(""))
+	for range n {
+		sess.AddMessages(
+			NewUserMessage(NewTextContent(strings.Repeat("ask ", 50))),
+			NewAssistantMessage(NewTextContent(strings.Repeat("reply ", 60))),
+		)
+	}
+	return sess
+}
+
+func TestEstimateTokens(t *testing.T) {
+	require.Equal(t, 0, EstimateTokens(""))
+	require.Equal(t, 3, EstimateTokens("hello world"))
+	require.Equal(t, 5, EstimateTokens("こんにちは"))
+}
+
+func TestContextPolicy_Fit(t *testing.T) {
+	ctx := context.Background()
+	model := &fakeModel{window: 1_000}
+
+	t.Run("fits", func(t *testing.T) {
+		sess := longSession(2)
+		msgs, res, err := ContextPolicy{Strategy: CompactionFail}.Fit(ctx, model, sess)
+		require.NoError(t, err)
+		require.Nil(t, res)
+		require.Len(t, msgs, 4)
+		require.Len(t, sess.Messages, 4)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		sess := longSession(10)
+		_, _, err := ContextPolicy{Strategy: CompactionFail}.Fit(ctx, model, sess)
+		require.ErrorIs(t, err, ErrContextWindowExceeded)
+		require.Len(t, sess.Messages, 20)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		sess := longSession(10)
+		msgs, res, err := ContextPolicy{Strategy: CompactionDrop}.Fit(ctx, model, sess)
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		require.False(t, res.Summarized)
+		require.Equal(t, 20-len(msgs), res.Removed)
+		require.LessOrEqual(t, res.After, PromptBudget(model, 0))
+		require.Equal(t, MessageAuthorUser, msgs[0].GetAuthor())
+		require.Len(t, sess.Messages, 20, "dropped turns should stay in the session")
+	})
+
+	t.Run("summarize", func(t *testing.T) {
+		sess := longSession(10)
+		summarizer := &fakeModel{window: 100_000, reply: "They talked a lot."}
+		msgs, res, err := ContextPolicy{Strategy: CompactionSummarize, Summarizer: summarizer}.Fit(ctx, model, sess)
+		require.NoError(t, err)
+		require.True(t, res.Summarized)
+		require.Equal(t, sess.Messages, msgs)
+		require.Contains(t, MessageText(sess.Messages[0]), "They talked a lot.")
+		require.Equal(t, MessageAuthorAssistant, sess.Messages[1].GetAuthor())
+		require.Equal(t, MessageAuthorUser, sess.Messages[2].GetAuthor())
+		require.NotContains(t, sess.Preview(80), "They talked", "summary should not be used as preview")
+		require.Equal(t, Usage{InputTokens: 1_000, OutputTokens: 100, Cost: 0.002}, sess.Usage)
+	})
+}
+
+func TestContextPolicy_Compact(t *testing.T) {
+	sess := longSession(3)
+	res, err := ContextPolicy{Strategy: CompactionDrop}.Compact(context.Background(), sess, 1)
+	require.NoError(t, err)
+	require.Equal(t, 4, res.Removed)
+	require.Len(t, sess.Messages, 2)
+}
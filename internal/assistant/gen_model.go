@@ -1,14 +1,28 @@
 package assistant
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"iter"
+	"time"
 )
 
 type ModelDescriptor interface {
 	Name() string
 	Description() string
 	Provider() string
+
+	// ContextWindow returns the maximum number of tokens (prompt and output
+	// combined) the model accepts.
+	ContextWindow() int
+
+	// MaxOutputTokens returns the maximum number of tokens the model can
+	// generate in a single response.
+	MaxOutputTokens() int
+
+	// Pricing returns the list prices of the model.
+	Pricing() Pricing
 }
 
 // GenerativeModel represents a generative model.
@@ -36,6 +50,45 @@ type Usage struct {
 	OutputTokens      int `json:"output_tokens"`
 	CachedInputTokens int `json:"cached_input_tokens"` // subset of InputTokens served from a prompt cache
 	CacheWriteTokens  int `json:"cache_write_tokens"`  // tokens newly written to a prompt cache this call (0 if unsupported/not reported)
+
+	// Cost is the price of the generation in USD, see [Pricing.Cost].
+	// It is 0 if the pricing of the model is unknown.
+	Cost float64 `json:"cost,omitempty"`
+
+	// CacheSavings is how much less the generation cost in USD thanks to
+	// prompt caching, see [Pricing.CacheSavings]. Negative if cache writes
+	// were not (yet) paid back by reads.
+	CacheSavings float64 `json:"cache_savings,omitempty"`
+}
+
+// UsageRecord is the usage of a single generation.
+type UsageRecord struct {
+	Model string    `json:"model"` // qualified name, e.g. "anthropic:claude-haiku-4-5"
+	At    time.Time `json:"at"`
+	Usage
+}
+
+// Add adds the token counts and cost of o to u.
+func (u *Usage) Add(o *Usage) {
+	if o == nil {
+		return
+	}
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CachedInputTokens += o.CachedInputTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+	u.Cost += o.Cost
+	u.CacheSavings += o.CacheSavings
+}
+
+// sub subtracts the token counts and cost of o from u.
+func (u *Usage) sub(o Usage) {
+	u.InputTokens -= o.InputTokens
+	u.OutputTokens -= o.OutputTokens
+	u.CachedInputTokens -= o.CachedInputTokens
+	u.CacheWriteTokens -= o.CacheWriteTokens
+	u.Cost -= o.Cost
+	u.CacheSavings -= o.CacheSavings
 }
 
 // CacheHitRate returns the percentage of InputTokens served from a prompt cache.
@@ -45,3 +98,122 @@ func (u *Usage) CacheHitRate() float64 {
 	}
 	return 100 * float64(u.CachedInputTokens) / float64(u.InputTokens)
 }
+
+// Pricing holds the list prices of a model in USD per million tokens.
+// The zero value means the prices are unknown.
+type Pricing struct {
+	Input  float64 // uncached input tokens
+	Output float64
+
+	// CacheRead and CacheWrite price input tokens read from and written to
+	// a prompt cache. Zero means the same as Input.
+	CacheRead  float64
+	CacheWrite float64
+}
+
+// IsZero reports whether the prices are unknown.
+func (p Pricing) IsZero() bool {
+	return p == Pricing{}
+}
+
+// Cost returns the price in USD of a generation with the given usage.
+func (p Pricing) Cost(u *Usage) float64 {
+	if u == nil {
+		return 0
+	}
+	read, write := cmp.Or(p.CacheRead, p.Input), cmp.Or(p.CacheWrite, p.Input)
+	uncached := max(u.InputTokens-u.CachedInputTokens-u.CacheWriteTokens, 0)
+	total := float64(uncached)*p.Input +
+		float64(u.CachedInputTokens)*read +
+		float64(u.CacheWriteTokens)*write +
+		float64(u.OutputTokens)*p.Output
+	return total / 1_000_000
+}
+
+// CacheSavings returns how much less a generation with the given usage cost
+// than it would have without prompt caching, i.e. with every input token
+// priced as uncached input.
+func (p Pricing) CacheSavings(u *Usage) float64 {
+	if u == nil {
+		return 0
+	}
+	uncached := Pricing{Input: p.Input, Output: p.Output, CacheRead: p.Input, CacheWrite: p.Input}
+	return uncached.Cost(u) - p.Cost(u)
+}
+
+// CacheMode controls explicit prompt caching.
+type CacheMode string
+
+const (
+	// CacheOff sends no cache breakpoints.
+	CacheOff CacheMode = "off"
+
+	// CacheAuto places cache breakpoints with the provider's default
+	// lifetime (5 minutes for Anthropic).
+	CacheAuto CacheMode = "auto"
+
+	// Cache1h places cache breakpoints with a one hour lifetime. Cache
+	// writes cost more, but survive longer pauses between turns.
+	Cache1h CacheMode = "1h"
+)
+
+// ParseCacheMode parses a cache mode name. An empty name yields [CacheAuto].
+func ParseCacheMode(s string) (CacheMode, error) {
+	switch m := CacheMode(s); m {
+	case "":
+		return CacheAuto, nil
+	case CacheOff, CacheAuto, Cache1h:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cache mode %q (valid: %s, %s, %s)", s, CacheOff, CacheAuto, Cache1h)
+	}
+}
+
+// PromptCacher is implemented by models whose provider caches prompts only
+// at explicit breakpoints (e.g. Anthropic). Providers with automatic
+// caching, such as OpenAI, do not implement it.
+//
+// Models cache nothing until SetPromptCache is called.
+type PromptCacher interface {
+	SetPromptCache(CacheMode)
+}
+
+// Effort is how much a model should think before it replies.
+type Effort string
+
+const (
+	EffortLow    Effort = "low"
+	EffortMedium Effort = "medium"
+	EffortHigh   Effort = "high"
+)
+
+// ParseEffort parses an effort name. An empty name yields "", the default
+// of the model.
+func ParseEffort(s string) (Effort, error) {
+	switch e := Effort(s); e {
+	case "", EffortLow, EffortMedium, EffortHigh:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown effort %q (valid: %s, %s, %s)", s, EffortLow, EffortMedium, EffortHigh)
+	}
+}
+
+// GenerationParams tune a generation. Zero values leave the default of the
+// provider.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+
+	// Effort enables thinking on models that support it.
+	Effort Effort `json:"effort,omitempty"`
+}
+
+// IsZero reports whether p leaves every default.
+func (p GenerationParams) IsZero() bool {
+	return p.Temperature == nil && p.MaxTokens == 0 && p.Effort == ""
+}
+
+// ParamSetter is implemented by models that accept [GenerationParams].
+type ParamSetter interface {
+	SetGenerationParams(GenerationParams)
+}
@@ -0,0 +1,31 @@
+package assistant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricing_CacheSavings(t *testing.T) {
+	p := Pricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+
+	// 1M tokens read from the cache: $3.00 without caching, $0.30 with it.
+	read := &Usage{InputTokens: 1_000_000, CachedInputTokens: 1_000_000}
+	require.InDelta(t, 2.7, p.CacheSavings(read), 1e-9)
+
+	// Writing to the cache costs more than it saves.
+	write := &Usage{InputTokens: 1_000_000, CacheWriteTokens: 1_000_000}
+	require.InDelta(t, -0.75, p.CacheSavings(write), 1e-9)
+
+	require.Zero(t, p.CacheSavings(&Usage{InputTokens: 1000, OutputTokens: 100}))
+}
+
+func TestParseCacheMode(t *testing.T) {
+	for in, want := range map[string]CacheMode{"": CacheAuto, "off": CacheOff, "auto": CacheAuto, "1h": Cache1h} {
+		got, err := ParseCacheMode(in)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := ParseCacheMode("5m")
+	require.Error(t, err)
+}
@@ -3,6 +3,8 @@ package assistant
 import (
 	"encoding/json"
 	"net/url"
+	"strings"
+	"time"
 )
 
 type Message interface {
@@ -19,10 +21,12 @@ type Message interface {
 //	  "contents": [
 //	    {"text": "Hello, how are you?"},
 //	    {"url": "https://example.com/image.jpg"}
-//	  ]
+//	  ],
+//	  "created_at": "2025-01-01T00:00:00Z"
 //	}
 type UserMessage struct {
-	Contents []MessageContent `json:"contents"`
+	Contents  []MessageContent `json:"contents"`
+	CreatedAt time.Time        `json:"created_at,omitzero"`
 }
 
 var (
@@ -31,9 +35,9 @@ var (
 	_ json.Unmarshaler = (*UserMessage)(nil)
 )
 
-// NewUserMessage creates a new user message.
+// NewUserMessage creates a new user message stamped with the current time.
 func NewUserMessage(contents ...MessageContent) *UserMessage {
-	return &UserMessage{Contents: contents}
+	return &UserMessage{Contents: contents, CreatedAt: time.Now()}
 }
 
 func (u UserMessage) GetAuthor() MessageAuthor {
@@ -60,12 +64,14 @@ func (u UserMessage) MarshalJSON() ([]byte, error) {
 
 func (u *UserMessage) UnmarshalJSON(data []byte) error {
 	var aux struct {
-		Author   MessageAuthor     `json:"author"`
-		Contents []json.RawMessage `json:"contents"`
+		Author    MessageAuthor     `json:"author"`
+		Contents  []json.RawMessage `json:"contents"`
+		CreatedAt time.Time         `json:"created_at"`
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	u.CreatedAt = aux.CreatedAt
 
 	// Unmarshal each content by detecting its type
 	u.Contents = make([]MessageContent, 0, len(aux.Contents))
@@ -105,10 +111,20 @@ func (u *UserMessage) UnmarshalJSON(data []byte) error {
 //	  "author": "assistant",
 //	  "contents": [
 //	    {"text": "I'm fine, thank you!"}
-//	  ]
+//	  ],
+//	  "model": "anthropic:claude-haiku-4-5",
+//	  "usage": {"input_tokens": 12, "output_tokens": 8, "cached_input_tokens": 0, "cache_write_tokens": 0, "cost": 0.000052},
+//	  "created_at": "2025-01-01T00:00:01Z"
 //	}
 type AssistantMessage struct {
 	Contents []MessageContent `json:"contents"`
+
+	// Model is the qualified name of the model which generated this message.
+	Model string `json:"model,omitempty"`
+
+	// Usage is the token usage of the generation, if reported.
+	Usage     *Usage    `json:"usage,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
 }
 
 var (
@@ -117,9 +133,9 @@ var (
 	_ json.Unmarshaler = (*AssistantMessage)(nil)
 )
 
-// NewAssistantMessage creates a new assistant message.
+// NewAssistantMessage creates a new assistant message stamped with the current time.
 func NewAssistantMessage(contents ...MessageContent) *AssistantMessage {
-	return &AssistantMessage{Contents: contents}
+	return &AssistantMessage{Contents: contents, CreatedAt: time.Now()}
 }
 
 func (a AssistantMessage) GetAuthor() MessageAuthor {
@@ -146,12 +162,18 @@ func (a AssistantMessage) MarshalJSON() ([]byte, error) {
 
 func (a *AssistantMessage) UnmarshalJSON(data []byte) error {
 	var aux struct {
-		Author   MessageAuthor     `json:"author"`
-		Contents []json.RawMessage `json:"contents"`
+		Author    MessageAuthor     `json:"author"`
+		Contents  []json.RawMessage `json:"contents"`
+		Model     string            `json:"model"`
+		Usage     *Usage            `json:"usage"`
+		CreatedAt time.Time         `json:"created_at"`
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	a.Model = aux.Model
+	a.Usage = aux.Usage
+	a.CreatedAt = aux.CreatedAt
 
 	// Unmarshal each content by detecting its type
 	a.Contents = make([]MessageContent, 0, len(aux.Contents))
@@ -183,6 +205,45 @@ func (a *AssistantMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MessageText concatenates the text contents of msg.
+func MessageText(msg Message) string {
+	parts := []string{}
+	for _, c := range msg.GetContents() {
+		switch c := c.(type) {
+		case *TextContent:
+			parts = append(parts, c.Text)
+		case *AttachmentContent:
+			parts = append(parts, c.ToText())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// WithText returns a copy of msg whose text contents are replaced by a single
+// text content. Non-text contents (e.g. images) are kept after it.
+func WithText(msg Message, text string) Message {
+	contents := []MessageContent{NewTextContent(text)}
+	for _, c := range msg.GetContents() {
+		switch c.(type) {
+		case *TextContent, *AttachmentContent:
+		default:
+			contents = append(contents, c)
+		}
+	}
+	switch m := msg.(type) {
+	case *UserMessage:
+		cp := *m
+		cp.Contents = contents
+		return &cp
+	case *AssistantMessage:
+		cp := *m
+		cp.Contents = contents
+		return &cp
+	default:
+		return msg
+	}
+}
+
 // TODO: Remove this
 type MessageAuthor string
 
@@ -297,3 +358,29 @@ func (u *URLImageContent) UnmarshalJSON(data []byte) error {
 	}
 	return nil
 }
+
+// ToolCallContent is a request of the model to call a tool.
+//
+// Only the mock provider emits tool calls for now, so that clients can be
+// tested against them. They are not stored in session history.
+type ToolCallContent struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+var _ MessageContent = (*ToolCallContent)(nil)
+
+func (*ToolCallContent) isMessageContent() {}
+
+// ThinkingContent is the reasoning a model streams before its reply.
+//
+// Like tool calls, it is reported to clients but not stored in session
+// history.
+type ThinkingContent struct {
+	Text string `json:"thinking"`
+}
+
+var _ MessageContent = (*ThinkingContent)(nil)
+
+func (*ThinkingContent) isMessageContent() {}
@@ -0,0 +1,382 @@
+package assistant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"micheam.com/aico/internal/logging"
+)
+
+// ResponseCache stores model responses on disk, so that identical requests
+// are answered locally instead of being sent to the provider again.
+//
+// Each response is stored as a JSON file named after the hash of the request
+// (provider, model, generation parameters, system instruction and messages).
+// Entries older than the TTL are ignored and overwritten.
+type ResponseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewResponseCache returns a cache backed by the given directory.
+// A ttl of 0 keeps entries forever. The directory is created on first write.
+func NewResponseCache(dir string, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{dir: dir, ttl: ttl}
+}
+
+// Dir returns the directory the responses are stored in.
+func (c *ResponseCache) Dir() string { return c.dir }
+
+// Wrap returns a model that answers from the cache when possible, and
+// stores the responses of m otherwise.
+func (c *ResponseCache) Wrap(m GenerativeModel) GenerativeModel {
+	return &cachedModel{GenerativeModel: m, cache: c}
+}
+
+// CachedResponse is a response stored in a [ResponseCache].
+type CachedResponse struct {
+	Model     string    `json:"model"` // qualified name, e.g. "anthropic:claude-haiku-4-5"
+	CreatedAt time.Time `json:"created_at"`
+
+	// Chunks are the texts of the response in the order they were streamed.
+	Chunks []string `json:"chunks"`
+
+	// Usage is the usage of the original generation.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Hits counts how often the response was served from the cache.
+	Hits    int       `json:"hits,omitempty"`
+	LastHit time.Time `json:"last_hit,omitzero"`
+}
+
+// Text returns the whole text of the response.
+func (r *CachedResponse) Text() string {
+	return strings.Join(r.Chunks, "")
+}
+
+type freshResponseKey struct{}
+
+// WithFreshResponse returns a context under which cached models skip the
+// cache lookup. The new response still replaces the cached one.
+func WithFreshResponse(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshResponseKey{}, true)
+}
+
+func wantsFreshResponse(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshResponseKey{}).(bool)
+	return fresh
+}
+
+// Key returns the cache key of a request to m with the given parameters,
+// system instruction and messages.
+//
+// Only the author and contents of messages are taken into account, so a
+// replayed history hits the cache regardless of when it was written.
+func (c *ResponseCache) Key(m ModelDescriptor, params GenerationParams, system []*TextContent, msgs []Message) (string, error) {
+	type message struct {
+		Author   MessageAuthor    `json:"author"`
+		Contents []MessageContent `json:"contents"`
+	}
+	req := struct {
+		Version  int               `json:"v"`
+		Provider string            `json:"provider"`
+		Model    string            `json:"model"`
+		Params   *GenerationParams `json:"params,omitempty"` // omitted if zero, to keep older keys
+		System   []*TextContent    `json:"system"`
+		Messages []message         `json:"messages"`
+	}{
+		Version:  1,
+		Provider: m.Provider(),
+		Model:    m.Name(),
+		System:   system,
+	}
+	if !params.IsZero() {
+		req.Params = &params
+	}
+	for _, msg := range msgs {
+		req.Messages = append(req.Messages, message{msg.GetAuthor(), msg.GetContents()})
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get returns the unexpired response stored under key, or nil if there is
+// none.
+func (c *ResponseCache) Get(key string) (*CachedResponse, error) {
+	resp, err := readCachedResponse(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.expired(resp, time.Now()) {
+		return nil, nil
+	}
+	return resp, nil
+}
+
+// Put stores resp under key.
+func (c *ResponseCache) Put(key string, resp *CachedResponse) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("marshal response: %w", err)
+	}
+	return writeFileAtomic(c.path(key), b, 0644)
+}
+
+func (c *ResponseCache) expired(resp *CachedResponse, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(resp.CreatedAt) > c.ttl
+}
+
+func readCachedResponse(path string) (*CachedResponse, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+	return &resp, nil
+}
+
+// ResponseCacheStats summarizes the contents of a [ResponseCache].
+type ResponseCacheStats struct {
+	Entries int   `json:"entries"`
+	Expired int   `json:"expired"`
+	Bytes   int64 `json:"bytes"`
+	Hits    int   `json:"hits"`
+
+	// Saved is the cost in USD of the generations that were served from the
+	// cache instead.
+	Saved float64 `json:"saved"`
+}
+
+// Stats scans the cache directory. A missing directory is an empty cache.
+func (c *ResponseCache) Stats() (ResponseCacheStats, error) {
+	var stats ResponseCacheStats
+	now := time.Now()
+	err := c.walk(func(path string, info os.FileInfo) error {
+		resp, err := readCachedResponse(path)
+		if err != nil {
+			return nil // not ours, or half written
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+		stats.Hits += resp.Hits
+		if resp.Usage != nil {
+			stats.Saved += float64(resp.Hits) * resp.Usage.Cost
+		}
+		if c.expired(resp, now) {
+			stats.Expired++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Clear removes cached responses and returns how many were removed. If
+// expiredOnly is set, unexpired responses are kept.
+func (c *ResponseCache) Clear(expiredOnly bool) (int, error) {
+	var removed int
+	now := time.Now()
+	err := c.walk(func(path string, _ os.FileInfo) error {
+		if expiredOnly {
+			resp, err := readCachedResponse(path)
+			if err == nil && !c.expired(resp, now) {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func (c *ResponseCache) walk(fn func(path string, info os.FileInfo) error) error {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cache dir: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if err := fn(filepath.Join(c.dir, e.Name()), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -------------------------------------------
+// Cached model
+// -------------------------------------------
+
+// cachedModel is a GenerativeModel that answers from a ResponseCache.
+//
+// Cache failures are logged and never fail a generation. Responses served
+// from the cache carry no usage, as nothing was billed for them.
+type cachedModel struct {
+	GenerativeModel
+	cache  *ResponseCache
+	system []*TextContent
+	params GenerationParams
+}
+
+var _ ParamSetter = (*cachedModel)(nil)
+
+func (m *cachedModel) SetSystemInstruction(contents ...*TextContent) {
+	m.system = contents
+	m.GenerativeModel.SetSystemInstruction(contents...)
+}
+
+// SetGenerationParams passes p on to the wrapped model if it accepts
+// parameters.
+func (m *cachedModel) SetGenerationParams(p GenerationParams) {
+	m.params = p
+	if ps, ok := m.GenerativeModel.(ParamSetter); ok {
+		ps.SetGenerationParams(p)
+	}
+}
+
+// lookup returns the cache key for msgs and the cached response, if any.
+func (m *cachedModel) lookup(ctx context.Context, msgs []Message) (string, *CachedResponse) {
+	logger := logging.LoggerFrom(ctx)
+	key, err := m.cache.Key(m, m.params, m.system, msgs)
+	if err != nil {
+		logger.Warn("skip response cache", "error", err)
+		return "", nil
+	}
+	if wantsFreshResponse(ctx) {
+		return key, nil
+	}
+	resp, err := m.cache.Get(key)
+	if err != nil {
+		logger.Warn("read response cache", "key", key, "error", err)
+		return key, nil
+	}
+	if resp == nil {
+		return key, nil
+	}
+	logger.Debug("response served from cache", "key", key, "created_at", resp.CreatedAt)
+	resp.Hits++
+	resp.LastHit = time.Now()
+	if err := m.cache.Put(key, resp); err != nil {
+		logger.Warn("update response cache", "key", key, "error", err)
+	}
+	return key, resp
+}
+
+func (m *cachedModel) store(ctx context.Context, key string, chunks []string, usage *Usage) {
+	if key == "" {
+		return
+	}
+	if usage != nil && usage.Cost == 0 {
+		u := *usage
+		u.Cost = m.Pricing().Cost(&u)
+		usage = &u
+	}
+	resp := &CachedResponse{
+		Model:     m.Provider() + ":" + m.Name(),
+		CreatedAt: time.Now(),
+		Chunks:    chunks,
+		Usage:     usage,
+	}
+	if err := m.cache.Put(key, resp); err != nil {
+		logging.LoggerFrom(ctx).Warn("write response cache", "key", key, "error", err)
+	}
+}
+
+func (m *cachedModel) GenerateContent(ctx context.Context, msgs ...Message) (*GenerateContentResponse, error) {
+	key, cached := m.lookup(ctx, msgs)
+	if cached != nil {
+		return &GenerateContentResponse{Content: NewTextContent(cached.Text())}, nil
+	}
+	resp, err := m.GenerativeModel.GenerateContent(ctx, msgs...)
+	if err != nil {
+		return nil, err
+	}
+	if text, ok := resp.Content.(*TextContent); ok {
+		m.store(ctx, key, []string{text.Text}, resp.Usage)
+	}
+	return resp, nil
+}
+
+func (m *cachedModel) GenerateContentStream(ctx context.Context, msgs ...Message) (iter.Seq2[*GenerateContentResponse, error], error) {
+	key, cached := m.lookup(ctx, msgs)
+	if cached != nil {
+		return func(yield func(*GenerateContentResponse, error) bool) {
+			for _, chunk := range cached.Chunks {
+				if !yield(&GenerateContentResponse{Content: NewTextContent(chunk)}, nil) {
+					return
+				}
+			}
+		}, nil
+	}
+
+	stream, err := m.GenerativeModel.GenerateContentStream(ctx, msgs...)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(*GenerateContentResponse, error) bool) {
+		var (
+			chunks   []string
+			usage    *Usage
+			complete = true
+		)
+		for resp, err := range stream {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			switch {
+			case resp.Usage != nil:
+				usage = resp.Usage
+			case resp.Content != nil:
+				if text, ok := resp.Content.(*TextContent); ok {
+					chunks = append(chunks, text.Text)
+				} else {
+					complete = false // cannot be replayed
+				}
+			}
+			if !yield(resp, nil) {
+				return
+			}
+		}
+		if complete && len(chunks) > 0 {
+			m.store(ctx, key, chunks, usage)
+		}
+	}, nil
+}
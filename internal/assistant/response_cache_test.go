@@ -0,0 +1,103 @@
+package assistant
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache_Wrap(t *testing.T) {
+	ctx := context.Background()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+	fake := &fakeModel{reply: "hello cached world"}
+	model := cache.Wrap(fake)
+	model.SetSystemInstruction(NewTextContent("be brief"))
+
+	// stream collects the text and usage of a streamed reply.
+	stream := func(ctx context.Context, msgs ...Message) (string, *Usage) {
+		seq, err := model.GenerateContentStream(ctx, msgs...)
+		require.NoError(t, err)
+		var text strings.Builder
+		var usage *Usage
+		for resp, err := range seq {
+			require.NoError(t, err)
+			if resp.Usage != nil {
+				usage = resp.Usage
+				continue
+			}
+			text.WriteString(resp.Content.(*TextContent).Text)
+		}
+		return text.String(), usage
+	}
+	prompt := func() Message { return NewUserMessage(NewTextContent("hi")) }
+
+	text, usage := stream(ctx, prompt())
+	require.Equal(t, "hello cached world", text)
+	require.NotNil(t, usage)
+	require.Equal(t, 1, fake.calls)
+
+	// An identical request, written at another time, is replayed locally
+	// and carries no usage.
+	text, usage = stream(ctx, prompt())
+	require.Equal(t, "hello cached world", text)
+	require.Nil(t, usage)
+	require.Equal(t, 1, fake.calls)
+
+	resp, err := model.GenerateContent(ctx, prompt())
+	require.NoError(t, err)
+	require.Equal(t, "hello cached world", resp.Content.(*TextContent).Text)
+	require.Equal(t, 1, fake.calls)
+
+	// A different system instruction is a different request.
+	model.SetSystemInstruction(NewTextContent("be verbose"))
+	stream(ctx, prompt())
+	require.Equal(t, 2, fake.calls)
+
+	// WithFreshResponse skips the lookup.
+	stream(WithFreshResponse(ctx), prompt())
+	require.Equal(t, 3, fake.calls)
+
+	// So are other generation parameters, which reach the model.
+	temperature := 0.2
+	params := GenerationParams{Temperature: &temperature, Effort: EffortHigh}
+	model.(ParamSetter).SetGenerationParams(params)
+	stream(ctx, prompt())
+	require.Equal(t, 4, fake.calls)
+	require.Equal(t, params, fake.params)
+	model.(ParamSetter).SetGenerationParams(GenerationParams{})
+	stream(ctx, prompt())
+	require.Equal(t, 4, fake.calls)
+
+	stats, err := cache.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.Entries)
+	require.Equal(t, 3, stats.Hits)
+	require.InDelta(t, 3*0.002, stats.Saved, 1e-9) // 1,000 in at $1 + 100 out at $10 per MTok
+
+	n, err := cache.Clear(true)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = cache.Clear(false)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+}
+
+func TestResponseCache_Expired(t *testing.T) {
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+	require.NoError(t, cache.Put("k", &CachedResponse{CreatedAt: time.Now().Add(-2 * time.Hour), Chunks: []string{"old"}}))
+
+	resp, err := cache.Get("k")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	stats, err := cache.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Expired)
+
+	n, err := cache.Clear(true)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
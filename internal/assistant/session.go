@@ -1,28 +1,51 @@
 package assistant
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-
-	"micheam.com/aico/internal/logging"
 )
 
 type Session struct {
 	ID                string         `json:"id"`
+	Title             string         `json:"title,omitempty"`
+	Tags              []string       `json:"tags,omitempty"`
 	Model             string         `json:"model,omitempty"`
+	Persona           string         `json:"persona,omitempty"`
+	Profile           string         `json:"profile,omitempty"` // config profile the session was created with
 	SystemInstruction []*TextContent `json:"system_instruction"`
 	Messages          []Message      `json:"messages"`
+	CreatedAt         time.Time      `json:"created_at,omitzero"`
+	UpdatedAt         time.Time      `json:"updated_at,omitzero"`
+
+	// Usage is the running total of every generation made for the session,
+	// including titles and summaries. It is not reduced when messages are
+	// removed.
+	Usage Usage `json:"usage,omitzero"`
+
+	// UsageLog records each generation behind Usage, oldest first.
+	UsageLog []UsageRecord `json:"usage_log,omitempty"`
 
-	filePath string `json:"-"`
+	store SessionStore `json:"-"`
+	saved savedState   `json:"-"`
+
+	// rewritten is set when existing messages were removed or replaced
+	// since the last save, so that a concurrent update is not merged in.
+	rewritten bool `json:"-"`
+
+	// locked is set while the session is locked by [SessionLocker.LoadLocked],
+	// so that saving it does not wait for the lock.
+	locked bool `json:"-"`
 }
 
 func (s Session) GetMessages() []Message {
@@ -33,106 +56,274 @@ func (s Session) GetMessages() []Message {
 
 func (s *Session) AddMessage(message Message) {
 	s.Messages = append(s.Messages, message)
+	s.UpdatedAt = time.Now()
 }
 
 func (s *Session) AddMessages(messages ...Message) {
 	s.Messages = append(s.Messages, messages...)
+	s.UpdatedAt = time.Now()
 }
 
-// NewSession creates a new session with a unique ID and optional initial messages.
-// If no messages are provided, the session will start empty.
-//
-// Note: Loading an existing session should be done via [LoadSession].
-func NewSession(dir string, messages ...Message) *Session {
-	id := uuid.NewString()
-	return &Session{
-		ID:       id,
-		Messages: messages,
-		filePath: sessionFilePath(dir, id),
+// AddUsage adds the usage of a generation by model to the session totals
+// and the usage log. u is priced with the model's [Pricing] unless its cost
+// is already set.
+func (s *Session) AddUsage(model ModelDescriptor, u *Usage) {
+	if u == nil {
+		return
+	}
+	if u.Cost == 0 {
+		u.Cost = model.Pricing().Cost(u)
+		u.CacheSavings = model.Pricing().CacheSavings(u)
 	}
+	s.Usage.Add(u)
+	s.UsageLog = append(s.UsageLog, UsageRecord{
+		Model: model.Provider() + ":" + model.Name(),
+		At:    time.Now(),
+		Usage: *u,
+	})
 }
 
-// -------------------------------------------
-// Helper: Load/Save Session
-// -------------------------------------------
+// UsageRecords returns the usage of each generation made for the session.
+// Sessions saved before the usage log existed fall back to the usage
+// recorded on their replies.
+func (s Session) UsageRecords() []UsageRecord {
+	if len(s.UsageLog) > 0 || s.Usage == (Usage{}) {
+		return s.UsageLog
+	}
+	var records []UsageRecord
+	for _, m := range s.Messages {
+		if am, ok := m.(*AssistantMessage); ok && am.Usage != nil {
+			records = append(records, UsageRecord{Model: am.Model, At: am.CreatedAt, Usage: *am.Usage})
+		}
+	}
+	return records
+}
 
-func sessionFilePath(dir, id string) string {
-	return filepath.Join(dir, id+".json")
+// ReplaceMessage replaces the i-th (0-based) message.
+func (s *Session) ReplaceMessage(i int, msg Message) {
+	s.Messages[i] = msg
+	s.rewritten = true
+	s.UpdatedAt = time.Now()
 }
 
-func (s *Session) FilePath() string {
-	return s.filePath
+// Truncate keeps the first n messages and removes the rest.
+// It returns the number of removed messages.
+func (s *Session) Truncate(n int) int {
+	if n >= len(s.Messages) {
+		return 0
+	}
+	removed := len(s.Messages) - max(n, 0)
+	s.setMessages(s.Messages[:max(n, 0)])
+	return removed
 }
 
-// LoadSession tries to load an existing session by dir and id.
-// It returns error if no session file found with the given id.
-func LoadSession(dir, id string) (*Session, error) {
-	if id == "" {
-		return nil, errors.New("id must be specified")
+// TrimTrailingReplies removes the assistant messages after the last user
+// message, e.g. to regenerate the reply, and returns them.
+func (s *Session) TrimTrailingReplies() []Message {
+	n := len(s.Messages)
+	for n > 0 && s.Messages[n-1].GetAuthor() == MessageAuthorAssistant {
+		n--
 	}
-	if dir == "" {
-		return nil, errors.New("base dir must be specified")
+	removed := slices.Clone(s.Messages[n:])
+	s.Truncate(n)
+	return removed
+}
+
+// DropLastExchange removes the last user message and every message after
+// it. It returns the number of removed messages.
+func (s *Session) DropLastExchange() int {
+	starts := turnStarts(s.Messages)
+	if len(starts) == 0 {
+		return s.Truncate(0)
 	}
-	f, err := os.Open(sessionFilePath(dir, id))
-	if err != nil {
-		return nil, fmt.Errorf("open session: %w", err)
+	return s.Truncate(starts[len(starts)-1])
+}
+
+// LastMessage returns the last message of the session, or nil if empty.
+func (s Session) LastMessage() Message {
+	if len(s.Messages) == 0 {
+		return nil
 	}
-	defer f.Close()
-	sess, err := decodeSession(f)
-	if err != nil {
-		return nil, fmt.Errorf("decode found session: %w", err)
+	return s.Messages[len(s.Messages)-1]
+}
+
+// setMessages replaces the whole history.
+func (s *Session) setMessages(msgs []Message) {
+	s.Messages = msgs
+	s.rewritten = true
+	s.UpdatedAt = time.Now()
+}
+
+// AddTags adds the given tags to the session, ignoring blanks and duplicates.
+// Tags are kept sorted.
+func (s *Session) AddTags(tags ...string) {
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || slices.Contains(s.Tags, t) {
+			continue
+		}
+		s.Tags = append(s.Tags, t)
 	}
-	// Set Unexported fields...
-	sess.filePath = sessionFilePath(dir, id)
-	return sess, nil
+	slices.Sort(s.Tags)
+	s.UpdatedAt = time.Now()
 }
 
-// LoadLatestSession loads last used session and returns it.
-func LoadLatestSession(dir string) (*Session, error) {
-	id, err := latestSessionID(dir)
+// RemoveTags removes the given tags from the session.
+func (s *Session) RemoveTags(tags ...string) {
+	s.Tags = slices.DeleteFunc(s.Tags, func(t string) bool {
+		return slices.Contains(tags, t)
+	})
+	s.UpdatedAt = time.Now()
+}
+
+// HasTag reports whether the session is tagged with tag.
+func (s Session) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
+
+// NeedsTitle reports whether the session has completed its first exchange
+// but has not been titled yet.
+func (s Session) NeedsTitle() bool {
+	if s.Title != "" {
+		return false
+	}
+	var user, asst bool
+	for _, m := range s.Messages {
+		switch m.GetAuthor() {
+		case MessageAuthorUser:
+			user = true
+		case MessageAuthorAssistant:
+			asst = true
+		}
+	}
+	return user && asst
+}
+
+// NewSession creates a new session in store with a unique ID and optional
+// initial messages. If no messages are provided, the session will start empty.
+//
+// Note: Loading an existing session should be done via [SessionStore.Load].
+func NewSession(store SessionStore, messages ...Message) *Session {
+	id := uuid.NewString()
+	now := time.Now()
+	return &Session{
+		ID:        id,
+		Messages:  messages,
+		CreatedAt: now,
+		UpdatedAt: now,
+		store:     store,
+	}
+}
+
+// Save persists the session to the store it was created in or loaded from.
+func (s *Session) Save(ctx context.Context) error {
+	if s.store == nil {
+		return errors.New("session is not bound to a store")
+	}
+	return s.store.Save(ctx, s)
+}
+
+// LoadLatestSession loads the most recently updated session from store.
+func LoadLatestSession(ctx context.Context, store SessionStore) (*Session, error) {
+	summaries, err := store.List(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return LoadSession(dir, id)
+	if len(summaries) == 0 {
+		return nil, fmt.Errorf("no sessions found")
+	}
+	return store.Load(ctx, summaries[0].ID)
 }
 
-// Save saves the session to a file.
-// This will overwrite any existing session file.
-func (s *Session) Save(ctx context.Context, model GenerativeModel) error {
-	logger := logging.LoggerFrom(ctx)
-
-	dir := filepath.Dir(s.FilePath())
-	stat, err := os.Stat(dir)
-	if err != nil || !stat.IsDir() {
-		if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
-			return fmt.Errorf("failed to create session directory: %w", mkErr)
-		}
+// LoadSessionLocked loads the session with the given id for a
+// read-modify-write, locked against other processes until unlock is called
+// if store is a [SessionLocker]. Other stores only merge concurrent updates
+// when the session is saved.
+func LoadSessionLocked(ctx context.Context, store SessionStore, id string) (sess *Session, unlock func(), err error) {
+	if l, ok := store.(SessionLocker); ok {
+		return l.LoadLocked(ctx, id)
 	}
-	f, err := os.OpenFile(s.FilePath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	sess, err = store.Load(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to open session file for writing: %w", err)
+		return nil, nil, err
+	}
+	return sess, func() {}, nil
+}
+
+// savedState remembers what a session looked like in its store when it was
+// last loaded or saved, so that stores can detect concurrent updates.
+type savedState struct {
+	digest   [sha256.Size]byte
+	msgCount int
+	tags     []string
+	usage    Usage
+	logCount int
+}
+
+func (s *Session) markSaved(data []byte) {
+	s.rewritten = false
+	s.saved = savedState{
+		digest:   sha256.Sum256(data),
+		msgCount: len(s.Messages),
+		tags:     slices.Clone(s.Tags),
+		usage:    s.Usage,
+		logCount: len(s.UsageLog),
+	}
+}
+
+// changedSince reports whether the stored encoding of the session differs
+// from the one it was last loaded from or saved as.
+func (s *Session) changedSince(stored []byte) bool {
+	return sha256.Sum256(stored) != s.saved.digest
+}
+
+// merge folds a newer stored version of the session into s.
+//
+// Messages appended to s since it was loaded are moved after the stored
+// messages. If s was rewritten rather than appended to (e.g. truncated or
+// edited), s wins as-is. Title and tags set on s take precedence.
+func (s *Session) merge(stored *Session) {
+	if !s.rewritten && len(s.Messages) >= s.saved.msgCount {
+		added := s.Messages[s.saved.msgCount:]
+		s.Messages = append(stored.GetMessages(), added...)
+	}
+	if s.Title == "" {
+		s.Title = stored.Title
+	}
+	if slices.Equal(s.Tags, s.saved.tags) {
+		s.Tags = stored.Tags
+	}
+	// Usage spent since load is added to the stored total.
+	s.Usage.sub(s.saved.usage)
+	s.Usage.Add(&stored.Usage)
+	if len(s.UsageLog) >= s.saved.logCount {
+		s.UsageLog = append(slices.Clone(stored.UsageLog), s.UsageLog[s.saved.logCount:]...)
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = stored.CreatedAt
 	}
-	defer f.Close()
-	logger.Debug("saving session", "file", s.FilePath(), "model", model.Name())
-	return s.encode(f)
+	s.UpdatedAt = time.Now()
 }
 
 // -------------------------------------------
-// Helper: Encoding/Decoding a Session to/from a file
+// Helper: Encoding/Decoding a Session to/from its stored form
 // -------------------------------------------
 
-func decodeSession(file *os.File) (*Session, error) {
+func decodeSession(r io.Reader) (*Session, error) {
 	var sess Session
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(r)
 	if err := decoder.Decode(&sess); err != nil {
 		return nil, err
 	}
 	return &sess, nil
 }
 
-func (s *Session) encode(file *os.File) error {
-	encoder := json.NewEncoder(file)
-	return encoder.Encode(s)
+func (s *Session) encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 var (
@@ -152,9 +343,17 @@ func (s Session) MarshalJSON() ([]byte, error) {
 func (s *Session) UnmarshalJSON(data []byte) error {
 	var temp struct {
 		ID                string            `json:"id"`
+		Title             string            `json:"title,omitempty"`
+		Tags              []string          `json:"tags,omitempty"`
 		Model             string            `json:"model,omitempty"`
+		Persona           string            `json:"persona,omitempty"`
+		Profile           string            `json:"profile,omitempty"`
 		SystemInstruction []json.RawMessage `json:"system_instruction"`
 		Messages          []json.RawMessage `json:"messages"`
+		CreatedAt         time.Time         `json:"created_at"`
+		UpdatedAt         time.Time         `json:"updated_at"`
+		Usage             Usage             `json:"usage"`
+		UsageLog          []UsageRecord     `json:"usage_log"`
 	}
 
 	if err := json.Unmarshal(data, &temp); err != nil {
@@ -162,7 +361,15 @@ func (s *Session) UnmarshalJSON(data []byte) error {
 	}
 
 	s.ID = temp.ID
+	s.Title = temp.Title
+	s.Tags = temp.Tags
 	s.Model = temp.Model
+	s.Persona = temp.Persona
+	s.Profile = temp.Profile
+	s.CreatedAt = temp.CreatedAt
+	s.UpdatedAt = temp.UpdatedAt
+	s.Usage = temp.Usage
+	s.UsageLog = temp.UsageLog
 
 	// Unmarshal system instructions
 	s.SystemInstruction = make([]*TextContent, 0, len(temp.SystemInstruction))
@@ -215,71 +422,43 @@ func (s *Session) UnmarshalJSON(data []byte) error {
 
 // SessionSummary holds lightweight metadata for session listing.
 type SessionSummary struct {
-	ID       string
-	ModTime  time.Time
-	Preview  string // first user message preview
-	MsgCount int
+	ID        string
+	Title     string
+	Tags      []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Preview   string // first user message preview
+	MsgCount  int
+	Usage     Usage
 }
 
-// ListSessions returns summaries of all sessions in the given directory,
-// sorted by modification time (newest first).
-func ListSessions(dir string) ([]SessionSummary, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, fmt.Errorf("read session dir: %w", err)
+// DisplayTitle returns the title of the session, or the preview of the
+// first user message if the session has not been titled yet.
+func (s SessionSummary) DisplayTitle() string {
+	if s.Title != "" {
+		return s.Title
 	}
-
-	var summaries []SessionSummary
-	for _, e := range entries {
-		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
-			continue
-		}
-		info, err := e.Info()
-		if err != nil {
-			continue
-		}
-		id := strings.TrimSuffix(e.Name(), ".json")
-		preview, msgCount := sessionPreview(filepath.Join(dir, e.Name()))
-		summaries = append(summaries, SessionSummary{
-			ID:       id,
-			ModTime:  info.ModTime(),
-			Preview:  preview,
-			MsgCount: msgCount,
-		})
-	}
-
-	slices.SortFunc(summaries, func(a, b SessionSummary) int {
-		return b.ModTime.Compare(a.ModTime) // newest first
-	})
-	return summaries, nil
+	return s.Preview
 }
 
-// latestSessionID returns the ID of the most recently modified session.
-func latestSessionID(dir string) (string, error) {
-	summaries, err := ListSessions(dir)
-	if err != nil {
-		return "", err
-	}
-	if len(summaries) == 0 {
-		return "", fmt.Errorf("no sessions found")
+// Summary returns the listing metadata of the session.
+func (s Session) Summary() SessionSummary {
+	return SessionSummary{
+		ID:        s.ID,
+		Title:     s.Title,
+		Tags:      s.Tags,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Preview:   s.Preview(80),
+		MsgCount:  len(s.Messages),
+		Usage:     s.Usage,
 	}
-	return summaries[0].ID, nil
 }
 
-// sessionPreview reads a session file and extracts the first user message text.
-func sessionPreview(path string) (string, int) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", 0
-	}
-	defer f.Close()
-
-	sess, err := decodeSession(f)
-	if err != nil {
-		return "", 0
-	}
-
-	for _, msg := range sess.Messages {
+// Preview returns the text of the first user message, truncated to at most
+// maxRunes characters. Source and context blocks are skipped.
+func (s Session) Preview(maxRunes int) string {
+	for _, msg := range s.Messages {
 		if msg.GetAuthor() != MessageAuthorUser {
 			continue
 		}
@@ -289,14 +468,22 @@ func sessionPreview(path string) (string, int) {
 				continue
 			}
 			text := tc.Text
-			if strings.HasPrefix(text, "<source") || strings.HasPrefix(text, "<context") {
+			if strings.HasPrefix(text, "<source") || strings.HasPrefix(text, "<context") ||
+				strings.HasPrefix(text, "<"+summaryTag) {
 				continue
 			}
-			if len(text) > 80 {
-				text = text[:80] + "..."
-			}
-			return text, len(sess.Messages)
+			return truncate(strings.Join(strings.Fields(text), " "), maxRunes)
 		}
 	}
-	return "(empty)", len(sess.Messages)
+	return "(empty)"
+}
+
+// truncate shortens s to at most n runes, appending "..." when cut.
+// It never splits a multi-byte character.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "..."
 }
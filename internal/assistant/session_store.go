@@ -0,0 +1,301 @@
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"micheam.com/aico/internal/logging"
+)
+
+// ErrSessionNotFound is returned when a session does not exist in a store.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists chat sessions.
+//
+// Implementations must make [SessionStore.Save] safe against concurrent
+// writers: if the stored session changed since it was loaded, the changes
+// are merged rather than overwritten. Stores that can also lock a session
+// from load to save implement [SessionLocker].
+type SessionStore interface {
+	// Load loads the session with the given id.
+	// It returns an error wrapping [ErrSessionNotFound] if there is none.
+	Load(ctx context.Context, id string) (*Session, error)
+
+	// Save persists the session, binding it to this store.
+	Save(ctx context.Context, sess *Session) error
+
+	// List returns summaries of all sessions, newest first.
+	List(ctx context.Context) ([]SessionSummary, error)
+
+	// Delete removes the session with the given id.
+	Delete(ctx context.Context, id string) error
+
+	// Close releases resources held by the store.
+	Close() error
+}
+
+// CostReporter is implemented by session stores that can total the cost of
+// generations without loading every session.
+type CostReporter interface {
+	// CostSince returns the cost in USD of the generations made at or after
+	// since, across all sessions.
+	CostSince(ctx context.Context, since time.Time) (float64, error)
+}
+
+// SessionLocker is implemented by session stores that can lock a session
+// against other processes for a read-modify-write, such as undo or edit,
+// which the merge on save cannot reconcile. See [LoadSessionLocked].
+type SessionLocker interface {
+	// LoadLocked loads the session with the given id like Load and locks it
+	// until unlock is called. Saves of the session in between keep the lock.
+	LoadLocked(ctx context.Context, id string) (sess *Session, unlock func(), err error)
+}
+
+// -------------------------------------------
+// JSON directory store
+// -------------------------------------------
+
+// JSONStore stores each session as a JSON file named "<id>.json" in a
+// directory. It is the default session store.
+type JSONStore struct {
+	dir string
+}
+
+var (
+	_ SessionStore  = (*JSONStore)(nil)
+	_ SessionLocker = (*JSONStore)(nil)
+)
+
+// NewJSONStore returns a store backed by the given directory.
+// The directory is created on first save.
+func NewJSONStore(dir string) *JSONStore {
+	return &JSONStore{dir: dir}
+}
+
+// Dir returns the directory the sessions are stored in.
+func (st *JSONStore) Dir() string { return st.dir }
+
+// Path returns the file path of the session with the given id.
+func (st *JSONStore) Path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+func (st *JSONStore) Load(_ context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, errors.New("id must be specified")
+	}
+	if st.dir == "" {
+		return nil, errors.New("base dir must be specified")
+	}
+	data, err := os.ReadFile(st.Path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	sess, err := decodeSession(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode found session: %w", err)
+	}
+	sess.store = st
+	sess.markSaved(data)
+	return sess, nil
+}
+
+// LoadLocked loads the session with the given id, holding the advisory lock
+// of [JSONStore.Save] until unlock is called.
+func (st *JSONStore) LoadLocked(ctx context.Context, id string) (*Session, func(), error) {
+	if err := os.MkdirAll(st.dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	unlock, err := lockSession(st.Path(id))
+	if err != nil {
+		return nil, nil, fmt.Errorf("lock session: %w", err)
+	}
+	sess, err := st.Load(ctx, id)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	sess.locked = true
+	return sess, func() {
+		sess.locked = false
+		unlock()
+	}, nil
+}
+
+// Save saves the session to its file.
+//
+// The file is written atomically: the session is encoded into a temporary
+// file in the same directory, which is then renamed over the target, so a
+// crash never leaves a truncated session behind.
+//
+// Concurrent writers are serialized with an advisory lock, which is already
+// held for sessions loaded with [JSONStore.LoadLocked]. If the file was
+// changed by another process since this session was loaded, the other
+// process's messages are kept and the messages added here are appended
+// after them.
+func (st *JSONStore) Save(ctx context.Context, s *Session) error {
+	logger := logging.LoggerFrom(ctx)
+	path := st.Path(s.ID)
+
+	if err := os.MkdirAll(st.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	if !s.locked {
+		unlock, err := lockSession(path)
+		if err != nil {
+			return fmt.Errorf("lock session: %w", err)
+		}
+		defer unlock()
+	}
+
+	current, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// first save
+	case err != nil:
+		return fmt.Errorf("read session file: %w", err)
+	case s.changedSince(current):
+		stored, err := decodeSession(bytes.NewReader(current))
+		if err != nil {
+			return fmt.Errorf("decode session file changed on disk: %w", err)
+		}
+		logger.Info("session changed on disk since load; merging",
+			"file", path, "messages_on_disk", len(stored.Messages))
+		s.merge(stored)
+	}
+
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = time.Now()
+	}
+	data, err := s.encode()
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	logger.Debug("saving session", "file", path, "model", s.Model)
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("write session file: %w", err)
+	}
+	s.store = st
+	s.markSaved(data)
+	return nil
+}
+
+// List returns summaries of all sessions in the directory, sorted by update
+// time (newest first).
+//
+// Sessions saved before timestamps were recorded fall back to the file
+// modification time.
+func (st *JSONStore) List(_ context.Context) ([]SessionSummary, error) {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read session dir: %w", err)
+	}
+
+	var summaries []SessionSummary
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		f, err := os.Open(filepath.Join(st.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		sess, err := decodeSession(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		summary := sess.Summary()
+		summary.ID = strings.TrimSuffix(e.Name(), ".json")
+		if summary.UpdatedAt.IsZero() {
+			summary.UpdatedAt = info.ModTime()
+		}
+		if summary.CreatedAt.IsZero() {
+			summary.CreatedAt = summary.UpdatedAt
+		}
+		summaries = append(summaries, summary)
+	}
+
+	slices.SortFunc(summaries, func(a, b SessionSummary) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt) // newest first
+	})
+	return summaries, nil
+}
+
+func (st *JSONStore) Delete(_ context.Context, id string) error {
+	path := st.Path(id)
+	unlock, err := lockSession(path)
+	if err != nil {
+		return fmt.Errorf("lock session: %w", err)
+	}
+	defer unlock()
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return err
+}
+
+func (st *JSONStore) Close() error { return nil }
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path once the data is flushed to disk.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockSession takes an exclusive advisory lock for the session file at path.
+// The lock is held on a separate file under ".locks", because the session
+// file itself is replaced on every save.
+func lockSession(path string) (unlock func(), err error) {
+	lockDir := filepath.Join(filepath.Dir(path), ".locks")
+	if err := os.MkdirAll(lockDir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(lockDir, filepath.Base(path)+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
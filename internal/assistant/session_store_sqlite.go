@@ -0,0 +1,356 @@
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver
+
+	"micheam.com/aico/internal/logging"
+)
+
+// SQLiteStore stores sessions in an embedded SQLite database.
+//
+// Each session is kept as its JSON document alongside indexed metadata
+// columns, so that listing and querying do not need to decode every session.
+// The cost of each generation is also kept in the usage_log table, so that
+// spending can be totaled with a single query.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+var (
+	_ SessionStore = (*SQLiteStore)(nil)
+	_ CostReporter = (*SQLiteStore)(nil)
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id         TEXT PRIMARY KEY,
+	title      TEXT NOT NULL DEFAULT '',
+	tags       TEXT NOT NULL DEFAULT '[]',
+	model      TEXT NOT NULL DEFAULT '',
+	preview    TEXT NOT NULL DEFAULT '',
+	msg_count  INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	usage      TEXT NOT NULL DEFAULT '{}',
+	data       BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_updated_at ON sessions (updated_at DESC);
+CREATE TABLE IF NOT EXISTS usage_log (
+	session_id TEXT NOT NULL,
+	at         INTEGER NOT NULL,
+	model      TEXT NOT NULL DEFAULT '',
+	cost       REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS usage_log_session_id ON usage_log (session_id);
+CREATE INDEX IF NOT EXISTS usage_log_at ON usage_log (at);
+`
+
+// sqliteMigrations adds columns introduced after the first schema to
+// existing databases, keyed by column name.
+var sqliteMigrations = []struct{ column, ddl string }{
+	{"usage", `ALTER TABLE sessions ADD COLUMN usage TEXT NOT NULL DEFAULT '{}'`},
+}
+
+// OpenSQLiteStore opens (and creates if needed) the session database at path.
+// Make sure to close the returned store when done.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create database directory: %w", err)
+	}
+	// Write transactions take the database lock up front, so concurrent
+	// writers queue on busy_timeout instead of failing mid-transaction.
+	dsn := (&url.URL{
+		Scheme: "file",
+		Path:   path,
+		RawQuery: url.Values{
+			"_pragma": {"busy_timeout(10000)", "journal_mode(WAL)"},
+			"_txlock": {"immediate"},
+		}.Encode(),
+	}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	var hasUsageLog int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'usage_log'`).Scan(&hasUsageLog)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("inspect schema: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate schema: %w", err)
+	}
+	if hasUsageLog == 0 {
+		if err := backfillUsageLog(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate schema: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// backfillUsageLog fills the usage_log table from the sessions of a
+// database created before it existed.
+func backfillUsageLog(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT data FROM sessions`)
+	if err != nil {
+		return fmt.Errorf("query sessions: %w", err)
+	}
+	var sessions []*Session
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan session: %w", err)
+		}
+		sess, err := decodeSession(bytes.NewReader(data))
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("decode session: %w", err)
+		}
+		sessions = append(sessions, sess)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query sessions: %w", err)
+	}
+	for _, sess := range sessions {
+		if err := writeUsageLog(context.Background(), tx, sess); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// writeUsageLog replaces the usage_log rows of sess with its usage records.
+func writeUsageLog(ctx context.Context, tx *sql.Tx, sess *Session) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_log WHERE session_id = ?`, sess.ID); err != nil {
+		return fmt.Errorf("delete usage log: %w", err)
+	}
+	for _, r := range sess.UsageRecords() {
+		_, err := tx.ExecContext(ctx, `INSERT INTO usage_log (session_id, at, model, cost) VALUES (?, ?, ?, ?)`,
+			sess.ID, unixMilli(r.At), r.Model, r.Cost)
+		if err != nil {
+			return fmt.Errorf("insert usage log: %w", err)
+		}
+	}
+	return nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	for _, m := range sqliteMigrations {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('sessions') WHERE name = ?`, m.column).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("inspect column %s: %w", m.column, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(m.ddl); err != nil {
+			return fmt.Errorf("add column %s: %w", m.column, err)
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the database file.
+func (st *SQLiteStore) Path() string { return st.path }
+
+func (st *SQLiteStore) Load(ctx context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, errors.New("id must be specified")
+	}
+	var data []byte
+	err := st.db.QueryRowContext(ctx, `SELECT data FROM sessions WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query session: %w", err)
+	}
+	sess, err := decodeSession(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode found session: %w", err)
+	}
+	sess.store = st
+	sess.markSaved(data)
+	return sess, nil
+}
+
+// Save upserts the session in a write transaction. If the stored session
+// changed since it was loaded, the changes are merged first.
+func (st *SQLiteStore) Save(ctx context.Context, s *Session) error {
+	logger := logging.LoggerFrom(ctx)
+
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current []byte
+	err = tx.QueryRowContext(ctx, `SELECT data FROM sessions WHERE id = ?`, s.ID).Scan(&current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// first save
+	case err != nil:
+		return fmt.Errorf("query session: %w", err)
+	case s.changedSince(current):
+		stored, err := decodeSession(bytes.NewReader(current))
+		if err != nil {
+			return fmt.Errorf("decode stored session: %w", err)
+		}
+		logger.Info("session changed in database since load; merging",
+			"session_id", s.ID, "stored_messages", len(stored.Messages))
+		s.merge(stored)
+	}
+
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = time.Now()
+	}
+	data, err := s.encode()
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	tags, err := json.Marshal(s.Tags)
+	if err != nil {
+		return fmt.Errorf("encode tags: %w", err)
+	}
+	usage, err := json.Marshal(s.Usage)
+	if err != nil {
+		return fmt.Errorf("encode usage: %w", err)
+	}
+	summary := s.Summary()
+	logger.Debug("saving session", "database", st.path, "model", s.Model)
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO sessions (id, title, tags, model, preview, msg_count, created_at, updated_at, usage, data)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	title = excluded.title,
+	tags = excluded.tags,
+	model = excluded.model,
+	preview = excluded.preview,
+	msg_count = excluded.msg_count,
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	usage = excluded.usage,
+	data = excluded.data`,
+		s.ID, s.Title, string(tags), s.Model, summary.Preview, summary.MsgCount,
+		unixMilli(s.CreatedAt), unixMilli(s.UpdatedAt), string(usage), data)
+	if err != nil {
+		return fmt.Errorf("upsert session: %w", err)
+	}
+	if err := writeUsageLog(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	s.store = st
+	s.markSaved(data)
+	return nil
+}
+
+func (st *SQLiteStore) List(ctx context.Context) ([]SessionSummary, error) {
+	rows, err := st.db.QueryContext(ctx, `
+SELECT id, title, tags, preview, msg_count, created_at, updated_at, usage
+FROM sessions ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []SessionSummary
+	for rows.Next() {
+		var (
+			s                    SessionSummary
+			tags, usage          string
+			createdAt, updatedAt int64
+		)
+		if err := rows.Scan(&s.ID, &s.Title, &tags, &s.Preview, &s.MsgCount, &createdAt, &updatedAt, &usage); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		if err := json.Unmarshal([]byte(tags), &s.Tags); err != nil {
+			return nil, fmt.Errorf("decode tags of %s: %w", s.ID, err)
+		}
+		if err := json.Unmarshal([]byte(usage), &s.Usage); err != nil {
+			return nil, fmt.Errorf("decode usage of %s: %w", s.ID, err)
+		}
+		s.UpdatedAt = time.UnixMilli(updatedAt)
+		s.CreatedAt = s.UpdatedAt
+		if createdAt != 0 {
+			s.CreatedAt = time.UnixMilli(createdAt)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+func (st *SQLiteStore) Delete(ctx context.Context, id string) error {
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usage_log WHERE session_id = ?`, id); err != nil {
+		return fmt.Errorf("delete usage log: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// CostSince sums the cost column of the usage log.
+func (st *SQLiteStore) CostSince(ctx context.Context, since time.Time) (float64, error) {
+	var cost float64
+	err := st.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(cost), 0) FROM usage_log WHERE at >= ?`, unixMilli(since)).Scan(&cost)
+	if err != nil {
+		return 0, fmt.Errorf("query usage log: %w", err)
+	}
+	return cost, nil
+}
+
+func (st *SQLiteStore) Close() error {
+	return st.db.Close()
+}
+
+// unixMilli returns t in Unix milliseconds, or 0 for the zero time.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
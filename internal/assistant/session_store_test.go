@@ -0,0 +1,269 @@
+package assistant
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// storeFactories opens each SessionStore implementation on a fresh location.
+var storeFactories = map[string]func(t *testing.T) SessionStore{
+	"json": func(t *testing.T) SessionStore {
+		return NewJSONStore(t.TempDir())
+	},
+	"sqlite": func(t *testing.T) SessionStore {
+		st, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { st.Close() })
+		return st
+	},
+}
+
+func TestSessionStore_SaveLoadList(t *testing.T) {
+	for name, open := range storeFactories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			older := NewSession(store, NewUserMessage(NewTextContent("older")))
+			older.UpdatedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			require.NoError(t, older.Save(ctx))
+
+			newer := NewSession(store, NewUserMessage(NewTextContent("newer")))
+			newer.Title = "Newer session"
+			newer.AddTags("work")
+			newer.AddUsage(&fakeModel{}, &Usage{InputTokens: 2_000, OutputTokens: 100})
+			newer.UpdatedAt = time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+			require.NoError(t, newer.Save(ctx))
+
+			summaries, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, summaries, 2)
+			require.Equal(t, newer.ID, summaries[0].ID)
+			require.Equal(t, "Newer session", summaries[0].DisplayTitle())
+			require.Equal(t, []string{"work"}, summaries[0].Tags)
+			require.Equal(t, Usage{InputTokens: 2_000, OutputTokens: 100, Cost: 0.003}, summaries[0].Usage)
+			require.Equal(t, "older", summaries[1].DisplayTitle())
+
+			latest, err := LoadLatestSession(ctx, store)
+			require.NoError(t, err)
+			require.Equal(t, newer.ID, latest.ID)
+
+			require.NoError(t, store.Delete(ctx, older.ID))
+			_, err = store.Load(ctx, older.ID)
+			require.ErrorIs(t, err, ErrSessionNotFound)
+		})
+	}
+}
+
+func TestSessionStore_Save_MergesConcurrentUpdates(t *testing.T) {
+	for name, open := range storeFactories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			base := NewSession(store, NewUserMessage(NewTextContent("hello")))
+			require.NoError(t, base.Save(ctx))
+
+			a, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+			b, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+
+			a.AddMessages(NewAssistantMessage(NewTextContent("from a")))
+			a.AddUsage(&fakeModel{}, &Usage{InputTokens: 1_000})
+			a.AddTags("a")
+			require.NoError(t, a.Save(ctx))
+
+			b.AddMessages(NewAssistantMessage(NewTextContent("from b")))
+			b.AddUsage(&fakeModel{}, &Usage{InputTokens: 2_000})
+			b.Title = "Title by b"
+			require.NoError(t, b.Save(ctx))
+
+			got, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+			require.Len(t, got.Messages, 3)
+			require.Equal(t, "from a", MessageText(got.Messages[1]))
+			require.Equal(t, "from b", MessageText(got.Messages[2]))
+			require.Equal(t, "Title by b", got.Title)
+			require.Equal(t, []string{"a"}, got.Tags)
+			require.Equal(t, 3_000, got.Usage.InputTokens)
+			require.InDelta(t, 0.003, got.Usage.Cost, 1e-9)
+			require.Len(t, got.UsageLog, 2)
+			require.Equal(t, "test:fake", got.UsageLog[1].Model)
+		})
+	}
+}
+
+func TestSessionStore_Save_RewrittenHistoryWins(t *testing.T) {
+	for name, open := range storeFactories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			base := NewSession(store,
+				NewUserMessage(NewTextContent("q1")),
+				NewAssistantMessage(NewTextContent("a1")),
+				NewUserMessage(NewTextContent("q2")),
+				NewAssistantMessage(NewTextContent("a2")),
+			)
+			require.NoError(t, base.Save(ctx))
+
+			a, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+			b, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+
+			a.AddMessages(NewUserMessage(NewTextContent("q3")))
+			require.NoError(t, a.Save(ctx))
+
+			// Undo must not resurrect the concurrently appended turn
+			// after the kept messages.
+			b.DropLastExchange()
+			b.AddMessages(NewUserMessage(NewTextContent("q2'")), NewAssistantMessage(NewTextContent("a2'")))
+			require.NoError(t, b.Save(ctx))
+
+			got, err := store.Load(ctx, base.ID)
+			require.NoError(t, err)
+			require.Len(t, got.Messages, 4)
+			require.Equal(t, "a2'", MessageText(got.LastMessage()))
+		})
+	}
+}
+
+func TestJSONStore_LoadLocked(t *testing.T) {
+	ctx := context.Background()
+	store := NewJSONStore(t.TempDir())
+	base := NewSession(store, NewUserMessage(NewTextContent("hello")), NewAssistantMessage(NewTextContent("hi")))
+	require.NoError(t, base.Save(ctx))
+
+	locked, unlock, err := LoadSessionLocked(ctx, store, base.ID)
+	require.NoError(t, err)
+
+	// A concurrent append waits for the lock instead of being merged into
+	// the undone history.
+	saved := make(chan error)
+	go func() {
+		other, err := store.Load(ctx, base.ID)
+		if err == nil {
+			other.AddMessages(NewUserMessage(NewTextContent("later")))
+			err = other.Save(ctx)
+		}
+		saved <- err
+	}()
+	locked.DropLastExchange()
+	require.NoError(t, locked.Save(ctx))
+	select {
+	case err := <-saved:
+		t.Fatalf("save did not wait for the lock: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	require.NoError(t, <-saved)
+
+	got, err := store.Load(ctx, base.ID)
+	require.NoError(t, err)
+	require.Len(t, got.Messages, 1)
+	require.Equal(t, "later", MessageText(got.Messages[0]))
+}
+
+func TestJSONStore_List_FallsBackToModTime(t *testing.T) {
+	ctx := context.Background()
+	store := NewJSONStore(t.TempDir())
+
+	legacy := `{"id":"legacy","system_instruction":[],"messages":[{"author":"user","contents":[{"text":"hi"}]}]}`
+	require.NoError(t, os.WriteFile(store.Path("legacy"), []byte(legacy), 0644))
+	modTime := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(store.Path("legacy"), modTime, modTime))
+
+	summaries, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.True(t, modTime.Equal(summaries[0].UpdatedAt))
+}
+
+func TestJSONStore_Save_Atomic(t *testing.T) {
+	ctx := context.Background()
+	store := NewJSONStore(t.TempDir())
+
+	sess := NewSession(store, NewUserMessage(NewTextContent("hello")))
+	require.NoError(t, sess.Save(ctx))
+	sess.AddMessage(NewAssistantMessage(NewTextContent("hi")))
+	require.NoError(t, sess.Save(ctx))
+
+	entries, err := os.ReadDir(store.Dir())
+	require.NoError(t, err)
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	require.Equal(t, []string{sess.ID + ".json"}, files, "no temporary files should be left behind")
+}
+
+func TestOpenSQLiteStore_MigratesSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	// Create a database with the schema from before usage was recorded.
+	st, err := OpenSQLiteStore(path)
+	require.NoError(t, err)
+	_, err = st.db.Exec(`ALTER TABLE sessions DROP COLUMN usage`)
+	require.NoError(t, err)
+	_, err = st.db.Exec(`INSERT INTO sessions (id, created_at, updated_at, data) VALUES ('old', 1, 1, '{"id":"old","messages":[]}')`)
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	st, err = OpenSQLiteStore(path)
+	require.NoError(t, err)
+	defer st.Close()
+	summaries, err := st.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Zero(t, summaries[0].Usage)
+}
+
+func TestSQLiteStore_CostSince(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	st, err := OpenSQLiteStore(path)
+	require.NoError(t, err)
+
+	monthStart := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sess := NewSession(st)
+	sess.AddMessage(NewUserMessage(NewTextContent("hi")))
+	sess.UsageLog = []UsageRecord{
+		{Model: "test:fake", At: monthStart.Add(-time.Hour), Usage: Usage{Cost: 1}},
+		{Model: "test:fake", At: monthStart.Add(time.Hour), Usage: Usage{Cost: 0.25}},
+	}
+	require.NoError(t, sess.Save(ctx))
+	other := NewSession(st)
+	other.AddMessage(NewUserMessage(NewTextContent("hello")))
+	other.UsageLog = []UsageRecord{{Model: "test:fake", At: monthStart.Add(2 * time.Hour), Usage: Usage{Cost: 0.5}}}
+	require.NoError(t, other.Save(ctx))
+
+	cost, err := st.CostSince(ctx, monthStart)
+	require.NoError(t, err)
+	require.InDelta(t, 0.75, cost, 1e-9)
+
+	require.NoError(t, st.Delete(ctx, other.ID))
+	cost, err = st.CostSince(ctx, monthStart)
+	require.NoError(t, err)
+	require.InDelta(t, 0.25, cost, 1e-9)
+
+	// Databases from before the usage log are backfilled when opened.
+	_, err = st.db.Exec(`DROP TABLE usage_log`)
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+	st, err = OpenSQLiteStore(path)
+	require.NoError(t, err)
+	defer st.Close()
+	cost, err = st.CostSince(ctx, time.Time{})
+	require.NoError(t, err)
+	require.InDelta(t, 1.25, cost, 1e-9)
+}
@@ -1,7 +1,11 @@
 package assistant
 
 import (
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 )
@@ -42,3 +46,111 @@ func TestSession_MarshalJSON(t *testing.T) {
 	require.NoError(t, err)
 	require.JSONEq(t, sessionJSONStr, string(data))
 }
+
+func TestSession_UnmarshalJSON_Metadata(t *testing.T) {
+	data := `{
+  "id": "session_12345",
+  "title": "Greetings",
+  "tags": ["work"],
+  "system_instruction": [],
+  "messages": [
+    {"author": "user", "contents": [{"text": "Hello"}], "created_at": "2025-01-01T00:00:00Z"},
+    {"author": "assistant", "contents": [{"text": "Hi"}], "model": "anthropic:claude-haiku-4-5", "created_at": "2025-01-01T00:00:01Z"}
+  ],
+  "created_at": "2025-01-01T00:00:00Z",
+  "updated_at": "2025-01-01T00:00:01Z"
+}`
+	sess := new(Session)
+	require.NoError(t, sess.UnmarshalJSON([]byte(data)))
+	require.Equal(t, "Greetings", sess.Title)
+	require.Equal(t, []string{"work"}, sess.Tags)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC), sess.UpdatedAt)
+
+	reply, ok := sess.Messages[1].(*AssistantMessage)
+	require.True(t, ok)
+	require.Equal(t, "anthropic:claude-haiku-4-5", reply.Model)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC), reply.CreatedAt)
+
+	out, err := sess.MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, data, string(out))
+}
+
+func TestSession_Tags(t *testing.T) {
+	sess := NewSession(NewJSONStore(t.TempDir()))
+	sess.AddTags("work", " ", "idea", "work")
+	require.Equal(t, []string{"idea", "work"}, sess.Tags)
+	require.True(t, sess.HasTag("idea"))
+
+	sess.RemoveTags("idea")
+	require.Equal(t, []string{"work"}, sess.Tags)
+}
+
+func TestSession_NeedsTitle(t *testing.T) {
+	sess := NewSession(NewJSONStore(t.TempDir()), NewUserMessage(NewTextContent("Hello")))
+	require.False(t, sess.NeedsTitle())
+
+	sess.AddMessage(NewAssistantMessage(NewTextContent("Hi")))
+	require.True(t, sess.NeedsTitle())
+
+	sess.Title = "Greetings"
+	require.False(t, sess.NeedsTitle())
+}
+
+func TestSession_EditHistory(t *testing.T) {
+	newSess := func() *Session {
+		return NewSession(NewJSONStore(t.TempDir()),
+			NewUserMessage(NewTextContent("q1")),
+			NewAssistantMessage(NewTextContent("a1")),
+			NewUserMessage(NewTextContent("q2")),
+			NewAssistantMessage(NewTextContent("a2")),
+			NewAssistantMessage(NewTextContent("a2 cont.")),
+		)
+	}
+
+	t.Run("TrimTrailingReplies", func(t *testing.T) {
+		sess := newSess()
+		removed := sess.TrimTrailingReplies()
+		require.Len(t, removed, 2)
+		require.Equal(t, "a2", MessageText(removed[0]))
+		require.Len(t, sess.Messages, 3)
+		require.Equal(t, "q2", MessageText(sess.LastMessage()))
+		require.Empty(t, sess.TrimTrailingReplies())
+	})
+
+	t.Run("DropLastExchange", func(t *testing.T) {
+		sess := newSess()
+		require.Equal(t, 3, sess.DropLastExchange())
+		require.Equal(t, 2, sess.DropLastExchange())
+		require.Equal(t, 0, sess.DropLastExchange())
+		require.Nil(t, sess.LastMessage())
+	})
+
+	t.Run("ReplaceAndTruncate", func(t *testing.T) {
+		sess := newSess()
+		u, err := url.Parse("https://example.com/x.png")
+		require.NoError(t, err)
+		img := NewURLImageContent(*u)
+		sess.Messages[2] = NewUserMessage(NewTextContent("q2"), img)
+
+		sess.ReplaceMessage(2, WithText(sess.Messages[2], "edited"))
+		require.Equal(t, 2, sess.Truncate(3))
+		require.Len(t, sess.Messages, 3)
+		require.Equal(t, "edited", MessageText(sess.Messages[2]))
+		require.Equal(t, []MessageContent{NewTextContent("edited"), img}, sess.Messages[2].GetContents())
+	})
+}
+
+func TestSession_Preview_MultiByte(t *testing.T) {
+	text := strings.Repeat("こんにちは", 20)
+	sess := NewSession(NewJSONStore(t.TempDir()), NewUserMessage(NewTextContent(text)))
+
+	preview := sess.Preview(80)
+	require.True(t, utf8.ValidString(preview))
+	require.Equal(t, strings.Repeat("こんにちは", 16)+"...", preview)
+}
+
+func TestSanitizeTitle(t *testing.T) {
+	require.Equal(t, "Go concurrency basics", sanitizeTitle("\"Go concurrency basics.\"\n\nExplanation"))
+	require.Equal(t, "Refactoring tips", sanitizeTitle("Title: Refactoring tips"))
+}
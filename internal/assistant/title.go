@@ -0,0 +1,74 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// titleMaxRunes is the maximum length of a generated session title.
+const titleMaxRunes = 60
+
+const titleInstruction = `You write titles for chat conversations.
+Reply with a single short title (at most 8 words) that summarizes the conversation below.
+Use the language of the conversation. Do not use quotes or trailing punctuation.`
+
+// GenerateTitle asks model for a short title summarizing the first exchange
+// of the session.
+//
+// The model's system instruction is overwritten, so callers should pass a
+// dedicated model instance (typically a cheap and fast one). The usage of
+// the request is added to the session totals.
+func GenerateTitle(ctx context.Context, model GenerativeModel, sess *Session) (string, error) {
+	transcript := new(strings.Builder)
+	var user, asst bool
+	for _, msg := range sess.Messages {
+		switch msg.GetAuthor() {
+		case MessageAuthorUser:
+			if user {
+				continue
+			}
+			user = true
+			fmt.Fprintf(transcript, "<user>\n%s\n</user>\n", MessageText(msg))
+		case MessageAuthorAssistant:
+			if asst || !user {
+				continue
+			}
+			asst = true
+			fmt.Fprintf(transcript, "<assistant>\n%s\n</assistant>\n", MessageText(msg))
+		}
+	}
+	if !user || !asst {
+		return "", errors.New("session has no complete exchange")
+	}
+
+	model.SetSystemInstruction(NewTextContent(titleInstruction))
+	resp, err := model.GenerateContent(ctx, NewUserMessage(NewTextContent(transcript.String())))
+	if err != nil {
+		return "", fmt.Errorf("generate title: %w", err)
+	}
+	sess.AddUsage(model, resp.Usage)
+	tc, ok := resp.Content.(*TextContent)
+	if !ok {
+		return "", fmt.Errorf("unexpected title content: %T", resp.Content)
+	}
+	title := sanitizeTitle(tc.Text)
+	if title == "" {
+		return "", errors.New("model returned an empty title")
+	}
+	return title, nil
+}
+
+// sanitizeTitle reduces a model reply to a single trimmed line without
+// surrounding quotes.
+func sanitizeTitle(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(s, "Title:")
+	s = strings.Trim(s, " \t\"'`*#")
+	s = strings.TrimRight(s, ".")
+	return truncate(s, titleMaxRunes)
+}
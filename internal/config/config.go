@@ -5,13 +5,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
+	"micheam.com/aico/internal/prompt"
 	"micheam.com/aico/internal/providers/anthropic"
 )
 
@@ -42,8 +48,29 @@ func MustFromContext(ctx context.Context) *Config {
 type Config struct {
 	location string `toml:"-"`
 
-	// Logfile is the path to the logfile
-	logfile string `toml:"logfile"`
+	// project is the project config merged into this one, if any.
+	project string
+
+	// profile is the profile merged into this one, if any.
+	profile string
+
+	// settings are the values set in the config files.
+	settings []Setting
+
+	// unknown are the settings that do not map to a field.
+	unknown []Setting
+
+	// Logfile is the path to the logfile. A relative path is relative to
+	// the directory of the config file, and "~/" to the home directory.
+	//
+	// If omitted, the default logfile in the state directory will be used.
+	Logfile string `toml:"logfile,omitempty"`
+
+	// LogLevel is the minimum level of the messages written to the logfile:
+	// "debug", "info", "warn" or "error". --debug overrides it.
+	//
+	// If omitted, "info" will be used.
+	LogLevel string `toml:"log_level,omitempty"`
 
 	// DefaultProvider is the default provider to use when model name is ambiguous.
 	//
@@ -60,13 +87,63 @@ type Config struct {
 	// If omitted, the default model for the application will be used.
 	Model string `toml:"model"`
 
+	// TitleModel is the model used to generate session titles after the
+	// first exchange. A cheap and fast model is recommended.
+	//
+	// Set to "none" to disable automatic titles.
+	// If omitted, [DefaultTitleModel] will be used.
+	TitleModel string `toml:"title_model"`
+
 	// PersonaMap is the persona to use for text generation
 	PersonaMap map[string]Personality `toml:"persona"`
 
-	// SessionDir is the directory to store session files
+	// Context is added to every new session before the context of the
+	// persona and --context. Relative "@path" entries are resolved against
+	// the directory of the config file, or the root of the project.
+	Context []string `toml:"context,omitempty"`
+
+	// TrustedProjects are the directories whose project configs are
+	// trusted, including those of projects below them. The context files and
+	// prompt file defaults of other projects must be inside of the project,
+	// and their personas and prompt templates are rendered in a sandbox (see
+	// [prompt.Options]).
+	// Relative paths are resolved like [Config.Logfile].
+	TrustedProjects []string `toml:"trusted_projects,omitempty"`
+
+	// PromptMap holds the reusable prompt templates by name
+	PromptMap map[string]Prompt `toml:"prompt,omitempty"`
+
+	// ProfileMap holds named sets of settings, each merged over the others
+	// when selected with --profile or $AICO_PROFILE. A profile may set any
+	// key but profile, e.g. model, session_dir, providers or personas.
+	ProfileMap map[string]map[string]any `toml:"profile,omitempty"`
+
+	// Compaction controls how conversations exceeding the model's context
+	// window are handled.
+	Compaction Compaction `toml:"compaction"`
+
+	// Budget sets a soft monthly spending limit.
+	Budget Budget `toml:"budget"`
+
+	// ResponseCache configures the local cache of model responses.
+	ResponseCache ResponseCache `toml:"response_cache"`
+
+	// Providers holds per-provider settings, keyed by provider name
+	// ("anthropic", "openai", "groq", "cerebras").
+	Providers map[string]Provider `toml:"providers"`
+
+	// SessionDir is the directory to store session files. Relative paths
+	// are resolved like [Config.Logfile].
 	//
 	// If omitted, the default session directory will be used.
-	sessionDir string `toml:"session_dir"`
+	SessionDir string `toml:"session_dir,omitempty"`
+
+	// SessionStore is the backend to persist sessions with.
+	//
+	// Valid values: "json" (one file per session in the session directory),
+	// "sqlite" (a single database file in the session directory).
+	// If omitted, "json" will be used.
+	SessionStore string `toml:"session_store"`
 }
 
 // Location returns the location of the configuration file
@@ -80,19 +157,270 @@ type Personality struct {
 	Description string `toml:"description"`
 
 	// Message is the system message to use for the personality
-	Message string `toml:"message"`
+	Message string `toml:"message,omitempty"`
+
+	// Cache is the prompt cache mode for models with explicit caching
+	// ("off", "auto" or "1h"). Empty means "auto".
+	Cache string `toml:"cache,omitempty"`
+
+	// Extends is the name of the persona this one is based on. Its message
+	// comes first, and its settings apply unless set here. See
+	// [Config.ResolvePersona].
+	Extends string `toml:"extends,omitempty"`
+
+	// Model is the model to use unless --model is given. It overrides the
+	// model of the config file.
+	Model string `toml:"model,omitempty"`
+
+	// Temperature is the sampling temperature. Nil means the model default.
+	Temperature *float64 `toml:"temperature,omitempty"`
+
+	// MaxTokens caps the number of tokens generated per reply. Zero means
+	// the model default.
+	MaxTokens int `toml:"max_tokens,omitempty"`
+
+	// Effort is the thinking effort of reasoning models ("low", "medium" or
+	// "high"). Empty means the model default.
+	Effort string `toml:"effort,omitempty"`
+
+	// Context is added to every new session before the --context values.
+	// Like those, each entry is a string or "@path" of a file; relative paths
+	// are resolved against the persona directory.
+	Context []string `toml:"context,omitempty"`
+
+	// path is the persona file this personality was read from, or empty if
+	// it is defined in the config file.
+	path string
+
+	// dir is the persona directory of the config p is defined in.
+	dir string
+
+	// sandbox is the root of the untrusted project p is defined in, if any.
+	sandbox string
+}
+
+// Path returns the persona file p was read from, or an empty string if p is
+// defined in the config file.
+func (p Personality) Path() string {
+	return p.path
+}
+
+// Dir returns the persona directory of the user or project config p belongs
+// to. Includes and context paths of p are relative to it.
+func (p Personality) Dir() string {
+	return p.dir
+}
+
+// Sandbox returns the root of the untrusted project p is defined in, or an
+// empty string if p is trusted. Its message is rendered with it as
+// [prompt.Options.Sandbox].
+func (p Personality) Sandbox() string {
+	return p.sandbox
+}
+
+// Compaction controls how conversations exceeding the model's context window
+// are handled.
+type Compaction struct {
+	// Strategy is one of "fail" (default), "drop" (drop the oldest turns) or
+	// "summarize" (replace the oldest turns with a summary).
+	Strategy string `toml:"strategy"`
+
+	// Threshold is the fraction of the context window that may be filled
+	// before compaction kicks in. Defaults to [DefaultCompactionThreshold].
+	Threshold float64 `toml:"threshold"`
+
+	// SummaryModel is the model used by the "summarize" strategy.
+	// Defaults to [DefaultTitleModel].
+	SummaryModel string `toml:"summary_model"`
+}
+
+// GetThreshold returns the compaction threshold
+func (c Compaction) GetThreshold() float64 {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		return DefaultCompactionThreshold
+	}
+	return c.Threshold
+}
+
+// GetSummaryModel returns the model spec used to summarize old turns
+func (c Compaction) GetSummaryModel() string {
+	if c.SummaryModel == "" {
+		return DefaultTitleModel
+	}
+	return c.SummaryModel
+}
+
+// Budget is a soft monthly spending limit, checked against the cost recorded
+// in sessions before each request.
+type Budget struct {
+	// Monthly is the budget in USD per calendar month. 0 disables the budget.
+	Monthly float64 `toml:"monthly"`
+
+	// RefuseOutputPrice, if set, refuses models whose output price (USD per
+	// million tokens) is at least this much once the budget is exceeded.
+	// Otherwise only a warning is printed.
+	RefuseOutputPrice float64 `toml:"refuse_output_price"`
+}
+
+// Provider configures access to an AI provider.
+type Provider struct {
+	// BaseURL overrides the API endpoint of the provider, e.g. to go through
+	// a proxy or a compatible gateway. Empty means the provider's default.
+	BaseURL string `toml:"base_url,omitempty"`
+
+	// Timeout limits each request to the provider, including the streaming
+	// of the response, as a Go duration (e.g. "5m"). Empty means no limit.
+	Timeout string `toml:"timeout,omitempty"`
+
+	// MaxRetries is how many times a request is retried after a connection
+	// error, a rate limit or a server error. Unset means the provider's
+	// default: 2 for anthropic, none for the others.
+	MaxRetries *int `toml:"max_retries,omitempty"`
+
+	// APIKeyCmd is a shell command printing the API key on its first line,
+	// e.g. "pass show anthropic". It is used unless the key is given as a
+	// flag or environment variable.
+	APIKeyCmd string `toml:"api_key_cmd,omitempty"`
+
+	// APIKeyFile is a file holding the API key, which only its owner may
+	// access. Relative paths are resolved like [Config.Logfile].
+	APIKeyFile string `toml:"api_key_file,omitempty"`
+}
+
+// ProviderNames are the providers that can be configured in [Config.Providers].
+var ProviderNames = []string{"anthropic", "openai", "groq", "cerebras"}
+
+// GetTimeout returns the parsed timeout, or 0 if there is none.
+func (p Provider) GetTimeout() (time.Duration, error) {
+	if p.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("timeout: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("timeout: must not be negative")
+	}
+	return d, nil
+}
+
+// ResponseCache configures the on-disk cache that answers repeated,
+// identical requests without calling the provider.
+type ResponseCache struct {
+	// Enabled turns the cache on. It is off by default.
+	Enabled bool `toml:"enabled"`
+
+	// TTL is how long responses are reused, as a Go duration (e.g. "24h").
+	// "0" keeps them forever. Defaults to [DefaultResponseCacheTTL].
+	TTL string `toml:"ttl"`
+}
+
+// GetTTL returns the parsed TTL.
+func (c ResponseCache) GetTTL() (time.Duration, error) {
+	if c.TTL == "" {
+		return DefaultResponseCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0, fmt.Errorf("response_cache.ttl: %w", err)
+	}
+	return ttl, nil
 }
 
 var ErrConfigFileNotFound = errors.New("config file not found")
 
-func (c *Config) Logfile() string {
-	if c.logfile == "" {
+// GetLogfile returns the path to the logfile
+func (c *Config) GetLogfile() string {
+	if c.Logfile == "" {
 		return defaultLogfilePath()
 	}
-	if filepath.IsAbs(c.logfile) {
-		return c.logfile
+	return c.resolvePath(c.Logfile)
+}
+
+// GetLogLevel returns the parsed log level.
+func (c *Config) GetLogLevel() (slog.Level, error) {
+	if c.LogLevel == "" {
+		return slog.LevelInfo, nil
 	}
-	return filepath.Join(filepath.Dir(c.location), c.logfile)
+	switch strings.ToLower(c.LogLevel) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log_level: invalid level %q: want debug, info, warn or error", c.LogLevel)
+}
+
+// resolvePath returns path with "~/" expanded to the home directory and
+// made relative to the directory of the config file.
+func (c *Config) resolvePath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.location), path)
+}
+
+// trusts reports whether the project at root is below one of the
+// [Config.TrustedProjects].
+func (c *Config) trusts(root string) bool {
+	return slices.ContainsFunc(c.TrustedProjects, func(dir string) bool {
+		return prompt.InDir(c.resolvePath(dir), root)
+	})
+}
+
+// Validate reports whether the settings of c are valid.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := c.GetLogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.DefaultProvider != "" && !slices.Contains(ProviderNames, c.DefaultProvider) {
+		errs = append(errs, fmt.Errorf("default_provider: unknown provider %q", c.DefaultProvider))
+	}
+	switch c.SessionStore {
+	case "", SessionStoreJSON, SessionStoreSQLite:
+	default:
+		errs = append(errs, fmt.Errorf("session_store: invalid store %q: want %q or %q", c.SessionStore, SessionStoreJSON, SessionStoreSQLite))
+	}
+	if _, err := c.ResponseCache.GetTTL(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.ProfileMap)) {
+		if !personaNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid profile name %q", name))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Providers)) {
+		p := c.Providers[name]
+		if !slices.Contains(ProviderNames, name) {
+			errs = append(errs, fmt.Errorf("providers.%s: unknown provider", name))
+		}
+		if p.BaseURL != "" {
+			if u, err := url.Parse(p.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("providers.%s.base_url: invalid URL %q", name, p.BaseURL))
+			}
+		}
+		if _, err := p.GetTimeout(); err != nil {
+			errs = append(errs, fmt.Errorf("providers.%s.%w", name, err))
+		}
+		if p.APIKeyCmd != "" && p.APIKeyFile != "" {
+			errs = append(errs, fmt.Errorf("providers.%s: api_key_cmd and api_key_file cannot both be set", name))
+		}
+		if p.MaxRetries != nil && *p.MaxRetries < 0 {
+			errs = append(errs, fmt.Errorf("providers.%s.max_retries: must not be negative", name))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // OpenLogfile opens the logfile for writing
@@ -102,7 +430,7 @@ func (c *Config) Logfile() string {
 //
 // Make sure to close the returned file when done.
 func (c *Config) OpenLogfile() (*os.File, error) {
-	logfilePath := c.Logfile()
+	logfilePath := c.GetLogfile()
 	// Ensure the directory exists
 	if err := os.MkdirAll(filepath.Dir(logfilePath), 0755); err != nil {
 		return nil, fmt.Errorf("mkdir all: %w", err)
@@ -118,33 +446,15 @@ func (c *Config) OpenLogfile() (*os.File, error) {
 //
 // This may return an error if the file cannot be read or parsed.
 func load(path string) (*Config, error) {
-	// Check if the file exists
-	_, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return nil, ErrConfigFileNotFound
-	}
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
-	}
-	config, err := loadFromReader(bytes.NewReader(b))
-	if err != nil {
-		return nil, fmt.Errorf("load from reader: %w", err)
-	}
-	config.location = path
-	return config, nil
-}
-
-func loadFromReader(r io.Reader) (*Config, error) {
-	var config Config
-	if _, err := toml.NewDecoder(r).Decode(&config); err != nil {
-		return nil, fmt.Errorf("decode toml: %w", err)
-	}
-	return &config, nil
+	return loadLayers(path, "", "", nil)
 }
 
 const EnvKeyConfigPath = "AI_ASSISTANT_CONFIG_PATH"
 
+// EnvKeyProfile is the environment variable selecting a profile of
+// [Config.ProfileMap]. The --profile flag sets it.
+const EnvKeyProfile = "AICO_PROFILE"
+
 // ConfigFilePath returns the path to the config file
 func ConfigFilePath() string {
 	if os.Getenv(EnvKeyConfigPath) != "" {
@@ -190,6 +500,19 @@ const (
 	// DefaultModel is the default model to use
 	DefaultModel = anthropic.ModelNameClaudeHaiku4_5
 
+	// DefaultTitleModel is the default model to generate session titles
+	DefaultTitleModel = anthropic.ModelNameClaudeHaiku4_5
+
+	// TitleModelNone disables automatic session titles
+	TitleModelNone = "none"
+
+	// DefaultCompactionThreshold is the default fraction of the context
+	// window that may be filled before compaction kicks in
+	DefaultCompactionThreshold = 0.9
+
+	// DefaultResponseCacheTTL is how long cached responses are reused by default
+	DefaultResponseCacheTTL = 24 * time.Hour
+
 	// ApplicationFQN is the fully qualified name of the application
 	ApplicationFQN = "com.micheam.aico"
 
@@ -198,27 +521,51 @@ const (
 
 	// LogFileName is the name of the log file
 	LogFileName = "aico.log"
+
+	// SessionDBFileName is the name of the SQLite session database
+	SessionDBFileName = "sessions.db"
+)
+
+// Session store backends
+const (
+	SessionStoreJSON   = "json"
+	SessionStoreSQLite = "sqlite"
 )
 
 // Load loads the configuration for the application
 //
 // This will load the configuration from the path specified by the AI_ASSISTANT_CONFIG_PATH
 // environment variable, or from the default location if the environment
-// variable is not set.
+// variable is not set, and merge the project config of the working
+// directory over it (see [FindProjectConfig]), then the profile named by
+// $AICO_PROFILE, if set, and the AICO_ variables of [EnvVars].
 //
-// This may return an error if the file cannot be read or parsed.
-// If the file does not exist, this will return [ErrConfigFileNotFound].
+// This may return an error if the files cannot be read or parsed.
+// If the config file does not exist, [DefaultConfig] stands in for it.
 func Load() (*Config, error) {
-	return load(ConfigFilePath())
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get working directory: %w", err)
+	}
+	return loadLayers(ConfigFilePath(), FindProjectConfig(wd), os.Getenv(EnvKeyProfile), os.Environ())
+}
+
+// LoadFile loads the configuration from path alone, without a project
+// config. It returns [ErrConfigFileNotFound] if path does not exist.
+func LoadFile(path string) (*Config, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrConfigFileNotFound
+	}
+	return load(path)
 }
 
 // InitAndLoad initializes the configuration for the application
 func InitAndLoad() (*Config, error) {
-	config, err := Load()
+	_, err := os.Stat(ConfigFilePath())
 	if err == nil {
-		return config, nil // already initialized
+		return Load() // already initialized
 	}
-	if errors.Is(err, ErrConfigFileNotFound) {
+	if errors.Is(err, os.ErrNotExist) {
 		conf := DefaultConfig()
 		// mkdir for path
 		if err := os.MkdirAll(filepath.Dir(ConfigFilePath()), 0755); err != nil {
@@ -238,7 +585,7 @@ func InitAndLoad() (*Config, error) {
 	}
 
 	// Unexpected error
-	return nil, err
+	return nil, fmt.Errorf("stat config file: %w", err)
 }
 
 // GetDefaultPersona returns the default persona
@@ -258,10 +605,36 @@ func (c *Config) GetPersona(name string) (*Personality, bool) {
 	return nil, false
 }
 
+// GetTitleModel returns the model spec used to generate session titles,
+// or an empty string if automatic titles are disabled.
+func (c *Config) GetTitleModel() string {
+	switch c.TitleModel {
+	case "":
+		return DefaultTitleModel
+	case TitleModelNone:
+		return ""
+	default:
+		return c.TitleModel
+	}
+}
+
+// GetSessionStore returns the session store backend to use
+func (c *Config) GetSessionStore() string {
+	if c.SessionStore == "" {
+		return SessionStoreJSON
+	}
+	return c.SessionStore
+}
+
+// SessionDBPath returns the path to the SQLite session database
+func (c *Config) SessionDBPath() string {
+	return filepath.Join(c.GetSessionDir(), SessionDBFileName)
+}
+
 // GetSessionDir returns the session directory
 func (c *Config) GetSessionDir() string {
-	if c.sessionDir != "" {
-		return c.sessionDir
+	if c.SessionDir != "" {
+		return c.resolvePath(c.SessionDir)
 	}
 	return DefaultSessionDir()
 }
@@ -274,7 +647,7 @@ func (c *Config) GetSessionDir() string {
 // This is used when initializing the configuration for the first time.
 func DefaultConfig() *Config {
 	return &Config{
-		logfile: defaultLogfilePath(),
+		Logfile: defaultLogfilePath(),
 		Model:   DefaultModel,
 		PersonaMap: map[string]Personality{
 			"default": {
@@ -308,3 +681,26 @@ func DefaultSessionDir() string {
 	}
 	return filepath.Join(rootDir, ApplicationFQN, "sessions")
 }
+
+// DefaultResponseCacheDir returns the directory of the response cache
+//
+// This follows the XDG Base Directory Specification.
+// Otherwise, it uses the appropriate directory for the OS.
+// Unix/Linux: ~/.cache/com.micheam.aico/responses
+// macOS: ~/Library/Caches/com.micheam.aico/responses
+// Windows: %LOCALAPPDATA%\com.micheam.aico\responses
+func DefaultResponseCacheDir() string {
+	rootDir := os.Getenv("XDG_CACHE_HOME")
+	if rootDir == "" {
+		switch runtime.GOOS {
+		case "windows":
+			rootDir = os.Getenv("LOCALAPPDATA")
+		case "darwin", "ios":
+			rootDir = filepath.Join(os.Getenv("HOME"), "Library", "Caches")
+		default:
+			rootDir, _ = os.UserHomeDir()
+			rootDir = filepath.Join(rootDir, ".cache")
+		}
+	}
+	return filepath.Join(rootDir, ApplicationFQN, "responses")
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CredentialsFileName is the name of the file in the state directory that
+// `aico auth set` stores API keys in.
+const CredentialsFileName = "credentials.toml"
+
+// APIKeyCmdTTL is how long the output of an api_key_cmd is reused, so that
+// the command runs once per invocation of aico while long-running servers
+// still pick up rotated keys.
+const APIKeyCmdTTL = 5 * time.Minute
+
+// apiKeyCmdTimeout limits an api_key_cmd, which may wait for a passphrase.
+const apiKeyCmdTimeout = time.Minute
+
+// Sources of an API key returned by [Config.APIKey]
+const (
+	APIKeySourceCmd         = "api_key_cmd"
+	APIKeySourceFile        = "api_key_file"
+	APIKeySourceCredentials = "credentials"
+)
+
+// ErrCredentialNotFound is returned when removing a key that is not stored.
+var ErrCredentialNotFound = errors.New("no API key stored")
+
+// credential is an entry of the credentials file.
+type credential struct {
+	APIKey string `toml:"api_key"`
+}
+
+var apiKeyCmdCache = struct {
+	sync.Mutex
+	keys    map[string]string
+	expires map[string]time.Time
+}{keys: map[string]string{}, expires: map[string]time.Time{}}
+
+// CredentialsFilePath returns the path to the credentials file.
+func CredentialsFilePath() string {
+	return filepath.Join(defaultStateDir(), CredentialsFileName)
+}
+
+// APIKey returns the API key of provider given by its api_key_cmd or
+// api_key_file, or else stored with `aico auth set`, with one of the
+// APIKeySource constants. It returns an empty key if there is none.
+//
+// Keys given as flags or environment variables take precedence, but are not
+// known here.
+func (c *Config) APIKey(ctx context.Context, provider string) (key, source string, err error) {
+	p := c.Providers[provider]
+	switch {
+	case p.APIKeyCmd != "":
+		key, err := runAPIKeyCmd(ctx, p.APIKeyCmd)
+		if err != nil {
+			return "", "", fmt.Errorf("providers.%s.api_key_cmd: %w", provider, err)
+		}
+		return key, APIKeySourceCmd, nil
+	case p.APIKeyFile != "":
+		key, err := readSecretFile(c.resolvePath(p.APIKeyFile))
+		if err != nil {
+			return "", "", fmt.Errorf("providers.%s.api_key_file: %w", provider, err)
+		}
+		return key, APIKeySourceFile, nil
+	}
+	creds, err := ReadCredentials()
+	if err != nil {
+		return "", "", err
+	}
+	if key := creds[provider]; key != "" {
+		return key, APIKeySourceCredentials, nil
+	}
+	return "", "", nil
+}
+
+// runAPIKeyCmd returns the first line of the output of command, run by the
+// shell. Its stderr goes to ours, e.g. for a passphrase prompt.
+func runAPIKeyCmd(ctx context.Context, command string) (string, error) {
+	apiKeyCmdCache.Lock()
+	defer apiKeyCmdCache.Unlock()
+	if time.Now().Before(apiKeyCmdCache.expires[command]) {
+		return apiKeyCmdCache.keys[command], nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiKeyCmdTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("run %q: %w", command, err)
+	}
+	key, _, _ := strings.Cut(string(out), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("run %q: no output", command)
+	}
+	apiKeyCmdCache.keys[command] = key
+	apiKeyCmdCache.expires[command] = time.Now().Add(APIKeyCmdTTL)
+	return key, nil
+}
+
+// readSecretFile returns the trimmed contents of the file at path, which
+// must not be accessible by other users.
+func readSecretFile(path string) (string, error) {
+	if err := checkPrivate(path); err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	key := strings.TrimSpace(string(b))
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return key, nil
+}
+
+// checkPrivate returns an error if the file at path may be read or written
+// by users other than its owner.
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible by other users (mode %04o); run `chmod 600 %s`", path, info.Mode().Perm(), path)
+	}
+	return nil
+}
+
+// ReadCredentials returns the API keys of the credentials file by provider.
+func ReadCredentials() (map[string]string, error) {
+	path := CredentialsFilePath()
+	if err := checkPrivate(path); errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var creds map[string]credential
+	if _, err := toml.DecodeFile(path, &creds); err != nil {
+		return nil, fmt.Errorf("%s: decode toml: %w", path, err)
+	}
+	keys := make(map[string]string, len(creds))
+	for provider, c := range creds {
+		keys[provider] = c.APIKey
+	}
+	return keys, nil
+}
+
+// SetCredential stores the API key of provider in the credentials file.
+func SetCredential(provider, key string) error {
+	keys, err := ReadCredentials()
+	if err != nil {
+		return err
+	}
+	keys[provider] = key
+	return writeCredentials(keys)
+}
+
+// RemoveCredential removes the API key of provider from the credentials
+// file. It returns [ErrCredentialNotFound] if there is none.
+func RemoveCredential(provider string) error {
+	keys, err := ReadCredentials()
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[provider]; !ok {
+		return fmt.Errorf("%w for %s", ErrCredentialNotFound, provider)
+	}
+	delete(keys, provider)
+	return writeCredentials(keys)
+}
+
+// writeCredentials replaces the credentials file with keys, readable by the
+// user only.
+func writeCredentials(keys map[string]string) error {
+	creds := make(map[string]credential, len(keys))
+	for provider, key := range keys {
+		creds[provider] = credential{APIKey: key}
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(creds); err != nil {
+		return fmt.Errorf("encode toml: %w", err)
+	}
+
+	path := CredentialsFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	// CreateTemp creates the file with mode 0600.
+	f, err := os.CreateTemp(filepath.Dir(path), CredentialsFileName+".*")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
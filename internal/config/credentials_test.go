@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	conf := &Config{
+		location: filepath.Join(dir, ConfigFileName),
+		Providers: map[string]Provider{
+			"groq":   {APIKeyCmd: "echo x >> " + count + "; printf 'gsk-cmd\\nmore\\n'"},
+			"openai": {APIKeyFile: "openai.key"},
+		},
+	}
+	ctx := context.Background()
+
+	for range 2 {
+		key, source, err := conf.APIKey(ctx, "groq")
+		require.NoError(t, err)
+		require.Equal(t, "gsk-cmd", key)
+		require.Equal(t, APIKeySourceCmd, source)
+	}
+	b, err := os.ReadFile(count)
+	require.NoError(t, err)
+	require.Equal(t, "x\n", string(b), "the output of the command is cached")
+
+	keyFile := filepath.Join(dir, "openai.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("sk-file\n"), 0644))
+	_, _, err = conf.APIKey(ctx, "openai")
+	require.ErrorContains(t, err, "accessible by other users")
+	require.NoError(t, os.Chmod(keyFile, 0600))
+	key, source, err := conf.APIKey(ctx, "openai")
+	require.NoError(t, err)
+	require.Equal(t, "sk-file", key)
+	require.Equal(t, APIKeySourceFile, source)
+
+	key, _, err = conf.APIKey(ctx, "anthropic")
+	require.NoError(t, err)
+	require.Empty(t, key)
+	require.NoError(t, SetCredential("anthropic", "sk-ant"))
+	info, err := os.Stat(CredentialsFilePath())
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	key, source, err = conf.APIKey(ctx, "anthropic")
+	require.NoError(t, err)
+	require.Equal(t, "sk-ant", key)
+	require.Equal(t, APIKeySourceCredentials, source)
+
+	require.NoError(t, RemoveCredential("anthropic"))
+	require.ErrorIs(t, RemoveCredential("anthropic"), ErrCredentialNotFound)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Lookup returns the settings of key: the value of key itself, or all values
+// below it if key is a table such as "providers.openai". A value that is not
+// set in any config file but has a default is returned with the source
+// "default".
+func (c *Config) Lookup(key string) ([]Setting, error) {
+	path, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	key = formatKey(path)
+	var found []Setting
+	for _, s := range c.settings {
+		if s.Key == key || strings.HasPrefix(s.Key, key+".") {
+			found = append(found, s)
+		}
+	}
+	if len(found) > 0 {
+		return found, nil
+	}
+	for _, s := range c.defaults() {
+		if s.Key == key {
+			s.Source = "default"
+			return []Setting{s}, nil
+		}
+	}
+	return nil, nil
+}
+
+// defaults returns the effective values of the settings that have a default.
+func (c *Config) defaults() []Setting {
+	ttl, _ := c.ResponseCache.GetTTL()
+	level, _ := c.GetLogLevel()
+	return []Setting{
+		{Key: "model", Value: DefaultModel},
+		{Key: "title_model", Value: DefaultTitleModel},
+		{Key: "logfile", Value: c.GetLogfile()},
+		{Key: "log_level", Value: strings.ToLower(level.String())},
+		{Key: "session_dir", Value: c.GetSessionDir()},
+		{Key: "session_store", Value: c.GetSessionStore()},
+		{Key: "compaction.strategy", Value: "fail"},
+		{Key: "compaction.threshold", Value: c.Compaction.GetThreshold()},
+		{Key: "compaction.summary_model", Value: c.Compaction.GetSummaryModel()},
+		{Key: "response_cache.ttl", Value: ttl.String()},
+	}
+}
+
+// SetValue sets key to value in the config file at path, creating the file
+// if needed. value is parsed as a TOML value, e.g. `true`, `0.5` or
+// `["a", "b"]`, and taken as a string if it is not one.
+//
+// The file is rewritten, so comments and formatting are not preserved. It
+// is left unchanged if key is unknown or the result is not a valid config.
+func SetValue(path, key, value string) error {
+	return editFile(path, key, func(t map[string]any, name string) error {
+		v := make(map[string]any)
+		if _, err := toml.Decode("v = "+value, &v); err == nil {
+			t[name] = v["v"]
+		} else {
+			t[name] = value
+		}
+		return nil
+	})
+}
+
+// UnsetValue removes key from the config file at path.
+func UnsetValue(path, key string) error {
+	return editFile(path, key, func(t map[string]any, name string) error {
+		if _, ok := t[name]; !ok {
+			return fmt.Errorf("%s is not set in %s", key, path)
+		}
+		delete(t, name)
+		return nil
+	})
+}
+
+// editFile applies edit to the table holding key in the config file at path
+// and writes it back if the result is valid.
+func editFile(path, key string, edit func(t map[string]any, name string) error) error {
+	keyPath, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read file: %w", err)
+	}
+	data := make(map[string]any)
+	if _, err := toml.Decode(string(old), &data); err != nil {
+		return fmt.Errorf("%s: decode toml: %w", path, err)
+	}
+
+	t := data
+	for i, part := range keyPath[:len(keyPath)-1] {
+		switch sub := t[part].(type) {
+		case map[string]any:
+			t = sub
+		case nil:
+			next := make(map[string]any)
+			t[part] = next
+			t = next
+		default:
+			return fmt.Errorf("%s is not a table", formatKey(keyPath[:i+1]))
+		}
+	}
+	if err := edit(t, keyPath[len(keyPath)-1]); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(data); err != nil {
+		return fmt.Errorf("encode toml: %w", err)
+	}
+	md, err := toml.Decode(buf.String(), new(Config))
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	for _, k := range md.Undecoded() {
+		if slices.Equal(k, keyPath) {
+			return fmt.Errorf("unknown key %s", key)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	// Check the result as a whole, with persona and prompt files, and
+	// restore the file if it is not valid.
+	if _, err := LoadFile(path); err != nil {
+		if old == nil {
+			err = errors.Join(err, os.Remove(path))
+		} else {
+			err = errors.Join(err, os.WriteFile(path, old, 0644))
+		}
+		return err
+	}
+	return nil
+}
+
+// parseKey splits the dotted TOML key into its parts.
+func parseKey(key string) ([]string, error) {
+	m := make(map[string]any)
+	md, err := toml.Decode(key+" = 0", &m)
+	if err != nil || len(md.Keys()) != 1 {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	return md.Keys()[0], nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("model = \"gpt-4.1\"\n"), 0644))
+
+	require.NoError(t, SetValue(path, "log_level", "debug"))
+	require.NoError(t, SetValue(path, "providers.openai.max_retries", "3"))
+	require.NoError(t, SetValue(path, "providers.openai.base_url", "http://localhost:8080/v1"))
+	require.NoError(t, SetValue(path, "context", `["@notes.md", "Be brief."]`))
+
+	conf, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "gpt-4.1", conf.Model)
+	require.Equal(t, "debug", conf.LogLevel)
+	require.Equal(t, 3, *conf.Providers["openai"].MaxRetries)
+	require.Equal(t, "http://localhost:8080/v1", conf.Providers["openai"].BaseURL)
+	require.Len(t, conf.Context, 2)
+
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.ErrorContains(t, SetValue(path, "no_such_key", "1"), "unknown key")
+	require.ErrorContains(t, SetValue(path, "log_level", "loud"), "log_level")
+	require.ErrorContains(t, SetValue(path, "providers.openai.timeout", "soon"), "timeout")
+	require.ErrorContains(t, SetValue(path, "model.name", "x"), "model is not a table")
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(before), string(after))
+
+	require.NoError(t, UnsetValue(path, "log_level"))
+	require.Error(t, UnsetValue(path, "log_level"))
+	conf, err = LoadFile(path)
+	require.NoError(t, err)
+	require.Empty(t, conf.LogLevel)
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte(`
+logfile = "logs/aico.log"
+
+[providers.groq]
+base_url = "http://localhost:8080/v1"
+timeout = "30s"
+`), 0644))
+	conf, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "logs", "aico.log"), conf.GetLogfile())
+
+	settings, err := conf.Lookup("providers.groq")
+	require.NoError(t, err)
+	require.Len(t, settings, 2)
+	require.Equal(t, "providers.groq.base_url", settings[0].Key)
+
+	settings, err = conf.Lookup("log_level")
+	require.NoError(t, err)
+	require.Equal(t, []Setting{{Key: "log_level", Value: "info", Source: "default"}}, settings)
+
+	settings, err = conf.Lookup("budget.monthly")
+	require.NoError(t, err)
+	require.Empty(t, settings)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvPrefix is the prefix of the environment variables that override
+// settings. See [EnvVars].
+const EnvPrefix = "AICO_"
+
+// EnvVar is an environment variable overriding a setting.
+type EnvVar struct {
+	// Key is the dotted TOML key of the setting, e.g. "providers.openai.base_url".
+	Key string
+
+	// Name is the name of the variable, e.g. "AICO_PROVIDERS_OPENAI_BASE_URL".
+	Name string
+
+	// text is set for string settings, whose values are taken as is.
+	// Other values are parsed as TOML, e.g. "true" or `["a", "b"]`.
+	text bool
+
+	// list is set for lists of strings, which may also be given as a
+	// single item that is not a TOML array.
+	list bool
+}
+
+// EnvVars returns the environment variables that override settings: one for
+// each setting of [Config], including those of each provider, named
+// AICO_ followed by the key in upper case with "_" for ".". Personas, prompt
+// templates and profiles cannot be set from the environment.
+func EnvVars() []EnvVar {
+	return envVars(nil, reflect.TypeFor[Config]())
+}
+
+func envVars(prefix []string, t reflect.Type) []EnvVar {
+	var vars []EnvVar
+	for f := range t.Fields() {
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		path := append(slices.Clip(prefix), name)
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			vars = append(vars, envVars(path, f.Type)...)
+		case f.Type == reflect.TypeFor[map[string]Provider]():
+			for _, provider := range ProviderNames {
+				vars = append(vars, envVars(append(slices.Clip(path), provider), reflect.TypeFor[Provider]())...)
+			}
+		case f.Type.Kind() == reflect.Map:
+			// personas, prompts and profiles
+		default:
+			vars = append(vars, EnvVar{
+				Key:  formatKey(path),
+				Name: EnvPrefix + strings.ToUpper(strings.Join(path, "_")),
+				text: f.Type.Kind() == reflect.String,
+				list: f.Type == reflect.TypeFor[[]string](),
+			})
+		}
+	}
+	return vars
+}
+
+// envTable returns the settings of the environment variables of environ,
+// as "NAME=VALUE" entries, as a table, with the name of the variable
+// setting each key.
+func envTable(environ []string) (map[string]any, map[string]string, error) {
+	values := make(map[string]string)
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, EnvPrefix) {
+			values[name] = value
+		}
+	}
+	data := make(map[string]any)
+	sources := make(map[string]string)
+	for _, v := range EnvVars() {
+		s, ok := values[v.Name]
+		if !ok {
+			continue
+		}
+		var value any = s
+		if !v.text {
+			m := make(map[string]any)
+			_, err := toml.Decode("v = "+s, &m)
+			switch {
+			case v.list && (err != nil || !strings.HasPrefix(strings.TrimSpace(s), "[")):
+				value = []any{s}
+			case err != nil:
+				return nil, nil, fmt.Errorf("%s: invalid value %q", v.Name, s)
+			default:
+				value = m["v"]
+			}
+		}
+		path, err := parseKey(v.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		t := data
+		for _, part := range path[:len(path)-1] {
+			sub, ok := t[part].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				t[part] = sub
+			}
+			t = sub
+		}
+		t[path[len(path)-1]] = value
+		sources[v.Key] = "$" + v.Name
+	}
+	return data, sources, nil
+}
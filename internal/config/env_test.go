@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvVars(t *testing.T) {
+	names := make(map[string]string)
+	for _, v := range EnvVars() {
+		names[v.Key] = v.Name
+	}
+	require.Equal(t, "AICO_MODEL", names["model"])
+	require.Equal(t, "AICO_SESSION_DIR", names["session_dir"])
+	require.Equal(t, "AICO_COMPACTION_THRESHOLD", names["compaction.threshold"])
+	require.Equal(t, "AICO_PROVIDERS_OPENAI_BASE_URL", names["providers.openai.base_url"])
+	require.Equal(t, "AICO_PROVIDERS_CEREBRAS_API_KEY_CMD", names["providers.cerebras.api_key_cmd"])
+	require.NotContains(t, names, "persona")
+	require.NotContains(t, names, "profile")
+}
+
+func TestLoadLayers_Env(t *testing.T) {
+	user := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(user, []byte(`
+model = "claude-haiku-4-5"
+
+[profile.work]
+model = "gpt-4.1"
+`), 0644))
+
+	conf, err := loadLayers(user, "", "work", []string{
+		"AICO_MODEL=groq:llama-3.3-70b-versatile",
+		"AICO_RESPONSE_CACHE_ENABLED=true",
+		"AICO_COMPACTION_THRESHOLD=0.5",
+		"AICO_PROVIDERS_OPENAI_MAX_RETRIES=4",
+		"AICO_CONTEXT=@notes.md",
+		"AICO_OPENAI_API_KEY=sk-ignored",
+		"HOME=/home/me",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "groq:llama-3.3-70b-versatile", conf.Model)
+	require.True(t, conf.ResponseCache.Enabled)
+	require.Equal(t, 0.5, conf.Compaction.Threshold)
+	require.Equal(t, 4, *conf.Providers["openai"].MaxRetries)
+	require.Equal(t, []string{"@notes.md"}, conf.Context)
+	settings, err := conf.Lookup("model")
+	require.NoError(t, err)
+	require.Equal(t, "$AICO_MODEL", settings[0].Source)
+
+	// The environment applies without a config file too.
+	conf, err = loadLayers(filepath.Join(t.TempDir(), ConfigFileName), "", "", []string{"AICO_MODEL=mock:echo", "AICO_TITLE_MODEL=none"})
+	require.NoError(t, err)
+	require.Equal(t, "mock:echo", conf.Model)
+	require.Equal(t, TitleModelNone, conf.TitleModel)
+	require.Contains(t, conf.PersonaMap, "default")
+
+	_, err = loadLayers(user, "", "", []string{"AICO_RESPONSE_CACHE_ENABLED=maybe"})
+	require.ErrorContains(t, err, "AICO_RESPONSE_CACHE_ENABLED")
+	_, err = loadLayers(user, "", "", []string{"AICO_LOG_LEVEL=loud"})
+	require.ErrorContains(t, err, "log_level")
+}
@@ -0,0 +1,521 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"micheam.com/aico/internal/prompt"
+)
+
+// ProjectConfigFileName is the name of a project config file. See
+// [FindProjectConfig].
+const ProjectConfigFileName = ".aico.toml"
+
+// ProjectDirName is the name of the directory at the root of a project that
+// may hold its config.toml, personas and prompts.
+const ProjectDirName = ".aico"
+
+// projectDeniedKeys are the keys a project config may not set: a repository
+// must not be able to send API keys elsewhere, make aico write files outside
+// of it or trust itself.
+var projectDeniedKeys = []string{"providers", "logfile", "session_dir", "trusted_projects"}
+
+// Setting is a value of the configuration.
+type Setting struct {
+	// Key is the dotted TOML key, e.g. "persona.default.message".
+	Key string `json:"key"`
+
+	Value any `json:"value"`
+
+	// Source is the config file that sets the value.
+	Source string `json:"source"`
+}
+
+// layer is a config file and the directories of its personas and prompts.
+type layer struct {
+	path string
+	data map[string]any
+
+	// root is the directory relative context paths are resolved against.
+	root       string
+	personaDir string
+	promptDir  string
+
+	// personaFiles and promptFiles are the files read into data, by name.
+	personaFiles map[string]string
+	promptFiles  map[string]string
+
+	// sandbox is root for an untrusted project, see [Config.TrustedProjects].
+	sandbox string
+}
+
+// FindProjectConfig returns the project config that applies in dir: the
+// .aico.toml or .aico/config.toml of dir or of its closest parent that has
+// one. It returns an empty string if there is none.
+func FindProjectConfig(dir string) string {
+	for {
+		for _, path := range []string{
+			filepath.Join(dir, ProjectConfigFileName),
+			filepath.Join(dir, ProjectDirName, ConfigFileName),
+		} {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadLayers loads the user config at path with the project config at
+// project, if not empty, merged over it: tables are merged key by key, other
+// values of the project replace those of the user. Persona and prompt files
+// count as tables of the config file they belong to. The table of profile,
+// if not empty, is then merged over the result, and finally the settings of
+// the AICO_ variables of environ (see [EnvVars]).
+//
+// Unless the user config trusts the project, its context files and the
+// file defaults of its prompts must be inside of the project, and its
+// personas and prompts are sandboxed.
+//
+// A missing user config is replaced with [DefaultConfig], so that the
+// environment alone can configure aico, e.g. in a container.
+func loadLayers(path, project, profile string, environ []string) (*Config, error) {
+	user, err := readLayer(path)
+	missing := errors.Is(err, ErrConfigFileNotFound)
+	if missing {
+		user, err = &layer{path: path, data: map[string]any{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.root = filepath.Dir(path)
+	user.personaDir = filepath.Join(user.root, PersonaDirName)
+	user.promptDir = filepath.Join(user.root, PromptDirName)
+	layers := []*layer{user}
+
+	if project != "" && project != path {
+		l, err := readLayer(project)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range projectDeniedKeys {
+			if _, ok := l.data[key]; ok {
+				return nil, fmt.Errorf("%s: %s cannot be set in a project config", project, key)
+			}
+			for name, t := range tables(l.data, "profile") {
+				if _, ok := t.(map[string]any)[key]; ok {
+					return nil, fmt.Errorf("%s: %s cannot be set in a project config", project, formatKey([]string{"profile", name, key}))
+				}
+			}
+		}
+		l.root = filepath.Dir(project)
+		if filepath.Base(l.root) == ProjectDirName {
+			l.root = filepath.Dir(l.root)
+		}
+		l.personaDir = filepath.Join(l.root, ProjectDirName, PersonaDirName)
+		l.promptDir = filepath.Join(l.root, ProjectDirName, PromptDirName)
+		layers = append(layers, l)
+	}
+
+	merged := make(map[string]any)
+	sources := make(map[string]string)
+	if missing {
+		defaults, err := defaultTable()
+		if err != nil {
+			return nil, err
+		}
+		walkSettings(nil, defaults, func(key string, _ any) { sources[key] = "default" })
+		mergeTables(merged, defaults)
+	}
+	for _, l := range layers {
+		if l.personaFiles, err = l.addFiles("persona", l.personaDir, "message", func(b []byte) error {
+			_, err := ParsePersona(b)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("load personas: %w", err)
+		}
+		if l.promptFiles, err = l.addFiles("prompt", l.promptDir, "template", func(b []byte) error {
+			_, err := ParsePrompt(b)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("load prompts: %w", err)
+		}
+		resolveContextPaths(l.data, l.root)
+		for _, t := range tables(l.data, "profile") {
+			if t, ok := t.(map[string]any); ok {
+				resolveContextPaths(t, l.root)
+			}
+		}
+		walkSettings(nil, l.data, func(key string, _ any) { sources[key] = l.path })
+		for key, files := range map[string]map[string]string{"persona": l.personaFiles, "prompt": l.promptFiles} {
+			for name, path := range files {
+				walkSettings([]string{key, name}, tables(tables(l.data, key), name), func(key string, _ any) { sources[key] = path })
+			}
+		}
+		mergeTables(merged, l.data)
+	}
+	if profile != "" {
+		t := tables(tables(merged, "profile"), profile)
+		if t == nil {
+			return nil, fmt.Errorf("profile %q not found", profile)
+		}
+		if _, ok := t["profile"]; ok {
+			return nil, fmt.Errorf("profile %q: profiles cannot be nested", profile)
+		}
+		prefix := formatKey([]string{"profile", profile}) + "."
+		walkSettings(nil, t, func(key string, _ any) {
+			sources[key] = fmt.Sprintf("%s (profile %s)", sources[prefix+key], profile)
+		})
+		mergeTables(merged, t)
+	}
+	env, envSources, err := envTable(environ)
+	if err != nil {
+		return nil, err
+	}
+	mergeTables(merged, env)
+	maps.Copy(sources, envSources)
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(merged); err != nil {
+		return nil, fmt.Errorf("encode merged config: %w", err)
+	}
+	config := new(Config)
+	md, err := toml.NewDecoder(&buf).Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("decode toml: %w", err)
+	}
+	config.location = path
+	config.profile = profile
+	if len(layers) > 1 {
+		config.project = project
+		if l := layers[1]; !config.trusts(l.root) {
+			if err := l.checkPaths(); err != nil {
+				return nil, err
+			}
+			l.sandbox = l.root
+		}
+	}
+	undecoded := make(map[string]bool)
+	for _, k := range md.Undecoded() {
+		undecoded[formatKey(k)] = true
+	}
+	walkSettings(nil, merged, func(key string, v any) {
+		s := Setting{Key: key, Value: v, Source: sources[key]}
+		config.settings = append(config.settings, s)
+		if undecoded[key] {
+			config.unknown = append(config.unknown, s)
+		}
+	})
+
+	// A persona or prompt belongs to the directory of the layer that sets
+	// its text, as its includes and context paths are relative to it.
+	setDirs := func(l *layer, data map[string]any) {
+		for name, t := range tables(data, "persona") {
+			p := config.PersonaMap[name]
+			if _, ok := t.(map[string]any)["message"]; ok || p.dir == "" {
+				p.dir = l.personaDir
+				p.sandbox = l.sandbox
+			}
+			if path, ok := l.personaFiles[name]; ok {
+				p.path = path
+			}
+			config.PersonaMap[name] = p
+		}
+		for name, t := range tables(data, "prompt") {
+			p := config.PromptMap[name]
+			if _, ok := t.(map[string]any)["template"]; ok || p.dir == "" {
+				p.dir = l.promptDir
+				p.sandbox = l.sandbox
+			}
+			if path, ok := l.promptFiles[name]; ok {
+				p.path = path
+			}
+			config.PromptMap[name] = p
+		}
+	}
+	for _, l := range layers {
+		setDirs(l, l.data)
+	}
+	if profile != "" {
+		for _, l := range layers {
+			setDirs(l, tables(tables(l.data, "profile"), profile))
+		}
+	}
+
+	for name, p := range config.PromptMap {
+		if !personaNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid prompt name %q", name)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("prompt %q: %w", name, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return config, nil
+}
+
+// defaultTable returns the settings of [DefaultConfig] as a table.
+func defaultTable() (map[string]any, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(DefaultConfig()); err != nil {
+		return nil, fmt.Errorf("encode default config: %w", err)
+	}
+	data := make(map[string]any)
+	if _, err := toml.NewDecoder(&buf).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode default config: %w", err)
+	}
+	deleteZeros(data)
+	return data, nil
+}
+
+// deleteZeros deletes the zero values of m, which the encoder writes for
+// unset fields, and the tables left empty.
+func deleteZeros(m map[string]any) {
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			deleteZeros(sub)
+			if len(sub) == 0 {
+				delete(m, k)
+			}
+			continue
+		}
+		if reflect.ValueOf(v).IsZero() {
+			delete(m, k)
+		}
+	}
+}
+
+// readLayer reads the config file at path.
+func readLayer(path string) (*layer, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrConfigFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	// Decode into Config first to report type errors against the file.
+	if _, err := toml.Decode(string(b), new(Config)); err != nil {
+		return nil, fmt.Errorf("%s: decode toml: %w", path, err)
+	}
+	data := make(map[string]any)
+	if _, err := toml.Decode(string(b), &data); err != nil {
+		return nil, fmt.Errorf("%s: decode toml: %w", path, err)
+	}
+	return &layer{path: path, data: data}, nil
+}
+
+// addFiles adds each Markdown file NAME.md of dir as the table NAME of the
+// table key of l, with the text below the front matter under textKey. check
+// validates the file. It returns the paths of the files by name.
+func (l *layer) addFiles(key, dir, textKey string, check func([]byte) error) (map[string]string, error) {
+	files, err := markdownFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s dir: %w", key, err)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	t := tables(l.data, key)
+	if t == nil {
+		t = make(map[string]any)
+		l.data[key] = t
+	}
+	for name, path := range files {
+		if _, ok := t[name]; ok {
+			return nil, fmt.Errorf("%s %q is defined in both %s and %s", key, name, filepath.Base(l.path), path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s file: %w", key, err)
+		}
+		if err := check(b); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		m := make(map[string]any)
+		text, err := decodeFrontMatter(b, &m)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		m[textKey] = text
+		t[name] = m
+	}
+	return files, nil
+}
+
+// mergeTables merges src into dst, copying tables so that dst shares none
+// with src.
+func mergeTables(dst, src map[string]any) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			d, ok := dst[k].(map[string]any)
+			if !ok {
+				d = make(map[string]any)
+				dst[k] = d
+			}
+			mergeTables(d, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// walkSettings calls fn for each value of the table m that is not a table
+// itself, in key order.
+func walkSettings(prefix []string, m map[string]any, fn func(key string, v any)) {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		key := append(slices.Clip(prefix), k)
+		if sub, ok := m[k].(map[string]any); ok {
+			walkSettings(key, sub, fn)
+			continue
+		}
+		fn(formatKey(key), m[k])
+	}
+}
+
+// formatKey returns the dotted TOML key of path, quoting parts that are not
+// bare keys.
+func formatKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = p
+		if p == "" || strings.ContainsFunc(p, func(r rune) bool {
+			return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+		}) {
+			parts[i] = fmt.Sprintf("%q", p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// tables returns the table of the given key in data, e.g. the personas.
+func tables(data map[string]any, key string) map[string]any {
+	t, _ := data[key].(map[string]any)
+	return t
+}
+
+// resolveContextPaths makes the relative "@path" entries of the context of
+// data relative to root.
+func resolveContextPaths(data map[string]any, root string) {
+	contexts, _ := data["context"].([]any)
+	for i, c := range contexts {
+		s, _ := c.(string)
+		if path, ok := strings.CutPrefix(s, "@"); ok && !filepath.IsAbs(path) {
+			contexts[i] = "@" + filepath.Join(root, path)
+		}
+	}
+}
+
+// checkPaths reports an error for a "@path" context entry or a default of
+// a file argument of a prompt of l that is outside of its root. Relative
+// context paths of personas are relative to the persona directory, other
+// relative paths to the root. Defaults are checked again when read, against
+// the working directory (see [prompt.ReadFile]).
+func (l *layer) checkPaths() error {
+	checkPath := func(source string, key []string, path, dir string) error {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if !prompt.InDir(l.root, path) {
+			return fmt.Errorf("%s: %s: %s is outside of the project; add %s to trusted_projects in your config.toml to allow it",
+				source, formatKey(key), path, l.root)
+		}
+		return nil
+	}
+	checkContext := func(source string, key []string, data map[string]any, dir string) error {
+		contexts, _ := data["context"].([]any)
+		for _, c := range contexts {
+			s, _ := c.(string)
+			if path, ok := strings.CutPrefix(s, "@"); ok {
+				if err := checkPath(source, append(slices.Clip(key), "context"), path, dir); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	checkTable := func(key []string, data map[string]any) error {
+		if err := checkContext(l.path, key, data, l.root); err != nil {
+			return err
+		}
+		for name, t := range tables(data, "persona") {
+			t, _ := t.(map[string]any)
+			source := l.path
+			if path, ok := l.personaFiles[name]; ok && key == nil {
+				source = path
+			}
+			if err := checkContext(source, append(slices.Clip(key), "persona", name), t, l.personaDir); err != nil {
+				return err
+			}
+		}
+		for name, t := range tables(data, "prompt") {
+			t, _ := t.(map[string]any)
+			source := l.path
+			if path, ok := l.promptFiles[name]; ok && key == nil {
+				source = path
+			}
+			for _, kind := range []string{"args", "flags"} {
+				args, _ := t[kind].([]map[string]any)
+				for _, a := range args {
+					file, _ := a["file"].(bool)
+					s, _ := a["default"].(string)
+					if !file || s == "" {
+						continue
+					}
+					if err := checkPath(source, append(slices.Clip(key), "prompt", name, kind, "default"), strings.TrimPrefix(s, "@"), l.root); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if err := checkTable(nil, l.data); err != nil {
+		return err
+	}
+	for name, t := range tables(l.data, "profile") {
+		t, _ := t.(map[string]any)
+		if err := checkTable([]string{"profile", name}, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Profile returns the name of the profile merged into c, or an empty string
+// if there is none.
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// ProjectLocation returns the path of the project config merged into c, or
+// an empty string if there is none.
+func (c *Config) ProjectLocation() string {
+	return c.project
+}
+
+// UnknownKeys returns the settings of the config files that aico does not
+// know, e.g. misspelled keys, in key order.
+func (c *Config) UnknownKeys() []Setting {
+	return c.unknown
+}
+
+// Settings returns the values set in the config files, including persona
+// and prompt files, in key order.
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.Empty(t, FindProjectConfig(sub))
+
+	nested := filepath.Join(root, ProjectDirName, ConfigFileName)
+	require.NoError(t, os.Mkdir(filepath.Dir(nested), 0755))
+	require.NoError(t, os.WriteFile(nested, nil, 0644))
+	require.Equal(t, nested, FindProjectConfig(sub))
+
+	closer := filepath.Join(root, "a", ProjectConfigFileName)
+	require.NoError(t, os.WriteFile(closer, nil, 0644))
+	require.Equal(t, closer, FindProjectConfig(sub))
+}
+
+func TestLoadLayers(t *testing.T) {
+	user := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(user, []byte(`
+model = "claude-haiku-4-5"
+title_model = "none"
+context = ["@notes.md"]
+
+[persona.default]
+description = "Default"
+message = "Hi."
+`), 0644))
+	personas := filepath.Join(filepath.Dir(user), PersonaDirName)
+	require.NoError(t, os.Mkdir(personas, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(personas, "quick.md"), []byte("+++\ntemperature = 0.2\n+++\nBe quick."), 0644))
+
+	root := t.TempDir()
+	project := filepath.Join(root, ProjectConfigFileName)
+	require.NoError(t, os.WriteFile(project, []byte(`
+model = "gpt-4.1"
+context = ["@CONTRIBUTING.md", "Use Go 1.26."]
+
+[persona.default]
+message = "Hello."
+
+[persona.quick]
+temperature = 0.9
+`), 0644))
+	projectPersonas := filepath.Join(root, ProjectDirName, PersonaDirName)
+	require.NoError(t, os.MkdirAll(projectPersonas, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectPersonas, "reviewer.md"), []byte("Review."), 0644))
+
+	conf, err := loadLayers(user, project, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, user, conf.Location())
+	require.Equal(t, project, conf.ProjectLocation())
+	require.Equal(t, "gpt-4.1", conf.Model)
+	require.Equal(t, "none", conf.TitleModel)
+	require.Equal(t, []string{"@" + filepath.Join(root, "CONTRIBUTING.md"), "Use Go 1.26."}, conf.Context)
+
+	// Tables merge key by key, files included.
+	require.Equal(t, "Default", conf.PersonaMap["default"].Description)
+	require.Equal(t, "Hello.", conf.PersonaMap["default"].Message)
+	require.Equal(t, projectPersonas, conf.PersonaMap["default"].Dir())
+	quick := conf.PersonaMap["quick"]
+	require.Equal(t, "Be quick.", quick.Message)
+	require.Equal(t, 0.9, *quick.Temperature)
+	require.Equal(t, filepath.Join(personas, "quick.md"), quick.Path())
+	require.Equal(t, personas, quick.Dir())
+	require.Empty(t, quick.Sandbox())
+	require.Equal(t, root, conf.PersonaMap["default"].Sandbox())
+	require.Equal(t, filepath.Join(projectPersonas, "reviewer.md"), conf.PersonaMap["reviewer"].Path())
+
+	sources := map[string]string{}
+	for _, s := range conf.Settings() {
+		sources[s.Key] = s.Source
+	}
+	require.Equal(t, project, sources["model"])
+	require.Equal(t, user, sources["title_model"])
+	require.Equal(t, user, sources["persona.default.description"])
+	require.Equal(t, filepath.Join(personas, "quick.md"), sources["persona.quick.message"])
+	require.Equal(t, project, sources["persona.quick.temperature"])
+
+	// Without a user config, the project config applies over the defaults.
+	missing := filepath.Join(t.TempDir(), ConfigFileName)
+	conf, err = loadLayers(missing, project, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, "gpt-4.1", conf.Model)
+	require.Equal(t, "Hello.", conf.PersonaMap["default"].Message)
+	require.Equal(t, "Default", conf.PersonaMap["default"].Description)
+	conf, err = loadLayers(missing, "", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, missing, conf.Location())
+	require.Equal(t, DefaultModel, conf.Model)
+	settings, err := conf.Lookup("model")
+	require.NoError(t, err)
+	require.Equal(t, "default", settings[0].Source)
+	_, err = LoadFile(missing)
+	require.ErrorIs(t, err, ErrConfigFileNotFound)
+
+	require.NoError(t, os.WriteFile(project, []byte("[providers.openai]\nbase_url = \"http://example.com\"\n"), 0644))
+	_, err = loadLayers(user, project, "", nil)
+	require.ErrorContains(t, err, "providers cannot be set in a project config")
+}
+
+func TestUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte(`
+modle = "gpt-4.1"
+
+[persona.default]
+message = "Hi."
+temprature = 0.2
+`), 0644))
+	personas := filepath.Join(filepath.Dir(path), PersonaDirName)
+	require.NoError(t, os.Mkdir(personas, 0755))
+	quick := filepath.Join(personas, "quick.md")
+	require.NoError(t, os.WriteFile(quick, []byte("+++\nefort = \"low\"\n+++\nBe quick."), 0644))
+
+	conf, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []Setting{
+		{Key: "modle", Value: "gpt-4.1", Source: path},
+		{Key: "persona.default.temprature", Value: 0.2, Source: path},
+		{Key: "persona.quick.efort", Value: "low", Source: quick},
+	}, conf.UnknownKeys())
+}
+
+func TestLoadLayers_Profile(t *testing.T) {
+	user := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(user, []byte(`
+model = "claude-haiku-4-5"
+
+[persona.default]
+message = "Hi."
+
+[providers.openai]
+api_key_cmd = "pass show personal/openai"
+
+[profile.work]
+model = "gpt-4.1"
+session_dir = "work"
+
+[profile.work.providers.openai]
+api_key_cmd = "pass show work/openai"
+
+[profile.work.persona.default]
+message = "Hello, colleague."
+`), 0644))
+
+	conf, err := loadLayers(user, "", "", nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Profile())
+	require.Equal(t, "claude-haiku-4-5", conf.Model)
+	require.Equal(t, "pass show personal/openai", conf.Providers["openai"].APIKeyCmd)
+
+	conf, err = loadLayers(user, "", "work", nil)
+	require.NoError(t, err)
+	require.Equal(t, "work", conf.Profile())
+	require.Equal(t, "gpt-4.1", conf.Model)
+	require.Equal(t, filepath.Join(filepath.Dir(user), "work"), conf.GetSessionDir())
+	require.Equal(t, "pass show work/openai", conf.Providers["openai"].APIKeyCmd)
+	require.Equal(t, "Hello, colleague.", conf.PersonaMap["default"].Message)
+	require.Equal(t, filepath.Join(filepath.Dir(user), PersonaDirName), conf.PersonaMap["default"].Dir())
+	settings, err := conf.Lookup("model")
+	require.NoError(t, err)
+	require.Equal(t, user+" (profile work)", settings[0].Source)
+
+	_, err = loadLayers(user, "", "home", nil)
+	require.ErrorContains(t, err, `profile "home" not found`)
+
+	project := filepath.Join(t.TempDir(), ProjectConfigFileName)
+	require.NoError(t, os.WriteFile(project, []byte("[profile.work]\nsession_dir = \"/tmp\"\n"), 0644))
+	_, err = loadLayers(user, project, "", nil)
+	require.ErrorContains(t, err, "profile.work.session_dir cannot be set in a project config")
+}
+
+func TestLoadLayers_Untrusted(t *testing.T) {
+	user := filepath.Join(t.TempDir(), ConfigFileName)
+	require.NoError(t, os.WriteFile(user, []byte("[persona.default]\nmessage = \"Hi.\"\n"), 0644))
+	root := filepath.Join(t.TempDir(), "app")
+	project := filepath.Join(root, ProjectConfigFileName)
+	projectPersonas := filepath.Join(root, ProjectDirName, PersonaDirName)
+	require.NoError(t, os.MkdirAll(projectPersonas, 0755))
+	reviewer := filepath.Join(projectPersonas, "reviewer.md")
+	require.NoError(t, os.WriteFile(reviewer, []byte("+++\ncontext = [\"@../../docs/review.md\"]\n+++\nReview."), 0644))
+
+	for _, tt := range []struct{ config, key string }{
+		{`context = ["@../secrets.txt"]`, "context"},
+		{`context = ["@/etc/passwd"]`, "context"},
+		{"[persona.default]\ncontext = [\"@../../../.ssh/id_rsa\"]", "persona.default.context"},
+		{"[profile.work]\ncontext = [\"@~/../x\"]\n[profile.work.persona.default]\ncontext = [\"@/x\"]", "profile.work.persona.default.context"},
+		{"[prompt.leak]\ntemplate = \"{{.Vars.f}}\"\n[[prompt.leak.args]]\nname = \"f\"\nfile = true\ndefault = \"@/etc/passwd\"", "prompt.leak.args.default"},
+		{"[profile.work.prompt.leak]\ntemplate = \"{{.Vars.f}}\"\n[[profile.work.prompt.leak.flags]]\nname = \"f\"\nfile = true\ndefault = \"../.env\"", "profile.work.prompt.leak.flags.default"},
+	} {
+		require.NoError(t, os.WriteFile(project, []byte(tt.config), 0644))
+		_, err := loadLayers(user, project, "", nil)
+		require.ErrorContains(t, err, tt.key+": ", tt.config)
+		require.ErrorContains(t, err, "is outside of the project", tt.config)
+	}
+
+	require.NoError(t, os.WriteFile(reviewer, []byte("+++\ncontext = [\"@../../../.env\"]\n+++\nReview."), 0644))
+	_, err := loadLayers(user, project, "", nil)
+	require.ErrorContains(t, err, reviewer+": persona.reviewer.context: ")
+
+	// Trusting the project or a parent lifts the checks and the sandbox.
+	require.NoError(t, os.WriteFile(project, []byte("[persona.default]\nmessage = \"Hello.\"\ncontext = [\"@/etc/hosts\"]\n"), 0644))
+	require.NoError(t, os.WriteFile(user, []byte(`trusted_projects = ["`+filepath.Dir(root)+`"]`+"\n[persona.default]\nmessage = \"Hi.\"\n"), 0644))
+	conf, err := loadLayers(user, project, "", nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.PersonaMap["default"].Sandbox())
+	require.Empty(t, conf.PersonaMap["reviewer"].Sandbox())
+
+	require.NoError(t, os.WriteFile(project, []byte(`trusted_projects = ["/"]`), 0644))
+	_, err = loadLayers(user, project, "", nil)
+	require.ErrorContains(t, err, "trusted_projects cannot be set in a project config")
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PersonaDirName is the name of the directory next to the config file that
+// holds persona files.
+const PersonaDirName = "personas"
+
+// PersonaFileExt is the extension of persona files.
+const PersonaFileExt = ".md"
+
+// frontMatterDelim opens and closes the TOML front matter of a persona file.
+const frontMatterDelim = "+++"
+
+var (
+	// ErrPersonaNotFound is returned when no persona has the given name.
+	ErrPersonaNotFound = errors.New("persona not found")
+
+	// ErrPersonaExists is returned when creating a persona whose name is
+	// already taken.
+	ErrPersonaExists = errors.New("persona already exists")
+
+	// ErrPersonaNotInFile is returned when modifying a persona defined in
+	// config.toml rather than in a persona file.
+	ErrPersonaNotInFile = errors.New("persona is defined in the config file")
+)
+
+var personaNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidatePersonaName reports whether name can be used as the name of a
+// persona file.
+func ValidatePersonaName(name string) error {
+	if !personaNamePattern.MatchString(name) || strings.HasSuffix(name, PersonaFileExt) {
+		return fmt.Errorf("invalid persona name %q: use letters, digits, '-', '_' and '.'", name)
+	}
+	return nil
+}
+
+// PersonaDir returns the directory of persona files
+func (c *Config) PersonaDir() string {
+	return filepath.Join(filepath.Dir(c.location), PersonaDirName)
+}
+
+// PersonaPath returns the path of the persona file with the given name.
+// The file may not exist.
+func (c *Config) PersonaPath(name string) string {
+	return filepath.Join(c.PersonaDir(), name+PersonaFileExt)
+}
+
+// markdownFiles returns the paths of the Markdown files in dir by name,
+// following symlinks and skipping hidden files, READMEs and directories. A
+// missing dir has no files.
+func markdownFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), PersonaFileExt)
+		if !ok || strings.HasPrefix(name, ".") || strings.EqualFold(name, "readme") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue // broken symlink or directory
+		}
+		files[name] = path
+	}
+	return files, nil
+}
+
+// ReadPersonaFile reads the persona file at path.
+func ReadPersonaFile(path string) (*Personality, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read persona file: %w", err)
+	}
+	p, err := ParsePersona(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	p.path = path
+	return p, nil
+}
+
+// ParsePersona parses a persona file: optional TOML front matter between
+// "+++" lines, with the same keys as a [persona.NAME] table, followed by the
+// system message in Markdown.
+//
+//	+++
+//	description = "Reviews Go code"
+//	+++
+//	You are a meticulous Go reviewer.
+func ParsePersona(b []byte) (*Personality, error) {
+	var p Personality
+	text, err := decodeFrontMatter(b, &p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Message != "" {
+		return nil, errors.New("front matter must not set message; write it below the front matter")
+	}
+	p.Message = text
+	return &p, nil
+}
+
+// decodeFrontMatter decodes the optional TOML front matter of a Markdown
+// file into v and returns the trimmed text below it.
+func decodeFrontMatter(b []byte, v any) (string, error) {
+	text := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, frontMatterDelim+"\n"); ok {
+		front, body, found := strings.Cut(rest, "\n"+frontMatterDelim+"\n")
+		if !found {
+			front, found = strings.CutSuffix(rest, "\n"+frontMatterDelim)
+		}
+		if !found {
+			return "", fmt.Errorf("front matter is not closed with %q", frontMatterDelim)
+		}
+		if _, err := toml.Decode(front, v); err != nil {
+			return "", fmt.Errorf("decode front matter: %w", err)
+		}
+		text = body
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// FormatPersona returns p in the format of a persona file.
+func FormatPersona(p Personality) ([]byte, error) {
+	message := p.Message
+	p.Message = ""
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelim + "\n")
+	if err := toml.NewEncoder(&buf).Encode(p); err != nil {
+		return nil, fmt.Errorf("encode front matter: %w", err)
+	}
+	buf.WriteString(frontMatterDelim + "\n")
+	buf.WriteString(message)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// WritePersona creates the persona file for name. It fails with
+// [ErrPersonaExists] if a persona of that name exists.
+func (c *Config) WritePersona(name string, p Personality) (string, error) {
+	if err := ValidatePersonaName(name); err != nil {
+		return "", err
+	}
+	if _, ok := c.PersonaMap[name]; ok {
+		return "", fmt.Errorf("%w: %s", ErrPersonaExists, name)
+	}
+	b, err := FormatPersona(p)
+	if err != nil {
+		return "", err
+	}
+	path := c.PersonaPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("mkdir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("%w: %s", ErrPersonaExists, path)
+		}
+		return "", fmt.Errorf("create persona file: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write persona file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("close persona file: %w", err)
+	}
+	p.path = path
+	p.dir = filepath.Dir(path)
+	if c.PersonaMap == nil {
+		c.PersonaMap = make(map[string]Personality)
+	}
+	c.PersonaMap[name] = p
+	return path, nil
+}
+
+// RemovePersona deletes the persona file of name. If the file is a symlink,
+// only the link is removed.
+func (c *Config) RemovePersona(name string) error {
+	p, ok := c.PersonaMap[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPersonaNotFound, name)
+	}
+	if p.path == "" {
+		return fmt.Errorf("%w: %s", ErrPersonaNotInFile, name)
+	}
+	if err := os.Remove(p.path); err != nil {
+		return fmt.Errorf("remove persona file: %w", err)
+	}
+	delete(c.PersonaMap, name)
+	return nil
+}
+
+// ResolvePersona returns the persona of name with the personas it extends
+// applied: their messages lead, base first, separated by blank lines, their
+// context files come first, and their settings fill in those it leaves empty.
+// It is sandboxed if any of them is.
+func (c *Config) ResolvePersona(name string) (*Personality, error) {
+	p, ok := c.PersonaMap[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPersonaNotFound, name)
+	}
+	seen := map[string]bool{name: true}
+	messages := []string{p.Message}
+	contexts := [][]string{p.Context}
+	for base := p.Extends; base != ""; {
+		if seen[base] {
+			return nil, fmt.Errorf("persona %q: extends loops back to %q", name, base)
+		}
+		seen[base] = true
+		b, ok := c.PersonaMap[base]
+		if !ok {
+			return nil, fmt.Errorf("persona %q: extends unknown persona %q", name, base)
+		}
+		messages = append(messages, b.Message)
+		contexts = append(contexts, b.Context)
+		p.Description = cmp.Or(p.Description, b.Description)
+		p.Cache = cmp.Or(p.Cache, b.Cache)
+		p.Model = cmp.Or(p.Model, b.Model)
+		p.MaxTokens = cmp.Or(p.MaxTokens, b.MaxTokens)
+		p.Effort = cmp.Or(p.Effort, b.Effort)
+		p.sandbox = cmp.Or(p.sandbox, b.sandbox)
+		if p.Temperature == nil {
+			p.Temperature = b.Temperature
+		}
+		base = b.Extends
+	}
+	slices.Reverse(messages)
+	messages = slices.DeleteFunc(messages, func(m string) bool { return m == "" })
+	p.Message = strings.Join(messages, "\n\n")
+	slices.Reverse(contexts)
+	p.Context = slices.Concat(contexts...)
+	return &p, nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePersona(t *testing.T) {
+	p, err := ParsePersona([]byte("+++\r\ndescription = \"Reviewer\"\r\ncache = \"1h\"\r\n+++\r\n\r\nReview the code.\r\n\r\n- Be terse.\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, Personality{Description: "Reviewer", Cache: "1h", Message: "Review the code.\n\n- Be terse."}, *p)
+
+	p, err = ParsePersona([]byte("Just a message.\n"))
+	require.NoError(t, err)
+	require.Equal(t, Personality{Message: "Just a message."}, *p)
+
+	_, err = ParsePersona([]byte("+++\ndescription = \"x\"\nNo end."))
+	require.Error(t, err)
+	_, err = ParsePersona([]byte("+++\nmessage = \"x\"\n+++\n"))
+	require.Error(t, err)
+}
+
+func TestFormatPersona(t *testing.T) {
+	temp := 0.7
+	want := Personality{Description: "Writer", Message: "Write well.\n\nReally.", Model: "gpt-4.1", Temperature: &temp, MaxTokens: 512, Effort: "low", Context: []string{"@style.md"}}
+	b, err := FormatPersona(want)
+	require.NoError(t, err)
+	got, err := ParsePersona(b)
+	require.NoError(t, err)
+	require.Equal(t, want, *got)
+}
+
+func TestLoadPersonaDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("[persona.default]\nmessage = \"Hi\"\n"), 0644))
+	personas := filepath.Join(dir, PersonaDirName)
+	require.NoError(t, os.Mkdir(personas, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(personas, "coder.md"), []byte("+++\ndescription = \"Coder\"\n+++\nWrite Go.\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(personas, "README.md"), []byte("# Our personas\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(personas, "notes.txt"), []byte("ignored"), 0644))
+
+	// A shared library linked in file by file.
+	shared := filepath.Join(t.TempDir(), "reviewer.md")
+	require.NoError(t, os.WriteFile(shared, []byte("Review."), 0644))
+	require.NoError(t, os.Symlink(shared, filepath.Join(personas, "reviewer.md")))
+
+	conf, err := load(path)
+	require.NoError(t, err)
+	require.Len(t, conf.PersonaMap, 3)
+	require.Empty(t, conf.PersonaMap["default"].Path())
+	require.Equal(t, "Coder", conf.PersonaMap["coder"].Description)
+	require.Equal(t, "Write Go.", conf.PersonaMap["coder"].Message)
+	require.Equal(t, "Review.", conf.PersonaMap["reviewer"].Message)
+
+	// Write, then remove the link without touching the shared file.
+	_, err = conf.WritePersona("coder", Personality{Message: "x"})
+	require.ErrorIs(t, err, ErrPersonaExists)
+	_, err = conf.WritePersona("../evil", Personality{Message: "x"})
+	require.Error(t, err)
+	created, err := conf.WritePersona("writer", Personality{Message: "Write."})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(personas, "writer.md"), created)
+	require.NoError(t, conf.RemovePersona("reviewer"))
+	require.FileExists(t, shared)
+	require.ErrorIs(t, conf.RemovePersona("default"), ErrPersonaNotInFile)
+
+	conf, err = load(path)
+	require.NoError(t, err)
+	require.Equal(t, "Write.", conf.PersonaMap["writer"].Message)
+	require.NotContains(t, conf.PersonaMap, "reviewer")
+
+	// The same name in config.toml and the directory is ambiguous.
+	require.NoError(t, os.WriteFile(filepath.Join(personas, "default.md"), []byte("Hello."), 0644))
+	_, err = load(path)
+	require.ErrorContains(t, err, `persona "default" is defined in both`)
+}
+
+func TestResolvePersona(t *testing.T) {
+	temp := 0.2
+	conf := &Config{PersonaMap: map[string]Personality{
+		"base":     {Description: "Base", Message: "Be kind.", Cache: "1h", Model: "claude-opus-4-8", Temperature: &temp, Context: []string{"@style.md"}, sandbox: "/app"},
+		"reviewer": {Message: "Review Go.", Extends: "base", Effort: "high", Context: []string{"go.mod"}},
+		"strict":   {Description: "Strict", Message: "Be strict.", Cache: "off", Extends: "reviewer"},
+		"empty":    {Extends: "base"},
+		"loop":     {Extends: "loop2"},
+		"loop2":    {Extends: "loop"},
+		"orphan":   {Extends: "ghost"},
+	}}
+
+	p, err := conf.ResolvePersona("strict")
+	require.NoError(t, err)
+	require.Equal(t, Personality{
+		Description: "Strict",
+		Message:     "Be kind.\n\nReview Go.\n\nBe strict.",
+		Cache:       "off",
+		Extends:     "reviewer",
+		Model:       "claude-opus-4-8",
+		Temperature: &temp,
+		Effort:      "high",
+		Context:     []string{"@style.md", "go.mod"},
+		sandbox:     "/app",
+	}, *p)
+
+	p, err = conf.ResolvePersona("empty")
+	require.NoError(t, err)
+	require.Equal(t, "Be kind.", p.Message)
+	require.Equal(t, "1h", p.Cache)
+	require.Equal(t, []string{"@style.md"}, p.Context)
+
+	_, err = conf.ResolvePersona("loop")
+	require.ErrorContains(t, err, "loops back")
+	_, err = conf.ResolvePersona("orphan")
+	require.ErrorContains(t, err, `unknown persona "ghost"`)
+	_, err = conf.ResolvePersona("nobody")
+	require.ErrorIs(t, err, ErrPersonaNotFound)
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// PromptDirName is the name of the directory next to the config file that
+// holds prompt template files.
+const PromptDirName = "prompts"
+
+// Values of [Prompt.Stdin].
+const (
+	StdinOptional = "optional"
+	StdinRequired = "required"
+)
+
+// Prompt is a reusable prompt template, run with `aico run NAME`.
+type Prompt struct {
+	// Description is shown by `aico prompts ls` and in the help of the
+	// template.
+	Description string `toml:"description"`
+
+	// Template is the prompt, rendered like the message of a persona with
+	// the arguments available as {{.Vars.NAME}}.
+	Template string `toml:"template,omitempty"`
+
+	// Persona is the persona to use unless --persona is given.
+	Persona string `toml:"persona,omitempty"`
+
+	// Model is the model to use unless --model is given. It overrides the
+	// model of the persona.
+	Model string `toml:"model,omitempty"`
+
+	// Stdin is "required" if the template needs input on stdin (or
+	// --source), which is passed as the source of the prompt. Empty means
+	// "optional".
+	Stdin string `toml:"stdin,omitempty"`
+
+	// Args are the positional arguments, in order.
+	Args []PromptArg `toml:"args,omitempty"`
+
+	// Flags are the named arguments, given as --NAME VALUE.
+	Flags []PromptArg `toml:"flags,omitempty"`
+
+	// path is the prompt file this template was read from, or empty if it is
+	// defined in the config file.
+	path string
+
+	// dir is the prompt directory of the config p is defined in.
+	dir string
+
+	// sandbox is the root of the untrusted project p is defined in, if any.
+	sandbox string
+}
+
+// PromptArg declares an argument of a [Prompt].
+type PromptArg struct {
+	// Name is the name of the argument, and of the flag for named ones.
+	Name string `toml:"name"`
+
+	// Description is shown in the help of the template.
+	Description string `toml:"description,omitempty"`
+
+	// Default is the value of an argument that is not given.
+	Default string `toml:"default,omitempty"`
+
+	// Required makes it an error to omit the argument.
+	Required bool `toml:"required,omitempty"`
+
+	// File makes the value a file path, with an optional "@" prefix; the
+	// template receives the contents of the file.
+	File bool `toml:"file,omitempty"`
+}
+
+// Path returns the prompt file p was read from, or an empty string if p is
+// defined in the config file.
+func (p Prompt) Path() string {
+	return p.path
+}
+
+// Dir returns the prompt directory of the user or project config p belongs
+// to. Includes of the template are relative to it.
+func (p Prompt) Dir() string {
+	return p.dir
+}
+
+// Sandbox returns the root of the untrusted project p is defined in, or an
+// empty string if p is trusted. The template is rendered with it as
+// [prompt.Options.Sandbox].
+func (p Prompt) Sandbox() string {
+	return p.sandbox
+}
+
+// Validate reports whether p is well-formed.
+func (p Prompt) Validate() error {
+	switch p.Stdin {
+	case "", StdinOptional, StdinRequired:
+	default:
+		return fmt.Errorf("invalid stdin %q: want %q or %q", p.Stdin, StdinOptional, StdinRequired)
+	}
+	seen := make(map[string]bool)
+	optional := false
+	for _, a := range slices.Concat(p.Args, p.Flags) {
+		if !personaNamePattern.MatchString(a.Name) {
+			return fmt.Errorf("invalid argument name %q", a.Name)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("argument %q is declared twice", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	for _, a := range p.Args {
+		if a.Required && optional {
+			return fmt.Errorf("required argument %q follows an optional one", a.Name)
+		}
+		optional = !a.Required
+	}
+	return nil
+}
+
+// PromptDir returns the directory of prompt template files.
+func (c *Config) PromptDir() string {
+	return filepath.Join(filepath.Dir(c.location), PromptDirName)
+}
+
+// ReadPromptFile reads the prompt template file at path.
+func ReadPromptFile(path string) (*Prompt, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt file: %w", err)
+	}
+	p, err := ParsePrompt(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	p.path = path
+	return p, nil
+}
+
+// ParsePrompt parses a prompt template file: optional TOML front matter
+// between "+++" lines, with the same keys as a [prompt.NAME] table, followed
+// by the template.
+//
+//	+++
+//	description = "Write a commit message for the staged changes"
+//	stdin = "required"
+//	[[flags]]
+//	name = "style"
+//	default = "conventional"
+//	+++
+//	Write a {{.Vars.style}} commit message for this diff.
+func ParsePrompt(b []byte) (*Prompt, error) {
+	var p Prompt
+	text, err := decodeFrontMatter(b, &p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Template != "" {
+		return nil, errors.New("front matter must not set template; write it below the front matter")
+	}
+	p.Template = text
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
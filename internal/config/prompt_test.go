@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrompt(t *testing.T) {
+	p, err := ParsePrompt([]byte("+++\ndescription = \"Explain\"\nstdin = \"required\"\n[[args]]\nname = \"file\"\nrequired = true\nfile = true\n[[flags]]\nname = \"lang\"\ndefault = \"Go\"\n+++\nExplain {{.Vars.file}}.\n"))
+	require.NoError(t, err)
+	require.Equal(t, Prompt{
+		Description: "Explain",
+		Template:    "Explain {{.Vars.file}}.",
+		Stdin:       StdinRequired,
+		Args:        []PromptArg{{Name: "file", Required: true, File: true}},
+		Flags:       []PromptArg{{Name: "lang", Default: "Go"}},
+	}, *p)
+
+	for _, b := range []string{
+		"+++\ntemplate = \"x\"\n+++\n",
+		"+++\nstdin = \"always\"\n+++\n",
+		"+++\n[[args]]\nname = \"a\"\n[[flags]]\nname = \"a\"\n+++\n",
+		"+++\n[[args]]\nname = \"a\"\n[[args]]\nname = \"b\"\nrequired = true\n+++\n",
+		"+++\n[[flags]]\nname = \"--x\"\n+++\n",
+	} {
+		_, err := ParsePrompt([]byte(b))
+		require.Error(t, err, b)
+	}
+}
+
+func TestLoadPromptDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("[prompt.review]\ntemplate = \"Review.\"\n"), 0644))
+	prompts := filepath.Join(dir, PromptDirName)
+	require.NoError(t, os.Mkdir(prompts, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(prompts, "commit-msg.md"), []byte("Write a commit message.\n"), 0644))
+
+	conf, err := load(path)
+	require.NoError(t, err)
+	require.Len(t, conf.PromptMap, 2)
+	require.Empty(t, conf.PromptMap["review"].Path())
+	require.Equal(t, filepath.Join(prompts, "commit-msg.md"), conf.PromptMap["commit-msg"].Path())
+	require.Equal(t, "Write a commit message.", conf.PromptMap["commit-msg"].Template)
+
+	require.NoError(t, os.WriteFile(filepath.Join(prompts, "review.md"), []byte("Again."), 0644))
+	_, err = load(path)
+	require.ErrorContains(t, err, `prompt "review" is defined in both`)
+
+	require.NoError(t, os.Remove(filepath.Join(prompts, "review.md")))
+	require.NoError(t, os.WriteFile(path, []byte("[prompt.review]\nstdin = \"never\"\n"), 0644))
+	_, err = load(path)
+	require.ErrorContains(t, err, `prompt "review"`)
+}
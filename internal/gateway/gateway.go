@@ -0,0 +1,379 @@
+// Package gateway serves aico's models over an OpenAI-compatible HTTP API,
+// so that any tool built on an OpenAI SDK can reach every provider through
+// one local endpoint.
+//
+// Only the subset of the Chat Completions API that maps onto
+// [assistant.GenerativeModel] is supported: text and image URL messages,
+// streaming and non-streaming replies, usage, and the temperature,
+// max_tokens and reasoning_effort parameters. Other parameters are accepted
+// and ignored.
+package gateway
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/logging"
+)
+
+// PersonaHeader selects the persona of a request, overriding
+// [Options.Persona].
+const PersonaHeader = "X-Aico-Persona"
+
+// maxRequestBytes limits the size of request bodies.
+const maxRequestBytes = 10 << 20
+
+// ErrUnknownModel is returned by [Options.Resolve] for model names that no
+// provider supports.
+var ErrUnknownModel = errors.New("unknown model")
+
+// Options configures the handler returned by [NewHandler].
+type Options struct {
+	// Models are listed by /v1/models.
+	Models []assistant.ModelDescriptor
+
+	// Resolve returns a ready-to-use model for a request. It should wrap
+	// [ErrUnknownModel] if the model name is not supported.
+	Resolve func(ctx context.Context, req ModelRequest) (assistant.GenerativeModel, error)
+
+	// Personas maps persona names to their system prompt.
+	Personas map[string]string
+
+	// Persona is the persona used when a request has no [PersonaHeader].
+	// If empty, only the system messages of the request are used.
+	Persona string
+
+	// Token, if set, is required as bearer token on every request.
+	Token string
+
+	// Logger receives a line per request.
+	Logger *logging.Logger
+}
+
+// ModelRequest is what [Options.Resolve] needs to know of a request.
+type ModelRequest struct {
+	// Model is the model name of the request, e.g.
+	// "anthropic:claude-haiku-4-5" or "gpt-4.1". It is empty if the request
+	// leaves the model to the persona.
+	Model string
+
+	// Persona is the persona of the request, from its [PersonaHeader] or
+	// [Options.Persona], or empty if there is none.
+	Persona string
+
+	// Params are the generation parameters the request sets. They take
+	// precedence over those of the persona.
+	Params assistant.GenerationParams
+}
+
+// NewHandler returns an http.Handler serving /v1/chat/completions and
+// /v1/models.
+func NewHandler(opts Options) http.Handler {
+	if opts.Logger == nil {
+		opts.Logger = logging.LoggerFrom(context.Background())
+	}
+	s := &server{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	return s.logRequests(s.authenticate(mux))
+}
+
+type server struct {
+	opts Options
+}
+
+// -----------------------------------------------------------------------------
+// Middleware
+// -----------------------------------------------------------------------------
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key",
+				"missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (s *server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		ctx := logging.ContextWith(r.Context(), s.opts.Logger)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		s.opts.Logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start).String(),
+			"remote", r.RemoteAddr)
+	})
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	list := modelList{Object: "list", Data: []model{}}
+	for _, m := range s.opts.Models {
+		list.Data = append(list.Data, model{
+			ID:      m.Provider() + ":" + m.Name(),
+			Object:  "model",
+			OwnedBy: m.Provider(),
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.LoggerFrom(ctx)
+
+	var req chatRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "decode request: "+err.Error())
+		return
+	}
+	effort, err := assistant.ParseEffort(req.ReasoningEffort)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "reasoning_effort: "+err.Error())
+		return
+	}
+	persona := s.persona(r)
+	system, msgs, err := s.convertMessages(persona, req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	m, err := s.opts.Resolve(ctx, ModelRequest{
+		Model:   req.Model,
+		Persona: persona,
+		Params: assistant.GenerationParams{
+			Temperature: req.Temperature,
+			MaxTokens:   cmp.Or(req.MaxCompletionTokens, req.MaxTokens),
+			Effort:      effort,
+		},
+	})
+	if errors.Is(err, ErrUnknownModel) {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found",
+			fmt.Sprintf("the model %q does not exist", req.Model))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	m.SetSystemInstruction(system...)
+	logger = logger.With("model", m.Provider()+":"+m.Name(), "stream", req.Stream)
+	ctx = logging.ContextWith(ctx, logger)
+
+	c := completion{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   cmp.Or(req.Model, m.Provider()+":"+m.Name()),
+	}
+	if req.Stream {
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		s.streamCompletion(ctx, w, m, msgs, c, includeUsage)
+		return
+	}
+
+	resp, err := m.GenerateContent(ctx, msgs...)
+	if err != nil {
+		logger.Error("generate content", "error", err)
+		writeError(w, http.StatusBadGateway, "api_error", "", err.Error())
+		return
+	}
+	var text string
+	if t, ok := resp.Content.(*assistant.TextContent); ok {
+		text = t.Text
+	}
+	logUsage(logger, resp.Usage)
+	writeJSON(w, http.StatusOK, chatResponse{
+		completion: c,
+		Object:     "chat.completion",
+		Choices: []choice{{
+			Message:      responseMessage{Role: "assistant", Content: text},
+			FinishReason: "stop",
+		}},
+		Usage: toUsage(resp.Usage),
+	})
+}
+
+// streamCompletion writes the reply as server-sent events. Once the first
+// event is sent, errors can only be reported in-band.
+func (s *server) streamCompletion(ctx context.Context, w http.ResponseWriter, m assistant.GenerativeModel, msgs []assistant.Message, c completion, includeUsage bool) {
+	logger := logging.LoggerFrom(ctx)
+	seq, err := m.GenerateContentStream(ctx, msgs...)
+	if err != nil {
+		logger.Error("generate content stream", "error", err)
+		writeError(w, http.StatusBadGateway, "api_error", "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	send := func(v any) bool {
+		b, err := json.Marshal(v)
+		if err != nil {
+			logger.Error("marshal event", "error", err)
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return false // client went away
+		}
+		rc.Flush()
+		return true
+	}
+	chunk := func(d delta, finish *string) chatChunk {
+		return chatChunk{
+			completion: c,
+			Object:     "chat.completion.chunk",
+			Choices:    []chunkChoice{{Delta: d, FinishReason: finish}},
+		}
+	}
+
+	if !send(chunk(delta{Role: "assistant"}, nil)) {
+		return
+	}
+	var usage *assistant.Usage
+	for resp, err := range seq {
+		if err != nil {
+			logger.Error("stream", "error", err)
+			send(errorResponse{Error: apiError{Message: err.Error(), Type: "api_error"}})
+			return
+		}
+		if resp.Usage != nil {
+			usage = resp.Usage
+			continue
+		}
+		text, ok := resp.Content.(*assistant.TextContent)
+		if !ok {
+			logger.Warn("ignore unsupported content type", "type", fmt.Sprintf("%T", resp.Content))
+			continue
+		}
+		if !send(chunk(delta{Content: text.Text}, nil)) {
+			return
+		}
+	}
+	stop := "stop"
+	if !send(chunk(delta{}, &stop)) {
+		return
+	}
+	logUsage(logger, usage)
+	if includeUsage && usage != nil {
+		if !send(chatChunk{completion: c, Object: "chat.completion.chunk", Choices: []chunkChoice{}, Usage: toUsage(usage)}) {
+			return
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	rc.Flush()
+}
+
+// persona returns the persona of r, or an empty string if there is none.
+func (s *server) persona(r *http.Request) string {
+	return cmp.Or(r.Header.Get(PersonaHeader), s.opts.Persona)
+}
+
+// convertMessages splits the request messages into the system instruction,
+// led by the prompt of persona, and the conversation.
+func (s *server) convertMessages(persona string, src []chatMessage) ([]*assistant.TextContent, []assistant.Message, error) {
+	var system []*assistant.TextContent
+	if persona != "" {
+		prompt, ok := s.opts.Personas[persona]
+		if !ok {
+			return nil, nil, fmt.Errorf("persona %q not found", persona)
+		}
+		system = append(system, assistant.NewTextContent(prompt))
+	}
+
+	var msgs []assistant.Message
+	for i, m := range src {
+		contents, err := m.contents()
+		if err != nil {
+			return nil, nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		switch m.Role {
+		case "system", "developer":
+			for _, c := range contents {
+				text, ok := c.(*assistant.TextContent)
+				if !ok {
+					return nil, nil, fmt.Errorf("messages[%d]: system messages must be text", i)
+				}
+				system = append(system, text)
+			}
+		case "user":
+			msgs = append(msgs, assistant.NewUserMessage(contents...))
+		case "assistant":
+			msgs = append(msgs, assistant.NewAssistantMessage(contents...))
+		default:
+			return nil, nil, fmt.Errorf("messages[%d]: unsupported role %q", i, m.Role)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil, nil, errors.New("messages must contain a user message")
+	}
+	return system, msgs, nil
+}
+
+func logUsage(logger *logging.Logger, u *assistant.Usage) {
+	if u == nil {
+		return
+	}
+	logger.Info("usage",
+		"input_tokens", u.InputTokens,
+		"output_tokens", u.OutputTokens,
+		"cached_input_tokens", u.CachedInputTokens)
+}
+
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, typ, code, msg string) {
+	writeJSON(w, status, errorResponse{Error: apiError{Message: msg, Type: typ, Code: code}})
+}
@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/providers/mock"
+)
+
+func newTestServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	h := NewHandler(Options{
+		Models: mock.AvailableModels(),
+		Resolve: func(ctx context.Context, req ModelRequest) (assistant.GenerativeModel, error) {
+			if req.Model != "mock:echo" {
+				return nil, ErrUnknownModel
+			}
+			return mock.NewEcho(mock.Options{}), nil
+		},
+		Personas: map[string]string{"pirate": "Talk like a pirate."},
+		Token:    token,
+	})
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, srv *httptest.Server, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestChatCompletions(t *testing.T) {
+	srv := newTestServer(t, "")
+	resp := post(t, srv, `{
+		"model": "mock:echo",
+		"temperature": 0.2,
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": [{"type": "text", "text": "Hello gateway"}]}
+		]
+	}`, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message      responseMessage `json:"message"`
+			FinishReason string          `json:"finish_reason"`
+		} `json:"choices"`
+		Usage usage `json:"usage"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "chat.completion", got.Object)
+	require.Equal(t, "mock:echo", got.Model)
+	require.Equal(t, responseMessage{Role: "assistant", Content: "Hello gateway"}, got.Choices[0].Message)
+	require.Equal(t, "stop", got.Choices[0].FinishReason)
+	require.Positive(t, got.Usage.PromptTokens)
+	require.Equal(t, got.Usage.PromptTokens+got.Usage.CompletionTokens, got.Usage.TotalTokens)
+}
+
+func TestChatCompletions_Stream(t *testing.T) {
+	srv := newTestServer(t, "")
+	resp := post(t, srv, `{
+		"model": "mock:echo",
+		"stream": true,
+		"stream_options": {"include_usage": true},
+		"messages": [{"role": "user", "content": "one two three"}]
+	}`, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var (
+		text   strings.Builder
+		events []chatChunk
+		done   bool
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var c chatChunk
+		require.NoError(t, json.Unmarshal([]byte(data), &c))
+		events = append(events, c)
+		for _, ch := range c.Choices {
+			text.WriteString(ch.Delta.Content)
+		}
+	}
+	require.True(t, done)
+	require.Equal(t, "one two three", text.String())
+	require.Equal(t, "assistant", events[0].Choices[0].Delta.Role)
+
+	finish := events[len(events)-2].Choices[0].FinishReason
+	require.NotNil(t, finish)
+	require.Equal(t, "stop", *finish)
+	last := events[len(events)-1]
+	require.Empty(t, last.Choices)
+	require.NotNil(t, last.Usage)
+	require.Equal(t, assistant.EstimateTokens("one two three"), last.Usage.CompletionTokens)
+}
+
+func TestChatCompletions_Errors(t *testing.T) {
+	srv := newTestServer(t, "secret")
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	resp := post(t, srv, `{"model": "mock:echo", "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = post(t, srv, `{"model": "nope", "messages": [{"role": "user", "content": "hi"}]}`, auth)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var e errorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+	require.Equal(t, "model_not_found", e.Error.Code)
+
+	resp = post(t, srv, `{"model": "mock:echo", "messages": [{"role": "tool", "content": "42"}]}`, auth)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = post(t, srv, `{"model": "mock:echo", "messages": [{"role": "user", "content": "hi"}]}`,
+		map[string]string{"Authorization": "Bearer secret", PersonaHeader: "ghost"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = post(t, srv, `{"model": "mock:echo", "messages": [{"role": "user", "content": "hi"}]}`,
+		map[string]string{"Authorization": "Bearer secret", PersonaHeader: "pirate"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestChatCompletions_ModelRequest(t *testing.T) {
+	requests := make(chan ModelRequest, 1)
+	srv := httptest.NewServer(NewHandler(Options{
+		Resolve: func(ctx context.Context, req ModelRequest) (assistant.GenerativeModel, error) {
+			requests <- req
+			return mock.NewEcho(mock.Options{}), nil
+		},
+		Personas: map[string]string{"pirate": "Talk like a pirate.", "poet": "Rhyme."},
+		Persona:  "poet",
+	}))
+	t.Cleanup(srv.Close)
+
+	resp := post(t, srv, `{
+		"model": "mock:echo",
+		"temperature": 0.2,
+		"max_tokens": 100,
+		"reasoning_effort": "low",
+		"messages": [{"role": "user", "content": "hi"}]
+	}`, map[string]string{PersonaHeader: "pirate"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	temp := 0.2
+	require.Equal(t, ModelRequest{
+		Model:   "mock:echo",
+		Persona: "pirate",
+		Params:  assistant.GenerationParams{Temperature: &temp, MaxTokens: 100, Effort: assistant.EffortLow},
+	}, <-requests)
+
+	// Without a model, the persona's or the configured one is used.
+	resp = post(t, srv, `{"max_completion_tokens": 50, "max_tokens": 100, "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, ModelRequest{Persona: "poet", Params: assistant.GenerationParams{MaxTokens: 50}}, <-requests)
+	var got completion
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "mock:echo", got.Model)
+
+	resp = post(t, srv, `{"model": "mock:echo", "reasoning_effort": "extreme", "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestModels(t *testing.T) {
+	srv := newTestServer(t, "")
+	resp, err := http.Get(srv.URL + "/v1/models")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var list modelList
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Equal(t, "list", list.Object)
+	require.Len(t, list.Data, len(mock.AvailableModels()))
+	require.Equal(t, "mock:echo", list.Data[0].ID)
+	require.Equal(t, "mock", list.Data[0].OwnedBy)
+}
@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// Wire types of the OpenAI Chat Completions API, as far as they are served.
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// MaxCompletionTokens replaces MaxTokens in newer clients.
+	MaxTokens           int    `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int    `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string `json:"reasoning_effort,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role string `json:"role"`
+
+	// Content is either a string or an array of content parts.
+	Content json.RawMessage `json:"content"`
+}
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+func (m chatMessage) contents() ([]assistant.MessageContent, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return nil, errors.New("content is required")
+	}
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return []assistant.MessageContent{assistant.NewTextContent(text)}, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, errors.New("content must be a string or an array of content parts")
+	}
+	var contents []assistant.MessageContent
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			contents = append(contents, assistant.NewTextContent(p.Text))
+		case "image_url":
+			if p.ImageURL == nil {
+				return nil, errors.New("image_url part without url")
+			}
+			u, err := url.Parse(p.ImageURL.URL)
+			if err != nil {
+				return nil, fmt.Errorf("image_url: %w", err)
+			}
+			contents = append(contents, assistant.NewURLImageContent(*u))
+		default:
+			return nil, fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+	}
+	return contents, nil
+}
+
+// completion holds the fields shared by responses and stream chunks.
+type completion struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+}
+
+type chatResponse struct {
+	completion
+	Object  string   `json:"object"`
+	Choices []choice `json:"choices"`
+	Usage   *usage   `json:"usage,omitempty"`
+}
+
+type choice struct {
+	Index        int             `json:"index"`
+	Message      responseMessage `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+}
+
+type responseMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatChunk struct {
+	completion
+	Object  string        `json:"object"`
+	Choices []chunkChoice `json:"choices"`
+	Usage   *usage        `json:"usage,omitempty"`
+}
+
+type chunkChoice struct {
+	Index        int     `json:"index"`
+	Delta        delta   `json:"delta"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+type delta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type usage struct {
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *promptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+type promptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+func toUsage(u *assistant.Usage) *usage {
+	if u == nil {
+		return nil
+	}
+	return &usage{
+		PromptTokens:        u.InputTokens,
+		CompletionTokens:    u.OutputTokens,
+		TotalTokens:         u.InputTokens + u.OutputTokens,
+		PromptTokensDetails: &promptTokensDetails{CachedTokens: u.CachedInputTokens},
+	}
+}
+
+type modelList struct {
+	Object string  `json:"object"`
+	Data   []model `json:"data"`
+}
+
+type model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
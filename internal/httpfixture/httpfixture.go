@@ -0,0 +1,316 @@
+// Package httpfixture records HTTP interactions with provider APIs to
+// fixture files, and replays them in tests without network access or API
+// keys.
+//
+// A fixture is a JSON file holding the request and response of each round
+// trip in order. Streaming (server-sent event) responses are stored as
+// their raw body, so replaying them exercises the same parsers as a live
+// stream. Request headers are never stored, as they carry API keys.
+//
+// [Capture] is also what openai.DebugTransport prints round trips with.
+package httpfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RecordEnv is the environment variable that switches [NewTestClient] to
+// recording. Set it to a non-empty value to re-record fixtures against the
+// real APIs.
+const RecordEnv = "AICO_RECORD_FIXTURES"
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single HTTP round trip.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of an HTTP request.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+
+	// Body is the request body. JSON bodies are kept as JSON for
+	// readability, anything else as a string.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body"`
+}
+
+// Transport is an http.RoundTripper that either records the round trips
+// made through it, or replays previously recorded ones in order.
+type Transport struct {
+	path      string
+	recording bool
+	next      http.RoundTripper // recording only
+
+	mu      sync.Mutex
+	fixture Fixture
+	pos     int
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewRecorder returns a Transport that sends requests through next and
+// records them. Call [Transport.Save] to write the fixture to path.
+func NewRecorder(path string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{path: path, recording: true, next: next}
+}
+
+// NewReplayer returns a Transport that answers requests from the fixture
+// at path. Requests must come in the recorded order, with the same method,
+// URL path and body.
+func NewReplayer(path string) (*Transport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	t := &Transport{path: path}
+	if err := json.Unmarshal(b, &t.fixture); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Recording reports whether t records rather than replays.
+func (t *Transport) Recording() bool { return t.recording }
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.recording {
+		return t.record(req)
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.replay(req, body)
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	resp, in, err := Capture(t.next, req)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fixture.Interactions = append(t.fixture.Interactions, in)
+	return resp, nil
+}
+
+// Capture sends req through next and returns the response together with
+// the round trip as an [Interaction], without request headers. The whole
+// response body is buffered, so streams arrive at once rather than
+// incrementally; the body of the returned response can be read as usual.
+func Capture(next http.RoundTripper, req *http.Request) (*http.Response, Interaction, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, Interaction{}, err
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, Interaction{}, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, Interaction{}, fmt.Errorf("read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := make(map[string]string)
+	for _, k := range []string{"Content-Type", "Request-Id", "X-Request-Id"} {
+		if v := resp.Header.Get(k); v != "" {
+			header[k] = v
+		}
+	}
+	return resp, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   rawBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       string(respBody),
+		},
+	}, nil
+}
+
+func (t *Transport) replay(req *http.Request, body []byte) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pos >= len(t.fixture.Interactions) {
+		return nil, fmt.Errorf("httpfixture: unexpected request %s %s: all %d interactions of %s were replayed",
+			req.Method, req.URL, len(t.fixture.Interactions), t.path)
+	}
+	want := t.fixture.Interactions[t.pos]
+	if err := match(want.Request, req, body); err != nil {
+		return nil, fmt.Errorf("httpfixture: interaction %d of %s: %w", t.pos, t.path, err)
+	}
+	t.pos++
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", want.Response.StatusCode, http.StatusText(want.Response.StatusCode)),
+		StatusCode:    want.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(want.Response.Body)),
+		ContentLength: int64(len(want.Response.Body)),
+		Request:       req,
+	}
+	for k, v := range want.Response.Header {
+		resp.Header.Set(k, v)
+	}
+	return resp, nil
+}
+
+// match reports how req differs from the recorded request. The host is
+// ignored, so fixtures replay regardless of the configured base URL.
+func match(want Request, req *http.Request, body []byte) error {
+	if req.Method != want.Method {
+		return fmt.Errorf("method %s, recorded %s", req.Method, want.Method)
+	}
+	if path := recordedPath(want.URL); req.URL.Path != path {
+		return fmt.Errorf("path %s, recorded %s", req.URL.Path, path)
+	}
+	if !sameBody(want.Body, rawBody(body)) {
+		return fmt.Errorf("request body differs from the recording:\n got: %s\nwant: %s", body, want.Body)
+	}
+	return nil
+}
+
+func recordedPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
+
+// sameBody compares JSON bodies semantically and others byte by byte.
+func sameBody(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (t *Transport) Save() error {
+	if !t.recording {
+		return errors.New("httpfixture: not recording")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, err := json.MarshalIndent(t.fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("create fixture dir: %w", err)
+	}
+	return os.WriteFile(t.path, append(b, '\n'), 0644)
+}
+
+// Remaining returns the number of recorded interactions not replayed yet.
+func (t *Transport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.fixture.Interactions) - t.pos
+}
+
+// TB is the part of testing.TB used by [NewTestClient]. The package does not
+// import testing, so that [Capture] can be used outside of tests.
+type TB interface {
+	Helper()
+	Cleanup(func())
+	Failed() bool
+	Errorf(format string, args ...any)
+	Fatal(args ...any)
+}
+
+// NewTestClient returns an HTTP client for the fixture testdata/<name>.json.
+//
+// By default, the fixture is replayed, and the test fails if it is not
+// fully consumed. If [RecordEnv] is set, requests go to the network and
+// the fixture is rewritten when the test ends.
+func NewTestClient(t TB, name string) (*http.Client, *Transport) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".json")
+	if os.Getenv(RecordEnv) != "" {
+		tr := NewRecorder(path, http.DefaultTransport)
+		t.Cleanup(func() {
+			if t.Failed() {
+				return
+			}
+			if err := tr.Save(); err != nil {
+				t.Errorf("save fixture: %v", err)
+			}
+		})
+		return &http.Client{Transport: tr}, tr
+	}
+	tr, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if n := tr.Remaining(); n > 0 && !t.Failed() {
+			t.Errorf("httpfixture: %d interactions of %s were not replayed", n, path)
+		}
+	})
+	return &http.Client{Transport: tr}, tr
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// rawBody returns body as JSON if it is valid JSON, or as a JSON string.
+func rawBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	s, _ := json.Marshal(string(body))
+	return s
+}
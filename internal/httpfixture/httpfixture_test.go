@@ -0,0 +1,53 @@
+package httpfixture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport_RecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"n\":1}\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	post := func(client *http.Client, body string) (string, error) {
+		resp, err := client.Post(srv.URL+"/v1/chat", "application/json", strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	rec := NewRecorder(path, nil)
+	got, err := post(&http.Client{Transport: rec}, `{"stream": true}`)
+	require.NoError(t, err)
+	require.Equal(t, "data: {\"n\":1}\n\ndata: [DONE]\n\n", got)
+	require.NoError(t, rec.Save())
+
+	rep, err := NewReplayer(path)
+	require.NoError(t, err)
+	srv.Close() // replays need no server
+	client := &http.Client{Transport: rep}
+
+	_, err = post(client, `{"stream":false}`)
+	require.ErrorContains(t, err, "request body differs")
+
+	got, err = post(client, `{"stream":true}`) // formatting does not matter
+	require.NoError(t, err)
+	require.Equal(t, "data: {\"n\":1}\n\ndata: [DONE]\n\n", got)
+	require.Zero(t, rep.Remaining())
+
+	_, err = post(client, `{"stream":true}`)
+	require.ErrorContains(t, err, "all 1 interactions")
+}
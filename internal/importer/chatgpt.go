@@ -0,0 +1,174 @@
+package importer
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// chatGPTConversation is an entry of conversations.json in a ChatGPT export.
+//
+// Messages form a tree (editing a prompt or regenerating a reply creates a
+// branch); current_node is the leaf of the branch shown in the UI.
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug    string `json:"model_slug"`
+		VisuallyHide bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// ChatGPT converts the conversations.json file of a ChatGPT data export.
+//
+// Only the branch that was current at export time is kept. System and tool
+// messages, as well as non-text parts such as uploaded images, are skipped.
+func ChatGPT(r io.Reader) ([]*assistant.Session, error) {
+	var convs []chatGPTConversation
+	if err := json.NewDecoder(r).Decode(&convs); err != nil {
+		return nil, fmt.Errorf("decode ChatGPT export: %w", err)
+	}
+
+	var sessions []*assistant.Session
+	for _, conv := range convs {
+		b := new(builder)
+		for _, node := range conv.path() {
+			if msg := node.Message.toMessage(); msg != nil {
+				b.add(msg)
+			}
+		}
+		if len(b.msgs) == 0 {
+			continue
+		}
+		sess := assistant.NewSession(nil, b.msgs...)
+		if id := cmp.Or(conv.ConversationID, conv.ID); id != "" {
+			sess.ID = id
+		}
+		sess.Title = conv.Title
+		sess.CreatedAt = unixSeconds(conv.CreateTime)
+		sess.UpdatedAt = unixSeconds(conv.UpdateTime)
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+// path returns the nodes from the root to the current node.
+func (c chatGPTConversation) path() []chatGPTNode {
+	leaf := c.CurrentNode
+	if _, ok := c.Mapping[leaf]; !ok {
+		leaf = c.lastLeaf()
+	}
+	var nodes []chatGPTNode
+	seen := make(map[string]bool)
+	for id := leaf; id != "" && !seen[id]; {
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		nodes = append(nodes, node)
+		id = node.Parent
+	}
+	slices.Reverse(nodes)
+	return nodes
+}
+
+// lastLeaf follows the most recent child from the root, for exports without
+// a usable current_node.
+func (c chatGPTConversation) lastLeaf() string {
+	var id string
+	for nid, node := range c.Mapping {
+		if node.Parent == "" {
+			id = nid
+			break
+		}
+	}
+	for depth := 0; depth < len(c.Mapping); depth++ {
+		node := c.Mapping[id]
+		if len(node.Children) == 0 {
+			break
+		}
+		id = node.Children[len(node.Children)-1]
+	}
+	return id
+}
+
+// toMessage converts m, returning nil for messages that are not part of the
+// visible conversation.
+func (m *chatGPTMessage) toMessage() assistant.Message {
+	if m == nil || m.Metadata.VisuallyHide {
+		return nil
+	}
+	var texts []string
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		for _, raw := range m.Content.Parts {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				texts = append(texts, s)
+			}
+		}
+	case "code":
+		texts = append(texts, "```\n"+m.Content.Text+"\n```")
+	default:
+		return nil
+	}
+	contents := textContents(texts...)
+	if len(contents) == 0 {
+		return nil
+	}
+
+	switch m.Author.Role {
+	case "user":
+		msg := assistant.NewUserMessage(contents...)
+		msg.CreatedAt = unixSeconds(m.CreateTime)
+		return msg
+	case "assistant":
+		msg := assistant.NewAssistantMessage(contents...)
+		msg.Model = m.Metadata.ModelSlug
+		msg.CreatedAt = unixSeconds(m.CreateTime)
+		return msg
+	default:
+		return nil
+	}
+}
+
+// unixSeconds converts a fractional Unix timestamp. Zero yields the zero
+// time.
+func unixSeconds(f float64) time.Time {
+	if f <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
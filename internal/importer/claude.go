@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// claudeConversation is an entry of conversations.json in a Claude.ai export.
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Attachments []struct {
+		FileName         string `json:"file_name"`
+		ExtractedContent string `json:"extracted_content"`
+	} `json:"attachments"`
+}
+
+// Claude converts the conversations.json file of a Claude.ai data export.
+//
+// The text of attachments is kept inline; other files, thinking blocks and
+// tool use are skipped.
+func Claude(r io.Reader) ([]*assistant.Session, error) {
+	var convs []claudeConversation
+	if err := json.NewDecoder(r).Decode(&convs); err != nil {
+		return nil, fmt.Errorf("decode Claude export: %w", err)
+	}
+
+	var sessions []*assistant.Session
+	for _, conv := range convs {
+		b := new(builder)
+		for _, m := range conv.ChatMessages {
+			if msg := m.toMessage(); msg != nil {
+				b.add(msg)
+			}
+		}
+		if len(b.msgs) == 0 {
+			continue
+		}
+		sess := assistant.NewSession(nil, b.msgs...)
+		if conv.UUID != "" {
+			sess.ID = conv.UUID
+		}
+		sess.Title = conv.Name
+		sess.CreatedAt = conv.CreatedAt
+		sess.UpdatedAt = conv.UpdatedAt
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+func (m claudeMessage) toMessage() assistant.Message {
+	var texts []string
+	for _, att := range m.Attachments {
+		if att.ExtractedContent == "" {
+			continue
+		}
+		texts = append(texts, assistant.AttachmentContent{
+			Name:    att.FileName,
+			Syntax:  strings.TrimPrefix(filepath.Ext(att.FileName), "."),
+			Content: []byte(att.ExtractedContent),
+		}.ToText())
+	}
+	// Newer exports split messages into typed content blocks; older ones
+	// only have text.
+	var blockTexts []string
+	for _, c := range m.Content {
+		if c.Type == "text" {
+			blockTexts = append(blockTexts, c.Text)
+		}
+	}
+	if len(blockTexts) > 0 {
+		texts = append(texts, blockTexts...)
+	} else {
+		texts = append(texts, m.Text)
+	}
+	contents := textContents(texts...)
+	if len(contents) == 0 {
+		return nil
+	}
+
+	switch m.Sender {
+	case "human":
+		msg := assistant.NewUserMessage(contents...)
+		msg.CreatedAt = m.CreatedAt
+		return msg
+	case "assistant":
+		msg := assistant.NewAssistantMessage(contents...)
+		msg.CreatedAt = m.CreatedAt
+		return msg
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+// Package importer converts conversations exported from other chat
+// applications into aico sessions.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// Format is the name of a supported export format.
+type Format string
+
+const (
+	// FormatChatGPT is the conversations.json file of a ChatGPT data export.
+	FormatChatGPT Format = "chatgpt"
+
+	// FormatClaude is the conversations.json file of a Claude.ai data export.
+	FormatClaude Format = "claude"
+
+	// FormatOpenAIMessages is a plain OpenAI Chat Completions `messages`
+	// array, optionally wrapped in an object with a `messages` field.
+	FormatOpenAIMessages Format = "openai-messages"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatChatGPT, FormatClaude, FormatOpenAIMessages}
+
+// Import reads conversations in the given format from r.
+//
+// The returned sessions are not bound to a store; save them with
+// [assistant.SessionStore.Save]. Each session is tagged with the format
+// name. Conversations without any message are skipped.
+func Import(format Format, r io.Reader) ([]*assistant.Session, error) {
+	var (
+		sessions []*assistant.Session
+		err      error
+	)
+	switch format {
+	case FormatChatGPT:
+		sessions, err = ChatGPT(r)
+	case FormatClaude:
+		sessions, err = Claude(r)
+	case FormatOpenAIMessages:
+		sessions, err = OpenAIMessages(r)
+	default:
+		names := make([]string, len(Formats))
+		for i, f := range Formats {
+			names[i] = string(f)
+		}
+		return nil, fmt.Errorf("unknown import format %q (valid: %s)", format, strings.Join(names, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		// Set directly: AddTags would bump UpdatedAt.
+		sess.Tags = []string{string(format)}
+	}
+	return sessions, nil
+}
+
+// builder collects messages into a session, merging consecutive messages
+// of the same author so that user and assistant messages alternate.
+type builder struct {
+	msgs []assistant.Message
+}
+
+func (b *builder) add(msg assistant.Message) {
+	if len(msg.GetContents()) == 0 {
+		return
+	}
+	if n := len(b.msgs); n > 0 && b.msgs[n-1].GetAuthor() == msg.GetAuthor() {
+		switch last := b.msgs[n-1].(type) {
+		case *assistant.UserMessage:
+			last.Contents = append(last.Contents, msg.GetContents()...)
+		case *assistant.AssistantMessage:
+			last.Contents = append(last.Contents, msg.GetContents()...)
+		}
+		return
+	}
+	b.msgs = append(b.msgs, msg)
+}
+
+// textContents converts non-blank texts into message contents.
+func textContents(texts ...string) []assistant.MessageContent {
+	var contents []assistant.MessageContent
+	for _, t := range texts {
+		if strings.TrimSpace(t) != "" {
+			contents = append(contents, assistant.NewTextContent(t))
+		}
+	}
+	return contents
+}
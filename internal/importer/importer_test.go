@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// chatGPTExport has a regenerated reply: "a1-old" and "a1" are siblings,
+// and current_node points to the branch through "a1".
+var chatGPTExport = `[{
+  "title": "Go generics",
+  "create_time": 1700000000.5,
+  "update_time": 1700000100,
+  "conversation_id": "11111111-1111-1111-1111-111111111111",
+  "current_node": "a2",
+  "mapping": {
+    "root": {"id": "root", "parent": null, "children": ["sys"], "message": null},
+    "sys": {"id": "sys", "parent": "root", "children": ["u1"], "message": {
+      "author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]},
+      "metadata": {"is_visually_hidden_from_conversation": true}}},
+    "u1": {"id": "u1", "parent": "sys", "children": ["a1-old", "a1"], "message": {
+      "author": {"role": "user"}, "create_time": 1700000001,
+      "content": {"content_type": "multimodal_text", "parts": [{"asset_pointer": "file-service://x"}, "What are generics?"]}}},
+    "a1-old": {"id": "a1-old", "parent": "u1", "children": [], "message": {
+      "author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["old answer"]}}},
+    "a1": {"id": "a1", "parent": "u1", "children": ["u2"], "message": {
+      "author": {"role": "assistant"}, "create_time": 1700000002,
+      "content": {"content_type": "text", "parts": ["Type parameters."]},
+      "metadata": {"model_slug": "gpt-4o"}}},
+    "u2": {"id": "u2", "parent": "a1", "children": ["a2"], "message": {
+      "author": {"role": "user"}, "content": {"content_type": "text", "parts": ["Example?"]}}},
+    "a2": {"id": "a2", "parent": "u2", "children": [], "message": {
+      "author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["func F[T any]()"]}}}
+  }
+}, {
+  "title": "Empty", "create_time": 1700000000, "current_node": "root",
+  "mapping": {"root": {"id": "root", "parent": null, "children": [], "message": null}}
+}]`
+
+func TestChatGPT(t *testing.T) {
+	sessions, err := Import(FormatChatGPT, strings.NewReader(chatGPTExport))
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	sess := sessions[0]
+	require.Equal(t, "11111111-1111-1111-1111-111111111111", sess.ID)
+	require.Equal(t, "Go generics", sess.Title)
+	require.Equal(t, []string{"chatgpt"}, sess.Tags)
+	require.Equal(t, time.Unix(1700000000, 5e8).UTC(), sess.CreatedAt)
+	require.Equal(t, time.Unix(1700000100, 0).UTC(), sess.UpdatedAt)
+
+	var texts []string
+	for _, msg := range sess.Messages {
+		texts = append(texts, string(msg.GetAuthor())+": "+assistant.MessageText(msg))
+	}
+	require.Equal(t, []string{
+		"user: What are generics?",
+		"assistant: Type parameters.",
+		"user: Example?",
+		"assistant: func F[T any]()",
+	}, texts)
+	require.Equal(t, "gpt-4o", sess.Messages[1].(*assistant.AssistantMessage).Model)
+}
+
+func TestClaude(t *testing.T) {
+	export := `[{
+  "uuid": "22222222-2222-2222-2222-222222222222",
+  "name": "Review",
+  "created_at": "2025-01-02T03:04:05Z",
+  "updated_at": "2025-01-02T03:10:00Z",
+  "chat_messages": [
+    {"sender": "human", "text": "Review this", "created_at": "2025-01-02T03:04:05Z",
+     "attachments": [{"file_name": "main.go", "extracted_content": "package main"}]},
+    {"sender": "assistant", "text": "ignored", "created_at": "2025-01-02T03:04:10Z",
+     "content": [{"type": "thinking", "thinking": "hmm"}, {"type": "text", "text": "Looks good."}]},
+    {"sender": "assistant", "text": "One more thing."}
+  ]
+}]`
+	sessions, err := Import(FormatClaude, strings.NewReader(export))
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	sess := sessions[0]
+	require.Equal(t, "22222222-2222-2222-2222-222222222222", sess.ID)
+	require.Equal(t, "Review", sess.Title)
+	require.Equal(t, time.Date(2025, 1, 2, 3, 10, 0, 0, time.UTC), sess.UpdatedAt)
+	require.Len(t, sess.Messages, 2)
+
+	user := assistant.MessageText(sess.Messages[0])
+	require.Contains(t, user, "Attachment: main.go")
+	require.Contains(t, user, "```go\npackage main\n```")
+	require.True(t, strings.HasSuffix(user, "Review this"))
+	// Consecutive replies are merged.
+	require.Equal(t, "Looks good.\nOne more thing.", assistant.MessageText(sess.Messages[1]))
+}
+
+func TestOpenAIMessages(t *testing.T) {
+	for name, input := range map[string]string{
+		"array": `[
+  {"role": "system", "content": "Be brief."},
+  {"role": "user", "content": [{"type": "text", "text": "Hi"}, {"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]},
+  {"role": "assistant", "content": "Hello", "tool_calls": []},
+  {"role": "tool", "content": "ignored"}
+]`,
+		"object": `{"model": "gpt-4.1", "messages": [
+  {"role": "developer", "content": "Be brief."},
+  {"role": "user", "content": [{"type": "text", "text": "Hi"}, {"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]},
+  {"role": "assistant", "content": "Hello"}
+]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			sessions, err := Import(FormatOpenAIMessages, strings.NewReader(input))
+			require.NoError(t, err)
+			require.Len(t, sessions, 1)
+
+			sess := sessions[0]
+			require.Equal(t, []string{"openai-messages"}, sess.Tags)
+			require.Equal(t, []*assistant.TextContent{assistant.NewTextContent("Be brief.")}, sess.SystemInstruction)
+			require.Len(t, sess.Messages, 2)
+			require.Len(t, sess.Messages[0].GetContents(), 2)
+			require.Equal(t, "Hello", assistant.MessageText(sess.Messages[1]))
+		})
+	}
+}
+
+func TestImport_UnknownFormat(t *testing.T) {
+	_, err := Import("bard", strings.NewReader("[]"))
+	require.ErrorContains(t, err, "unknown import format")
+}
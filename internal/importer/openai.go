@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// openAIMessage is a message of the OpenAI Chat Completions API. Content is
+// either a string or an array of content parts.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// OpenAIMessages converts a single conversation given as an OpenAI Chat
+// Completions `messages` array, or an object holding one in its `messages`
+// field (e.g. a request body).
+//
+// System and developer messages become the session's system instruction.
+// Tool messages and tool calls are skipped.
+func OpenAIMessages(r io.Reader) ([]*assistant.Session, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read messages: %w", err)
+	}
+	var msgs []openAIMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapper struct {
+			Messages []openAIMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("decode messages: %w", err)
+		}
+		msgs = wrapper.Messages
+	} else if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("decode messages: %w", err)
+	}
+
+	sess := assistant.NewSession(nil)
+	b := new(builder)
+	for i, m := range msgs {
+		contents, err := m.contents()
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		switch m.Role {
+		case "system", "developer":
+			for _, c := range contents {
+				if tc, ok := c.(*assistant.TextContent); ok {
+					sess.SystemInstruction = append(sess.SystemInstruction, tc)
+				}
+			}
+		case "user":
+			b.add(assistant.NewUserMessage(contents...))
+		case "assistant":
+			b.add(assistant.NewAssistantMessage(contents...))
+		}
+	}
+	if len(b.msgs) == 0 {
+		return nil, errors.New("no user or assistant messages found")
+	}
+	sess.AddMessages(b.msgs...)
+	return []*assistant.Session{sess}, nil
+}
+
+func (m openAIMessage) contents() ([]assistant.MessageContent, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return textContents(s), nil
+	}
+	var parts []openAIContentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, fmt.Errorf("decode content: %w", err)
+	}
+	var contents []assistant.MessageContent
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			contents = append(contents, textContents(p.Text)...)
+		case "image_url":
+			// Inline data URLs are skipped: sessions only reference images.
+			u, err := url.Parse(p.ImageURL.URL)
+			if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				contents = append(contents, assistant.NewURLImageContent(*u))
+			}
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,253 @@
+// Package jsonrpc implements a JSON-RPC 2.0 server over a stream, such as
+// the stdio of a child process.
+//
+// Messages are framed as newline-delimited JSON: each request, response
+// and notification is a single line. Requests are handled concurrently, so
+// a long-running request can be cancelled with a "$/cancelRequest"
+// notification carrying its id, as in the Language Server Protocol.
+// Batches are not supported.
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Version is the only supported value of the "jsonrpc" member.
+const Version = "2.0"
+
+// CancelMethod is the notification that cancels the request with the given
+// id. It is handled by the server itself.
+const CancelMethod = "$/cancelRequest"
+
+// Error codes defined by JSON-RPC 2.0, plus RequestCancelled from the
+// Language Server Protocol.
+const (
+	CodeParseError       = -32700
+	CodeInvalidRequest   = -32600
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeRequestCancelled = -32800
+)
+
+// Error is a JSON-RPC error object. Handlers may return it to control the
+// code of the error response; other errors become internal errors.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (%d)", e.Message, e.Code)
+}
+
+// Errorf returns an *Error with the given code and formatted message.
+func Errorf(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// message is any incoming or outgoing JSON-RPC message.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// isNotification reports whether m expects no response.
+func (m *message) isNotification() bool { return len(m.ID) == 0 }
+
+// Handler handles a request or notification. The returned result is
+// marshaled as the "result" of the response; it is discarded for
+// notifications.
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Server dispatches incoming messages to the registered handlers.
+type Server struct {
+	methods map[string]Handler
+
+	wmu sync.Mutex // guards enc
+	enc *json.Encoder
+
+	mu      sync.Mutex
+	pending map[string]context.CancelFunc // by raw request id
+}
+
+// NewServer returns a server without methods.
+func NewServer() *Server {
+	return &Server{
+		methods: make(map[string]Handler),
+		pending: make(map[string]context.CancelFunc),
+	}
+}
+
+// Handle registers h for method. It must not be called while serving.
+func (s *Server) Handle(method string, h Handler) {
+	s.methods[method] = h
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the id of the request being handled by ctx, or nil for
+// notifications.
+func RequestID(ctx context.Context) json.RawMessage {
+	id, _ := ctx.Value(requestIDKey{}).(json.RawMessage)
+	return id
+}
+
+// Bind unmarshals params into v, reporting failures as invalid params.
+// Missing params leave v untouched.
+func Bind(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return Errorf(CodeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
+
+// Serve reads messages from r and writes responses and notifications to w
+// until r is exhausted. Requests are handled under contexts derived from
+// ctx, and in-flight requests are awaited before Serve returns.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.reply(nil, nil, Errorf(CodeParseError, "parse error: %v", err))
+			continue
+		}
+		if msg.JSONRPC != Version || msg.Method == "" {
+			if !msg.isNotification() {
+				s.reply(msg.ID, nil, Errorf(CodeInvalidRequest, "invalid request"))
+			}
+			continue
+		}
+		if msg.Method == CancelMethod {
+			s.cancel(msg.Params)
+			continue
+		}
+
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		if !msg.isNotification() {
+			reqCtx = context.WithValue(reqCtx, requestIDKey{}, msg.ID)
+			s.mu.Lock()
+			s.pending[string(msg.ID)] = reqCancel
+			s.mu.Unlock()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reqCancel()
+			s.handle(reqCtx, &msg)
+		}()
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("read message: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) {
+	h, ok := s.methods[msg.Method]
+	if !ok {
+		if !msg.isNotification() {
+			s.reply(msg.ID, nil, Errorf(CodeMethodNotFound, "method not found: %s", msg.Method))
+		}
+		return
+	}
+	result, err := h(ctx, msg.Params)
+	if msg.isNotification() {
+		return
+	}
+	s.mu.Lock()
+	delete(s.pending, string(msg.ID))
+	s.mu.Unlock()
+
+	if err != nil {
+		var rpcErr *Error
+		switch {
+		case errors.As(err, &rpcErr):
+		case ctx.Err() != nil:
+			rpcErr = Errorf(CodeRequestCancelled, "request cancelled")
+		default:
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		s.reply(msg.ID, nil, rpcErr)
+		return
+	}
+	s.reply(msg.ID, result, nil)
+}
+
+// cancel cancels the pending request whose id is in params. Unknown ids
+// are ignored, as the request may have completed already.
+func (s *Server) cancel(params json.RawMessage) {
+	var p struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel, ok := s.pending[string(p.ID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *Error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	msg := message{JSONRPC: Version, ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = Errorf(CodeInternalError, "marshal result: %v", err)
+		} else {
+			msg.Result = b
+		}
+	}
+	s.write(&msg)
+}
+
+// Notify sends a notification to the client.
+func (s *Server) Notify(method string, params any) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal params: %w", err)
+	}
+	return s.write(&message{JSONRPC: Version, Method: method, Params: b})
+}
+
+func (s *Server) write(msg *message) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.enc == nil {
+		return errors.New("jsonrpc: not serving")
+	}
+	return s.enc.Encode(msg)
+}
@@ -0,0 +1,125 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// client drives a server over pipes.
+type client struct {
+	in   *io.PipeWriter
+	out  *bufio.Scanner
+	done chan error
+}
+
+func startServer(t *testing.T, srv *Server) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{in: inW, out: bufio.NewScanner(outR), done: make(chan error, 1)}
+	go func() {
+		c.done <- srv.Serve(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *client) send(t *testing.T, line string) {
+	t.Helper()
+	_, err := io.WriteString(c.in, line+"\n")
+	require.NoError(t, err)
+}
+
+func (c *client) recv(t *testing.T) map[string]any {
+	t.Helper()
+	require.True(t, c.out.Scan(), "no message")
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(c.out.Bytes(), &m))
+	return m
+}
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("add", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var p []int
+		if err := Bind(params, &p); err != nil {
+			return nil, err
+		}
+		return p[0] + p[1], nil
+	})
+	srv.Handle("fail", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("boom")
+	})
+	srv.Handle("echo", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, srv.Notify("echoed", map[string]any{"id": RequestID(ctx), "params": params})
+	})
+	c := startServer(t, srv)
+
+	c.send(t, `{"jsonrpc": "2.0", "id": 1, "method": "add", "params": [1, 2]}`)
+	require.Equal(t, map[string]any{"jsonrpc": "2.0", "id": 1.0, "result": 3.0}, c.recv(t))
+
+	c.send(t, `{"jsonrpc": "2.0", "id": "a", "method": "add", "params": {"x": 1}}`)
+	require.Equal(t, float64(CodeInvalidParams), c.recv(t)["error"].(map[string]any)["code"])
+
+	c.send(t, `{"jsonrpc": "2.0", "id": 2, "method": "fail"}`)
+	require.Equal(t, map[string]any{"code": float64(CodeInternalError), "message": "boom"}, c.recv(t)["error"])
+
+	c.send(t, `{"jsonrpc": "2.0", "id": 3, "method": "missing"}`)
+	require.Equal(t, float64(CodeMethodNotFound), c.recv(t)["error"].(map[string]any)["code"])
+
+	c.send(t, `not json`)
+	resp := c.recv(t)
+	require.Nil(t, resp["id"])
+	require.Equal(t, float64(CodeParseError), resp["error"].(map[string]any)["code"])
+
+	c.send(t, `{"id": 4, "method": "add"}`)
+	require.Equal(t, float64(CodeInvalidRequest), c.recv(t)["error"].(map[string]any)["code"])
+
+	// Notifications get no response.
+	c.send(t, `{"jsonrpc": "2.0", "method": "fail"}`)
+	c.send(t, `{"jsonrpc": "2.0", "id": 5, "method": "echo", "params": "hi"}`)
+	require.Equal(t, map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "echoed",
+		"params":  map[string]any{"id": 5.0, "params": "hi"},
+	}, c.recv(t))
+	require.Equal(t, map[string]any{"jsonrpc": "2.0", "id": 5.0, "result": nil}, c.recv(t))
+
+	c.in.Close()
+	require.NoError(t, <-c.done)
+}
+
+func TestServer_Cancel(t *testing.T) {
+	srv := NewServer()
+	started := make(chan struct{})
+	srv.Handle("wait", func(ctx context.Context, params json.RawMessage) (any, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+			return "too late", nil
+		}
+	})
+	c := startServer(t, srv)
+
+	c.send(t, `{"jsonrpc": "2.0", "id": "w", "method": "wait"}`)
+	<-started
+	c.send(t, `{"jsonrpc": "2.0", "method": "$/cancelRequest", "params": {"id": "w"}}`)
+	resp := c.recv(t)
+	require.Equal(t, "w", resp["id"])
+	require.Equal(t, float64(CodeRequestCancelled), resp["error"].(map[string]any)["code"])
+
+	// Cancelling a finished request is a no-op.
+	c.send(t, `{"jsonrpc": "2.0", "method": "$/cancelRequest", "params": {"id": "w"}}`)
+	c.in.Close()
+	require.NoError(t, <-c.done)
+}
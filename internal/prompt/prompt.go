@@ -0,0 +1,192 @@
+// Package prompt renders prompt templates, such as the system messages of
+// personas.
+//
+// Templates use the syntax of [text/template] with the fields and methods of
+// [Data] and these functions:
+//
+//	{{env "NAME"}}        the environment variable NAME
+//	{{include "file.md"}} the rendered template in file.md
+//
+// Templates of untrusted projects are rendered in a sandbox, see
+// [Options.Sandbox].
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxIncludeDepth bounds nested includes, which would otherwise recurse
+// forever on a file that includes itself.
+const maxIncludeDepth = 10
+
+// Options configures rendering.
+type Options struct {
+	// Dir is the directory relative include paths are resolved against.
+	// Empty means the working directory.
+	Dir string
+
+	// Vars are user variables, available as {{.Vars.NAME}}.
+	Vars map[string]string
+
+	// Now is the time of Date and Time. Zero means the current time.
+	Now time.Time
+
+	// Sandbox, if not empty, is the only directory files may be included
+	// from, and disables env. It is set for templates of untrusted projects,
+	// which must not send local files or secrets to a provider.
+	Sandbox string
+}
+
+// Data is the data of a template.
+type Data struct {
+	Date string // e.g. "2025-01-31"
+	Time string // e.g. "15:04"
+	OS   string // runtime.GOOS, e.g. "darwin"
+	CWD  string // the working directory
+
+	// Vars holds the variables passed with --var. Referring to an undefined
+	// variable is an error; use {{index .Vars "NAME"}} for optional ones.
+	Vars map[string]string
+}
+
+// GitBranch returns the branch checked out in the working directory, or an
+// empty string outside a git repository.
+func (d Data) GitBranch() string {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = d.CWD
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Render renders the template text.
+func Render(text string, opts Options) (string, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("get working directory: %w", err)
+	}
+	vars := opts.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	r := &renderer{
+		dir:     opts.Dir,
+		sandbox: opts.Sandbox,
+		data: Data{
+			Date: now.Format(time.DateOnly),
+			Time: now.Format("15:04"),
+			OS:   runtime.GOOS,
+			CWD:  cwd,
+			Vars: vars,
+		},
+	}
+	return r.render("prompt", text, 0)
+}
+
+type renderer struct {
+	dir     string
+	sandbox string
+	data    Data
+}
+
+func (r *renderer) render(name, text string, depth int) (string, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"env": func(name string) (string, error) {
+				if r.sandbox != "" {
+					return "", fmt.Errorf("env %s: not available in templates of untrusted projects", name)
+				}
+				return os.Getenv(name), nil
+			},
+			"include": func(path string) (string, error) {
+				return r.include(path, depth+1)
+			},
+		}).
+		Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r.data); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (r *renderer) include(path string, depth int) (string, error) {
+	if depth > maxIncludeDepth {
+		return "", errors.New("includes nested too deeply")
+	}
+	if !filepath.IsAbs(path) && r.dir != "" {
+		path = filepath.Join(r.dir, path)
+	}
+	b, err := ReadFile(r.sandbox, path)
+	if err != nil {
+		return "", fmt.Errorf("include: %w", err)
+	}
+	return r.render(filepath.Base(path), string(b), depth)
+}
+
+// ReadFile reads the file at path, which must be inside of sandbox unless
+// that is empty. See [Options.Sandbox].
+func ReadFile(sandbox, path string) ([]byte, error) {
+	if sandbox != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if !InDir(sandbox, abs) {
+			return nil, fmt.Errorf("%s is outside of %s", path, sandbox)
+		}
+	}
+	return os.ReadFile(path)
+}
+
+// InDir reports whether path is dir or below it, once symbolic links are
+// followed. Both should be absolute.
+func InDir(dir, path string) bool {
+	rel, err := filepath.Rel(realPath(dir), realPath(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath returns path with symbolic links followed as far as it exists.
+func realPath(path string) string {
+	path = filepath.Clean(path)
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		return p
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(realPath(parent), filepath.Base(path))
+}
+
+// ParseVars parses variables given as "NAME=VALUE".
+func ParseVars(kvs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid variable %q: want NAME=VALUE", kv)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
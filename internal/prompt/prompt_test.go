@@ -0,0 +1,108 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "style.md"), []byte(`Write {{.Vars.lang}}. {{include "sign.md"}}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sign.md"), []byte(`({{.OS}})`), 0644))
+	t.Setenv("AICO_TEST_NAME", "Ada")
+
+	got, err := Render(`{{.Date}} {{env "AICO_TEST_NAME"}}: {{include "style.md"}}{{index .Vars "missing"}}`, Options{
+		Dir:  dir,
+		Vars: map[string]string{"lang": "Go"},
+		Now:  time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "2025-01-31 Ada: Write Go. ("+runtime.GOOS+")", got)
+}
+
+func TestRender_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "loop.md"), []byte(`{{include "loop.md"}}`), 0644))
+
+	for _, text := range []string{
+		`{{.Vars.undefined}}`,
+		`{{.Nope}}`,
+		`{{include "missing.md"}}`,
+		`{{include "loop.md"}}`,
+		`{{`,
+	} {
+		_, err := Render(text, Options{Dir: dir})
+		require.Error(t, err, text)
+	}
+}
+
+func TestRender_Sandbox(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "prompts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules.md"), []byte(`rules`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.md"), []byte(`secret`), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret.md"), filepath.Join(dir, "link.md")))
+	opts := Options{Dir: filepath.Join(dir, "prompts"), Sandbox: dir}
+
+	got, err := Render(`{{include "../rules.md"}}`, opts)
+	require.NoError(t, err)
+	require.Equal(t, "rules", got)
+
+	for _, text := range []string{
+		`{{env "HOME"}}`,
+		`{{include "../../secret.md"}}`,
+		`{{include "../link.md"}}`,
+		`{{include "` + filepath.Join(root, "secret.md") + `"}}`,
+	} {
+		_, err := Render(text, opts)
+		require.Error(t, err, text)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "project")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "in.md"), []byte(`in`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.md"), []byte(`secret`), 0644))
+	t.Chdir(dir)
+
+	b, err := ReadFile(dir, "in.md")
+	require.NoError(t, err)
+	require.Equal(t, "in", string(b))
+	_, err = ReadFile(dir, "../secret.md")
+	require.ErrorContains(t, err, "is outside of")
+	_, err = ReadFile(dir, filepath.Join(root, "secret.md"))
+	require.ErrorContains(t, err, "is outside of")
+
+	// Without a sandbox, any file may be read.
+	b, err = ReadFile("", "../secret.md")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(b))
+}
+
+func TestInDir(t *testing.T) {
+	require.True(t, InDir("/a/b", "/a/b"))
+	require.True(t, InDir("/a/b", "/a/b/c/../d"))
+	require.True(t, InDir("/a/b", "/a/b/..c"))
+	require.False(t, InDir("/a/b", "/a"))
+	require.False(t, InDir("/a/b", "/a/bc"))
+	require.False(t, InDir("/a/b", "/a/b/../c"))
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"lang=Go", "expr=a=b", "empty="})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"lang": "Go", "expr": "a=b", "empty": ""}, vars)
+
+	_, err = ParseVars([]string{"novalue"})
+	require.Error(t, err)
+	_, err = ParseVars([]string{"=x"})
+	require.Error(t, err)
+}
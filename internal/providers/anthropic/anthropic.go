@@ -1,8 +1,10 @@
 package anthropic
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"strings"
 
 	anthropic "github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -106,8 +108,11 @@ func selectModel(modelName string) (assistant.GenerativeModel, bool) {
 }
 
 // NewGenerativeModel creates a new instance of a generative model
-func NewGenerativeModel(modelName, apiKey string) (assistant.GenerativeModel, error) {
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+//
+// The opts are applied after the API key, e.g. option.WithBaseURL to use
+// another endpoint, or option.WithHTTPClient.
+func NewGenerativeModel(modelName, apiKey string, opts ...option.RequestOption) (assistant.GenerativeModel, error) {
+	client := anthropic.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...)
 	switch modelName {
 	case "claude-fable-5":
 		return NewClaudeFable5(client), nil
@@ -125,17 +130,137 @@ func NewGenerativeModel(modelName, apiKey string) (assistant.GenerativeModel, er
 	return nil, fmt.Errorf("unsupported model name: %s", modelName)
 }
 
-func buildRequestBody(ctx context.Context, model anthropic.Model, systemInstruction []*assistant.TextContent, msgs []assistant.Message) (*anthropic.MessageNewParams, error) {
+func buildRequestBody(ctx context.Context, model anthropic.Model, systemInstruction []*assistant.TextContent, msgs []assistant.Message, cache assistant.CacheMode, params assistant.GenerationParams) (*anthropic.MessageNewParams, error) {
 	messages, err := messageParams(ctx, msgs...)
 	if err != nil {
 		return nil, fmt.Errorf("build message params: %w", err)
 	}
-	return &anthropic.MessageNewParams{
-		MaxTokens: anthropic.F(int64(defaultMaxTokens)),
+	system := systemMessageParam(systemInstruction)
+	placeCacheBreakpoints(system, messages, cache)
+	body := &anthropic.MessageNewParams{
+		MaxTokens: anthropic.F(int64(cmp.Or(params.MaxTokens, defaultMaxTokens))),
 		Model:     anthropic.F(model),
 		Messages:  anthropic.F(messages),
-		System:    anthropic.F(systemMessageParam(systemInstruction)),
-	}, nil
+		System:    anthropic.F(system),
+	}
+	switch {
+	case params.Temperature == nil:
+	case params.Effort != "":
+		// Thinking does not allow a temperature other than the default.
+		logging.LoggerFrom(ctx).Warn("ignore temperature with effort", "temperature", *params.Temperature, "effort", params.Effort)
+	default:
+		body.Temperature = anthropic.F(*params.Temperature)
+	}
+	switch {
+	case params.Effort == "":
+	case hasEffortControl(model):
+		// The SDK does not know adaptive thinking yet; the effort itself
+		// is sent by effortOptions.
+		body.Thinking = anthropic.Raw[anthropic.ThinkingConfigParamUnion](map[string]string{"type": "adaptive"})
+	default:
+		budget := thinkingBudgets[params.Effort]
+		if params.MaxTokens == 0 {
+			// The budget counts towards max_tokens; leave room for the answer.
+			body.MaxTokens = anthropic.F(int64(budget + defaultMaxTokens))
+		}
+		body.Thinking = anthropic.F[anthropic.ThinkingConfigParamUnion](anthropic.ThinkingConfigEnabledParam{
+			Type:         anthropic.F(anthropic.ThinkingConfigEnabledTypeEnabled),
+			BudgetTokens: anthropic.F(int64(budget)),
+		})
+	}
+	return body, nil
+}
+
+// thinkingBudgets maps efforts to extended thinking budgets in tokens, for
+// models without effort control.
+var thinkingBudgets = map[assistant.Effort]int{
+	assistant.EffortLow:    2_048,
+	assistant.EffortMedium: 8_192,
+	assistant.EffortHigh:   32_000,
+}
+
+// hasEffortControl reports whether model takes an effort instead of a
+// thinking budget.
+func hasEffortControl(model anthropic.Model) bool {
+	switch model {
+	case ModelNameClaudeFable5, ModelNameClaudeOpus4_8, ModelNameClaudeSonnet5:
+		return true
+	}
+	return false
+}
+
+// effortOptions returns the request options that set the effort of models
+// with effort control.
+func effortOptions(model anthropic.Model, params assistant.GenerationParams) []option.RequestOption {
+	if params.Effort == "" || !hasEffortControl(model) {
+		return nil
+	}
+	return []option.RequestOption{option.WithJSONSet("output_config", map[string]string{"effort": string(params.Effort)})}
+}
+
+// deltaContent returns the text or thinking of a streamed delta, or nil if it
+// has neither.
+func deltaContent(delta anthropic.ContentBlockDeltaEventDelta) assistant.MessageContent {
+	switch {
+	case delta.Type == anthropic.ContentBlockDeltaEventDeltaTypeThinkingDelta && delta.Thinking != "":
+		return &assistant.ThinkingContent{Text: delta.Thinking}
+	case delta.Text != "":
+		return assistant.NewTextContent(delta.Text)
+	}
+	return nil
+}
+
+// responseText returns the text of res, skipping thinking blocks.
+func responseText(res *anthropic.Message) string {
+	var b strings.Builder
+	for _, c := range res.Content {
+		if c.Type == anthropic.ContentBlockTypeText {
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String()
+}
+
+// placeCacheBreakpoints marks the end of the system instruction and the last
+// stable turn (the one before the new prompt) as cache breakpoints, so that
+// follow-up requests in a conversation read the shared prefix from the cache.
+func placeCacheBreakpoints(system []anthropic.TextBlockParam, messages []anthropic.MessageParam, mode assistant.CacheMode) {
+	if mode == assistant.CacheOff || mode == "" {
+		return
+	}
+	if n := len(system); n > 0 {
+		setCacheControl(&system[n-1], mode)
+	}
+	if len(messages) < 2 {
+		return
+	}
+	blocks := messages[len(messages)-2].Content.Value
+	if n := len(blocks); n > 0 {
+		if text, ok := blocks[n-1].(anthropic.TextBlockParam); ok {
+			setCacheControl(&text, mode)
+			blocks[n-1] = text
+		}
+	}
+}
+
+func setCacheControl(block *anthropic.TextBlockParam, mode assistant.CacheMode) {
+	if mode == assistant.Cache1h {
+		// The SDK does not know the ttl field yet.
+		block.CacheControl = anthropic.Raw[anthropic.CacheControlEphemeralParam](map[string]string{"type": "ephemeral", "ttl": "1h"})
+		return
+	}
+	block.CacheControl = anthropic.F(anthropic.CacheControlEphemeralParam{
+		Type: anthropic.F(anthropic.CacheControlEphemeralTypeEphemeral),
+	})
+}
+
+// withCacheTTL adjusts p for the cache mode: writes to the 1 hour cache are
+// billed at twice the input price instead of 1.25 times.
+func withCacheTTL(mode assistant.CacheMode, p assistant.Pricing) assistant.Pricing {
+	if mode == assistant.Cache1h {
+		p.CacheWrite = 2 * p.Input
+	}
+	return p
 }
 
 func messageParamFrom(ctx context.Context, src assistant.Message) (*anthropic.MessageParam, error) {
@@ -0,0 +1,171 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/httpfixture"
+)
+
+func TestBuildRequestBody_CacheBreakpoints(t *testing.T) {
+	system := []*assistant.TextContent{assistant.NewTextContent("You are helpful.")}
+	msgs := []assistant.Message{
+		assistant.NewUserMessage(assistant.NewTextContent("first")),
+		assistant.NewAssistantMessage(assistant.NewTextContent("reply")),
+		assistant.NewUserMessage(assistant.NewTextContent("second")),
+	}
+
+	// cacheControls returns the cache_control of the system block and of the
+	// last block of each message, in order.
+	cacheControls := func(t *testing.T, mode assistant.CacheMode) []any {
+		body, err := buildRequestBody(context.Background(), anthropic.Model("test"), system, msgs, mode, assistant.GenerationParams{})
+		require.NoError(t, err)
+		b, err := body.MarshalJSON()
+		require.NoError(t, err)
+		var req struct {
+			System []struct {
+				CacheControl any `json:"cache_control"`
+			} `json:"system"`
+			Messages []struct {
+				Content []struct {
+					CacheControl any `json:"cache_control"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal(b, &req))
+		got := []any{req.System[0].CacheControl}
+		for _, m := range req.Messages {
+			got = append(got, m.Content[len(m.Content)-1].CacheControl)
+		}
+		return got
+	}
+
+	ephemeral := map[string]any{"type": "ephemeral"}
+	require.Equal(t, []any{ephemeral, nil, ephemeral, nil}, cacheControls(t, assistant.CacheAuto))
+
+	hour := map[string]any{"type": "ephemeral", "ttl": "1h"}
+	require.Equal(t, []any{hour, nil, hour, nil}, cacheControls(t, assistant.Cache1h))
+
+	require.Equal(t, []any{nil, nil, nil, nil}, cacheControls(t, assistant.CacheOff))
+}
+
+func TestBuildRequestBody_Params(t *testing.T) {
+	msgs := []assistant.Message{assistant.NewUserMessage(assistant.NewTextContent("hi"))}
+
+	// request returns the request body for the model and params as JSON.
+	request := func(t *testing.T, model string, params assistant.GenerationParams) map[string]any {
+		body, err := buildRequestBody(context.Background(), anthropic.Model(model), nil, msgs, assistant.CacheOff, params)
+		require.NoError(t, err)
+		b, err := body.MarshalJSON()
+		require.NoError(t, err)
+		var req map[string]any
+		require.NoError(t, json.Unmarshal(b, &req))
+		return req
+	}
+
+	temp := 0.2
+	req := request(t, ModelNameClaudeHaiku4_5, assistant.GenerationParams{Temperature: &temp, MaxTokens: 100})
+	require.EqualValues(t, 100, req["max_tokens"])
+	require.EqualValues(t, 0.2, req["temperature"])
+	require.NotContains(t, req, "thinking")
+
+	req = request(t, ModelNameClaudeHaiku4_5, assistant.GenerationParams{Effort: assistant.EffortHigh})
+	require.Equal(t, map[string]any{"type": "enabled", "budget_tokens": float64(32_000)}, req["thinking"])
+	require.Greater(t, req["max_tokens"], float64(32_000))
+	require.Empty(t, effortOptions(ModelNameClaudeHaiku4_5, assistant.GenerationParams{Effort: assistant.EffortHigh}))
+
+	req = request(t, ModelNameClaudeOpus4_8, assistant.GenerationParams{Effort: assistant.EffortLow})
+	require.Equal(t, map[string]any{"type": "adaptive"}, req["thinking"])
+	require.Len(t, effortOptions(ModelNameClaudeOpus4_8, assistant.GenerationParams{Effort: assistant.EffortLow}), 1)
+
+	// Thinking rejects a temperature, so it is left out.
+	req = request(t, ModelNameClaudeHaiku4_5, assistant.GenerationParams{Temperature: &temp, Effort: assistant.EffortLow})
+	require.Contains(t, req, "thinking")
+	require.NotContains(t, req, "temperature")
+	req = request(t, ModelNameClaudeOpus4_8, assistant.GenerationParams{Temperature: &temp, Effort: assistant.EffortLow})
+	require.NotContains(t, req, "temperature")
+}
+
+func newTestClaudeHaiku4_5(t *testing.T, fixture string) *ClaudeHaiku4_5 {
+	httpClient, tr := httpfixture.NewTestClient(t, fixture)
+	apiKey := "test-key"
+	if tr.Recording() {
+		apiKey = os.Getenv("AICO_ANTHROPIC_API_KEY")
+	}
+	client := anthropic.NewClient(
+		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(httpClient),
+		option.WithMaxRetries(0),
+	)
+	m := NewClaudeHaiku4_5(client)
+	m.SetSystemInstruction(assistant.NewTextContent("You are a terse assistant."))
+	return m
+}
+
+func TestClaudeHaiku4_5_GenerateContent(t *testing.T) {
+	m := newTestClaudeHaiku4_5(t, "claude-haiku-4-5")
+	resp, err := m.GenerateContent(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")))
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", resp.Content.(*assistant.TextContent).Text)
+	require.Equal(t, &assistant.Usage{InputTokens: 19, OutputTokens: 5}, resp.Usage)
+}
+
+func TestClaudeHaiku4_5_GenerateContentStream(t *testing.T) {
+	m := newTestClaudeHaiku4_5(t, "claude-haiku-4-5_stream")
+	m.SetPromptCache(assistant.CacheAuto)
+	seq, err := m.GenerateContentStream(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")),
+		assistant.NewAssistantMessage(assistant.NewTextContent("Hello!")),
+		assistant.NewUserMessage(assistant.NewTextContent("Once more, with the world.")),
+	)
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var usage *assistant.Usage
+	for resp, err := range seq {
+		require.NoError(t, err)
+		if resp.Usage != nil {
+			usage = resp.Usage
+			continue
+		}
+		text.WriteString(resp.Content.(*assistant.TextContent).Text)
+	}
+	require.Equal(t, "Hello, world!", text.String())
+	// input_tokens excludes cached tokens; Usage counts the whole prompt.
+	require.Equal(t, &assistant.Usage{
+		InputTokens:       1416,
+		OutputTokens:      7,
+		CachedInputTokens: 1100,
+		CacheWriteTokens:  300,
+	}, usage)
+}
+
+func TestClaudeHaiku4_5_GenerateContentStream_Thinking(t *testing.T) {
+	m := newTestClaudeHaiku4_5(t, "claude-haiku-4-5_thinking")
+	m.SetGenerationParams(assistant.GenerationParams{Effort: assistant.EffortLow})
+	seq, err := m.GenerateContentStream(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("What is 17 * 3?")))
+	require.NoError(t, err)
+
+	var text, thinking strings.Builder
+	for resp, err := range seq {
+		require.NoError(t, err)
+		switch c := resp.Content.(type) {
+		case *assistant.TextContent:
+			text.WriteString(c.Text)
+		case *assistant.ThinkingContent:
+			thinking.WriteString(c.Text)
+		}
+	}
+	require.Equal(t, "17 * 3 = 50 + 1 = 51.", thinking.String())
+	require.Equal(t, "51", text.String())
+}
@@ -17,11 +17,17 @@ const ModelNameClaudeFable5 = "claude-fable-5"
 type ClaudeFable5 struct {
 	systemInstruction []*assistant.TextContent
 	client            *anthropic.Client
+	cache             assistant.CacheMode
+	params            assistant.GenerationParams
 
 	opts []anthropicopt.RequestOption
 }
 
-var _ assistant.GenerativeModel = (*ClaudeFable5)(nil)
+var (
+	_ assistant.GenerativeModel = (*ClaudeFable5)(nil)
+	_ assistant.PromptCacher    = (*ClaudeFable5)(nil)
+	_ assistant.ParamSetter     = (*ClaudeFable5)(nil)
+)
 
 func NewClaudeFable5(client *anthropic.Client) *ClaudeFable5 { return &ClaudeFable5{client: client} }
 func (m *ClaudeFable5) Provider() string                     { return ProviderName }
@@ -33,10 +39,24 @@ Pricing: $10/MTok input, $50/MTok output.
 Supports 1M context window and 128K max output.`
 }
 
+func (m *ClaudeFable5) ContextWindow() int   { return 1_000_000 }
+func (m *ClaudeFable5) MaxOutputTokens() int { return 128_000 }
+func (m *ClaudeFable5) Pricing() assistant.Pricing {
+	return withCacheTTL(m.cache, assistant.Pricing{Input: 10, Output: 50, CacheRead: 1, CacheWrite: 12.5})
+}
+
 func (m *ClaudeFable5) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *ClaudeFable5) SetPromptCache(mode assistant.CacheMode) {
+	m.cache = mode
+}
+
+func (m *ClaudeFable5) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *ClaudeFable5) GenerateContent(
 	ctx context.Context,
 	msgs ...assistant.Message,
@@ -48,11 +68,13 @@ func (m *ClaudeFable5) GenerateContent(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	res, err := m.client.Messages.New(ctx, *body, m.opts...)
+	res, err := m.client.Messages.New(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic New Message: %w", err)
 	}
@@ -69,7 +91,7 @@ func (m *ClaudeFable5) GenerateContent(
 		logger.Warn("anthropic response has more than one content", "content", fmt.Sprintf("%+v", res.Content))
 	}
 	return &assistant.GenerateContentResponse{
-		Content: assistant.NewTextContent(res.Content[0].Text),
+		Content: assistant.NewTextContent(responseText(res)),
 		Usage:   toUsage(res.Usage),
 	}, nil
 }
@@ -85,11 +107,13 @@ func (m *ClaudeFable5) GenerateContentStream(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	stream := m.client.Messages.NewStreaming(ctx, *body, m.opts...)
+	stream := m.client.Messages.NewStreaming(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 
 	// return converter iter
 	message := anthropic.Message{}
@@ -100,11 +124,8 @@ func (m *ClaudeFable5) GenerateContentStream(
 
 			switch delta := event.Delta.(type) {
 			case anthropic.ContentBlockDeltaEventDelta:
-				if delta.Text != "" {
-					resp := &assistant.GenerateContentResponse{
-						Content: assistant.NewTextContent(delta.Text),
-					}
-					if !yield(resp, nil) {
+				if content := deltaContent(delta); content != nil {
+					if !yield(&assistant.GenerateContentResponse{Content: content}, nil) {
 						return
 					}
 				}
@@ -17,11 +17,17 @@ const ModelNameClaudeHaiku4_5 = "claude-haiku-4-5"
 type ClaudeHaiku4_5 struct {
 	systemInstruction []*assistant.TextContent
 	client            *anthropic.Client
+	cache             assistant.CacheMode
+	params            assistant.GenerationParams
 
 	opts []anthropicopt.RequestOption
 }
 
-var _ assistant.GenerativeModel = (*ClaudeHaiku4_5)(nil)
+var (
+	_ assistant.GenerativeModel = (*ClaudeHaiku4_5)(nil)
+	_ assistant.PromptCacher    = (*ClaudeHaiku4_5)(nil)
+	_ assistant.ParamSetter     = (*ClaudeHaiku4_5)(nil)
+)
 
 func NewClaudeHaiku4_5(client *anthropic.Client) *ClaudeHaiku4_5 {
 	return &ClaudeHaiku4_5{client: client}
@@ -37,10 +43,24 @@ cost-sensitive deployments needing strong reasoning, and sub-agent tasks.
 Supports 200K context window.`
 }
 
+func (m *ClaudeHaiku4_5) ContextWindow() int   { return 200_000 }
+func (m *ClaudeHaiku4_5) MaxOutputTokens() int { return 64_000 }
+func (m *ClaudeHaiku4_5) Pricing() assistant.Pricing {
+	return withCacheTTL(m.cache, assistant.Pricing{Input: 1, Output: 5, CacheRead: 0.1, CacheWrite: 1.25})
+}
+
 func (m *ClaudeHaiku4_5) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *ClaudeHaiku4_5) SetPromptCache(mode assistant.CacheMode) {
+	m.cache = mode
+}
+
+func (m *ClaudeHaiku4_5) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *ClaudeHaiku4_5) GenerateContent(
 	ctx context.Context,
 	msgs ...assistant.Message,
@@ -52,11 +72,13 @@ func (m *ClaudeHaiku4_5) GenerateContent(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	res, err := m.client.Messages.New(ctx, *body, m.opts...)
+	res, err := m.client.Messages.New(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic New Message: %w", err)
 	}
@@ -70,7 +92,7 @@ func (m *ClaudeHaiku4_5) GenerateContent(
 		logger.Warn("anthropic response has more than one content", "content", fmt.Sprintf("%+v", res.Content))
 	}
 	return &assistant.GenerateContentResponse{
-		Content: assistant.NewTextContent(res.Content[0].Text),
+		Content: assistant.NewTextContent(responseText(res)),
 		Usage:   toUsage(res.Usage),
 	}, nil
 }
@@ -81,13 +103,15 @@ func (m *ClaudeHaiku4_5) GenerateContentStream(ctx context.Context, msgs ...assi
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
 
 	// Start streaming response from Anthropic API
-	stream := m.client.Messages.NewStreaming(ctx, *body, m.opts...)
+	stream := m.client.Messages.NewStreaming(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 
 	// return converter iter
 	message := anthropic.Message{}
@@ -98,11 +122,8 @@ func (m *ClaudeHaiku4_5) GenerateContentStream(ctx context.Context, msgs ...assi
 
 			switch delta := event.Delta.(type) {
 			case anthropic.ContentBlockDeltaEventDelta:
-				if delta.Text != "" {
-					resp := &assistant.GenerateContentResponse{
-						Content: assistant.NewTextContent(delta.Text),
-					}
-					if !yield(resp, nil) {
+				if content := deltaContent(delta); content != nil {
+					if !yield(&assistant.GenerateContentResponse{Content: content}, nil) {
 						return
 					}
 				}
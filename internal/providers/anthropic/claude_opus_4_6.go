@@ -17,11 +17,17 @@ const ModelNameClaudeOpus4_6 = "claude-opus-4-6"
 type ClaudeOpus4_6 struct {
 	systemInstruction []*assistant.TextContent
 	client            *anthropic.Client
+	cache             assistant.CacheMode
+	params            assistant.GenerationParams
 
 	opts []anthropicopt.RequestOption
 }
 
-var _ assistant.GenerativeModel = (*ClaudeOpus4_6)(nil)
+var (
+	_ assistant.GenerativeModel = (*ClaudeOpus4_6)(nil)
+	_ assistant.PromptCacher    = (*ClaudeOpus4_6)(nil)
+	_ assistant.ParamSetter     = (*ClaudeOpus4_6)(nil)
+)
 
 func NewClaudeOpus4_6(client *anthropic.Client) *ClaudeOpus4_6 { return &ClaudeOpus4_6{client: client} }
 func (m *ClaudeOpus4_6) Provider() string                      { return ProviderName }
@@ -34,10 +40,24 @@ Supports extended thinking and adaptive thinking. Pricing: $5/MTok input, $25/MT
 Supports 200K context window (1M with beta header) and 128K max output.`
 }
 
+func (m *ClaudeOpus4_6) ContextWindow() int   { return 200_000 }
+func (m *ClaudeOpus4_6) MaxOutputTokens() int { return 128_000 }
+func (m *ClaudeOpus4_6) Pricing() assistant.Pricing {
+	return withCacheTTL(m.cache, assistant.Pricing{Input: 5, Output: 25, CacheRead: 0.5, CacheWrite: 6.25})
+}
+
 func (m *ClaudeOpus4_6) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *ClaudeOpus4_6) SetPromptCache(mode assistant.CacheMode) {
+	m.cache = mode
+}
+
+func (m *ClaudeOpus4_6) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *ClaudeOpus4_6) GenerateContent(
 	ctx context.Context,
 	msgs ...assistant.Message,
@@ -49,11 +69,13 @@ func (m *ClaudeOpus4_6) GenerateContent(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	res, err := m.client.Messages.New(ctx, *body, m.opts...)
+	res, err := m.client.Messages.New(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic New Message: %w", err)
 	}
@@ -70,7 +92,7 @@ func (m *ClaudeOpus4_6) GenerateContent(
 		logger.Warn("anthropic response has more than one content", "content", fmt.Sprintf("%+v", res.Content))
 	}
 	return &assistant.GenerateContentResponse{
-		Content: assistant.NewTextContent(res.Content[0].Text),
+		Content: assistant.NewTextContent(responseText(res)),
 		Usage:   toUsage(res.Usage),
 	}, nil
 }
@@ -86,11 +108,13 @@ func (m *ClaudeOpus4_6) GenerateContentStream(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	stream := m.client.Messages.NewStreaming(ctx, *body, m.opts...)
+	stream := m.client.Messages.NewStreaming(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 
 	// return converter iter
 	message := anthropic.Message{}
@@ -101,11 +125,8 @@ func (m *ClaudeOpus4_6) GenerateContentStream(
 
 			switch delta := event.Delta.(type) {
 			case anthropic.ContentBlockDeltaEventDelta:
-				if delta.Text != "" {
-					resp := &assistant.GenerateContentResponse{
-						Content: assistant.NewTextContent(delta.Text),
-					}
-					if !yield(resp, nil) {
+				if content := deltaContent(delta); content != nil {
+					if !yield(&assistant.GenerateContentResponse{Content: content}, nil) {
 						return
 					}
 				}
@@ -17,11 +17,17 @@ const ModelNameClaudeSonnet4_6 = "claude-sonnet-4-6"
 type ClaudeSonnet4_6 struct {
 	systemInstruction []*assistant.TextContent
 	client            *anthropic.Client
+	cache             assistant.CacheMode
+	params            assistant.GenerationParams
 
 	opts []anthropicopt.RequestOption
 }
 
-var _ assistant.GenerativeModel = (*ClaudeSonnet4_6)(nil)
+var (
+	_ assistant.GenerativeModel = (*ClaudeSonnet4_6)(nil)
+	_ assistant.PromptCacher    = (*ClaudeSonnet4_6)(nil)
+	_ assistant.ParamSetter     = (*ClaudeSonnet4_6)(nil)
+)
 
 func NewClaudeSonnet4_6(client *anthropic.Client) *ClaudeSonnet4_6 {
 	return &ClaudeSonnet4_6{client: client}
@@ -35,10 +41,24 @@ Pricing: $3/MTok input, $15/MTok output.
 Supports 200K context window (1M with beta header) and 64K max output.`
 }
 
+func (m *ClaudeSonnet4_6) ContextWindow() int   { return 200_000 }
+func (m *ClaudeSonnet4_6) MaxOutputTokens() int { return 64_000 }
+func (m *ClaudeSonnet4_6) Pricing() assistant.Pricing {
+	return withCacheTTL(m.cache, assistant.Pricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75})
+}
+
 func (m *ClaudeSonnet4_6) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *ClaudeSonnet4_6) SetPromptCache(mode assistant.CacheMode) {
+	m.cache = mode
+}
+
+func (m *ClaudeSonnet4_6) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *ClaudeSonnet4_6) GenerateContent(
 	ctx context.Context,
 	msgs ...assistant.Message,
@@ -50,11 +70,13 @@ func (m *ClaudeSonnet4_6) GenerateContent(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	res, err := m.client.Messages.New(ctx, *body, m.opts...)
+	res, err := m.client.Messages.New(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic New Message: %w", err)
 	}
@@ -71,7 +93,7 @@ func (m *ClaudeSonnet4_6) GenerateContent(
 		logger.Warn("anthropic response has more than one content", "content", fmt.Sprintf("%+v", res.Content))
 	}
 	return &assistant.GenerateContentResponse{
-		Content: assistant.NewTextContent(res.Content[0].Text),
+		Content: assistant.NewTextContent(responseText(res)),
 		Usage:   toUsage(res.Usage),
 	}, nil
 }
@@ -87,11 +109,13 @@ func (m *ClaudeSonnet4_6) GenerateContentStream(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	stream := m.client.Messages.NewStreaming(ctx, *body, m.opts...)
+	stream := m.client.Messages.NewStreaming(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 
 	// return converter iter
 	message := anthropic.Message{}
@@ -102,11 +126,8 @@ func (m *ClaudeSonnet4_6) GenerateContentStream(
 
 			switch delta := event.Delta.(type) {
 			case anthropic.ContentBlockDeltaEventDelta:
-				if delta.Text != "" {
-					resp := &assistant.GenerateContentResponse{
-						Content: assistant.NewTextContent(delta.Text),
-					}
-					if !yield(resp, nil) {
+				if content := deltaContent(delta); content != nil {
+					if !yield(&assistant.GenerateContentResponse{Content: content}, nil) {
 						return
 					}
 				}
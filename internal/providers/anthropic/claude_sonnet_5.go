@@ -17,11 +17,17 @@ const ModelNameClaudeSonnet5 = "claude-sonnet-5"
 type ClaudeSonnet5 struct {
 	systemInstruction []*assistant.TextContent
 	client            *anthropic.Client
+	cache             assistant.CacheMode
+	params            assistant.GenerationParams
 
 	opts []anthropicopt.RequestOption
 }
 
-var _ assistant.GenerativeModel = (*ClaudeSonnet5)(nil)
+var (
+	_ assistant.GenerativeModel = (*ClaudeSonnet5)(nil)
+	_ assistant.PromptCacher    = (*ClaudeSonnet5)(nil)
+	_ assistant.ParamSetter     = (*ClaudeSonnet5)(nil)
+)
 
 func NewClaudeSonnet5(client *anthropic.Client) *ClaudeSonnet5 { return &ClaudeSonnet5{client: client} }
 func (m *ClaudeSonnet5) Provider() string                      { return ProviderName }
@@ -33,10 +39,24 @@ Pricing: $3/MTok input, $15/MTok output.
 Supports 1M context window and 128K max output.`
 }
 
+func (m *ClaudeSonnet5) ContextWindow() int   { return 1_000_000 }
+func (m *ClaudeSonnet5) MaxOutputTokens() int { return 128_000 }
+func (m *ClaudeSonnet5) Pricing() assistant.Pricing {
+	return withCacheTTL(m.cache, assistant.Pricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75})
+}
+
 func (m *ClaudeSonnet5) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *ClaudeSonnet5) SetPromptCache(mode assistant.CacheMode) {
+	m.cache = mode
+}
+
+func (m *ClaudeSonnet5) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *ClaudeSonnet5) GenerateContent(
 	ctx context.Context,
 	msgs ...assistant.Message,
@@ -48,11 +68,13 @@ func (m *ClaudeSonnet5) GenerateContent(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	res, err := m.client.Messages.New(ctx, *body, m.opts...)
+	res, err := m.client.Messages.New(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic New Message: %w", err)
 	}
@@ -69,7 +91,7 @@ func (m *ClaudeSonnet5) GenerateContent(
 		logger.Warn("anthropic response has more than one content", "content", fmt.Sprintf("%+v", res.Content))
 	}
 	return &assistant.GenerateContentResponse{
-		Content: assistant.NewTextContent(res.Content[0].Text),
+		Content: assistant.NewTextContent(responseText(res)),
 		Usage:   toUsage(res.Usage),
 	}, nil
 }
@@ -85,11 +107,13 @@ func (m *ClaudeSonnet5) GenerateContentStream(
 		logging.ContextWith(ctx, logger),
 		anthropic.Model(m.Name()),
 		m.systemInstruction,
-		msgs)
+		msgs,
+		m.cache,
+		m.params)
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request body: %w", err)
 	}
-	stream := m.client.Messages.NewStreaming(ctx, *body, m.opts...)
+	stream := m.client.Messages.NewStreaming(ctx, *body, append(effortOptions(body.Model.Value, m.params), m.opts...)...)
 
 	// return converter iter
 	message := anthropic.Message{}
@@ -100,11 +124,8 @@ func (m *ClaudeSonnet5) GenerateContentStream(
 
 			switch delta := event.Delta.(type) {
 			case anthropic.ContentBlockDeltaEventDelta:
-				if delta.Text != "" {
-					resp := &assistant.GenerateContentResponse{
-						Content: assistant.NewTextContent(delta.Text),
-					}
-					if !yield(resp, nil) {
+				if content := deltaContent(delta); content != nil {
+					if !yield(&assistant.GenerateContentResponse{Content: content}, nil) {
 						return
 					}
 				}
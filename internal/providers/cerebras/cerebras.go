@@ -4,10 +4,11 @@ import (
 	"fmt"
 
 	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/providers/openai"
 )
 
-// Endpoint is the Cerebras API endpoint (OpenAI-compatible)
-const Endpoint = "https://api.cerebras.ai/v1/chat/completions"
+// DefaultBaseURL is the base URL of the Cerebras API (OpenAI-compatible)
+const DefaultBaseURL = "https://api.cerebras.ai/v1"
 
 // ProviderName is the name of this provider
 const ProviderName = "cerebras"
@@ -37,10 +38,15 @@ func selectModel(modelName string) (assistant.GenerativeModel, bool) {
 }
 
 // NewGenerativeModel creates a new instance of a generative model
-func NewGenerativeModel(modelName, apiKey string) (assistant.GenerativeModel, error) {
+func NewGenerativeModel(modelName, apiKey string, opts ...openai.ClientOption) (assistant.GenerativeModel, error) {
 	switch modelName {
 	case "gpt-oss-120b":
-		return NewGptOss120B(apiKey), nil
+		return NewGptOss120B(apiKey, opts...), nil
 	}
 	return nil, fmt.Errorf("unsupported model name: %s", modelName)
 }
+
+// newAPIClient returns a client for [DefaultBaseURL], unless opts say otherwise.
+func newAPIClient(apiKey string, opts ...openai.ClientOption) *openai.APIClient {
+	return openai.NewAPIClient(apiKey, append([]openai.ClientOption{openai.WithBaseURL(DefaultBaseURL)}, opts...)...)
+}
@@ -0,0 +1,42 @@
+package cerebras
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/httpfixture"
+	"micheam.com/aico/internal/providers/openai"
+)
+
+func TestGptOss120B_GenerateContentStream(t *testing.T) {
+	httpClient, tr := httpfixture.NewTestClient(t, "gpt-oss-120b_stream")
+	apiKey := "test-key"
+	if tr.Recording() {
+		apiKey = os.Getenv("AICO_CEREBRAS_API_KEY")
+	}
+	m := NewGptOss120B(apiKey, openai.WithHTTPClient(httpClient))
+	m.SetSystemInstruction(assistant.NewTextContent("You are a terse assistant."))
+
+	seq, err := m.GenerateContentStream(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")))
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var usage *assistant.Usage
+	for resp, err := range seq {
+		require.NoError(t, err)
+		if resp.Usage != nil {
+			usage = resp.Usage
+			continue
+		}
+		text.WriteString(resp.Content.(*assistant.TextContent).Text)
+	}
+	require.Equal(t, "Hello!", text.String())
+	// Cerebras sends the usage with the finishing chunk.
+	require.Equal(t, &assistant.Usage{InputTokens: 75, OutputTokens: 12}, usage)
+}
@@ -11,13 +11,17 @@ import (
 type GptOss120B struct {
 	systemInstruction []*assistant.TextContent
 	client            *openai.APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*GptOss120B)(nil)
+var (
+	_ assistant.GenerativeModel = (*GptOss120B)(nil)
+	_ assistant.ParamSetter     = (*GptOss120B)(nil)
+)
 
-func NewGptOss120B(apiKey string) *GptOss120B {
+func NewGptOss120B(apiKey string, opts ...openai.ClientOption) *GptOss120B {
 	return &GptOss120B{
-		client: openai.NewAPIClient(apiKey),
+		client: newAPIClient(apiKey, opts...),
 	}
 }
 
@@ -32,17 +36,26 @@ func (m *GptOss120B) Name() string {
 func (m *GptOss120B) Description() string {
 	return `GPT-OSS 120B - General-purpose and fastest model (~3000 tokens/sec).
 Best for: File summarization, explanations, and general code generation.
+Context window: 128K tokens, 40K max output tokens.
 Reference: https://inference-docs.cerebras.ai/models/openai-oss.md`
 }
 
+func (m *GptOss120B) ContextWindow() int         { return 128_000 }
+func (m *GptOss120B) MaxOutputTokens() int       { return 40_000 }
+func (m *GptOss120B) Pricing() assistant.Pricing { return assistant.Pricing{Input: 0.25, Output: 0.69} }
+
 func (m *GptOss120B) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *GptOss120B) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *GptOss120B) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	return openai.GenerateContent(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *GptOss120B) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	return openai.GenerateContentStream(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
@@ -4,10 +4,11 @@ import (
 	"fmt"
 
 	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/providers/openai"
 )
 
-// Endpoint is the Groq API endpoint (OpenAI-compatible)
-const Endpoint = "https://api.groq.com/openai/v1/chat/completions"
+// DefaultBaseURL is the base URL of the Groq API (OpenAI-compatible)
+const DefaultBaseURL = "https://api.groq.com/openai/v1"
 
 // ProviderName is the name of this provider
 const ProviderName = "groq"
@@ -43,14 +44,19 @@ func selectModel(modelName string) (assistant.GenerativeModel, bool) {
 }
 
 // NewGenerativeModel creates a new instance of a generative model
-func NewGenerativeModel(modelName, apiKey string) (assistant.GenerativeModel, error) {
+func NewGenerativeModel(modelName, apiKey string, opts ...openai.ClientOption) (assistant.GenerativeModel, error) {
 	switch modelName {
 	case "llama-3.3-70b-versatile":
-		return NewLlama3_3_70B(apiKey), nil
+		return NewLlama3_3_70B(apiKey, opts...), nil
 	case "llama-3.1-8b-instant":
-		return NewLlama3_1_8B(apiKey), nil
+		return NewLlama3_1_8B(apiKey, opts...), nil
 	case "mixtral-8x7b-32768":
-		return NewMixtral8x7B(apiKey), nil
+		return NewMixtral8x7B(apiKey, opts...), nil
 	}
 	return nil, fmt.Errorf("unsupported model name: %s", modelName)
 }
+
+// newAPIClient returns a client for [DefaultBaseURL], unless opts say otherwise.
+func newAPIClient(apiKey string, opts ...openai.ClientOption) *openai.APIClient {
+	return openai.NewAPIClient(apiKey, append([]openai.ClientOption{openai.WithBaseURL(DefaultBaseURL)}, opts...)...)
+}
@@ -0,0 +1,42 @@
+package groq
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/httpfixture"
+	"micheam.com/aico/internal/providers/openai"
+)
+
+func TestLlama3_3_70B_GenerateContentStream(t *testing.T) {
+	httpClient, tr := httpfixture.NewTestClient(t, "llama-3.3-70b-versatile_stream")
+	apiKey := "test-key"
+	if tr.Recording() {
+		apiKey = os.Getenv("AICO_GROQ_API_KEY")
+	}
+	m := NewLlama3_3_70B(apiKey, openai.WithHTTPClient(httpClient))
+	m.SetSystemInstruction(assistant.NewTextContent("You are a terse assistant."))
+
+	seq, err := m.GenerateContentStream(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")))
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var usage *assistant.Usage
+	for resp, err := range seq {
+		require.NoError(t, err)
+		if resp.Usage != nil {
+			usage = resp.Usage
+			continue
+		}
+		text.WriteString(resp.Content.(*assistant.TextContent).Text)
+	}
+	require.Equal(t, "Hello there!", text.String())
+	// Groq reports no prompt_tokens_details.
+	require.Equal(t, &assistant.Usage{InputTokens: 47, OutputTokens: 4}, usage)
+}
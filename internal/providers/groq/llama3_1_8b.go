@@ -11,13 +11,17 @@ import (
 type Llama3_1_8B struct {
 	systemInstruction []*assistant.TextContent
 	client            *openai.APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*Llama3_1_8B)(nil)
+var (
+	_ assistant.GenerativeModel = (*Llama3_1_8B)(nil)
+	_ assistant.ParamSetter     = (*Llama3_1_8B)(nil)
+)
 
-func NewLlama3_1_8B(apiKey string) *Llama3_1_8B {
+func NewLlama3_1_8B(apiKey string, opts ...openai.ClientOption) *Llama3_1_8B {
 	return &Llama3_1_8B{
-		client: openai.NewAPIClient(apiKey),
+		client: newAPIClient(apiKey, opts...),
 	}
 }
 
@@ -36,14 +40,24 @@ Context window: 128K tokens. Best for: simple tasks, quick Q&A, and low-latency
 Reference: https://console.groq.com/docs/models`
 }
 
+func (m *Llama3_1_8B) ContextWindow() int   { return 128_000 }
+func (m *Llama3_1_8B) MaxOutputTokens() int { return 8_000 }
+func (m *Llama3_1_8B) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 0.05, Output: 0.08}
+}
+
 func (m *Llama3_1_8B) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *Llama3_1_8B) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *Llama3_1_8B) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	return openai.GenerateContent(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *Llama3_1_8B) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	return openai.GenerateContentStream(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
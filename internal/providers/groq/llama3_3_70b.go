@@ -11,13 +11,17 @@ import (
 type Llama3_3_70B struct {
 	systemInstruction []*assistant.TextContent
 	client            *openai.APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*Llama3_3_70B)(nil)
+var (
+	_ assistant.GenerativeModel = (*Llama3_3_70B)(nil)
+	_ assistant.ParamSetter     = (*Llama3_3_70B)(nil)
+)
 
-func NewLlama3_3_70B(apiKey string) *Llama3_3_70B {
+func NewLlama3_3_70B(apiKey string, opts ...openai.ClientOption) *Llama3_3_70B {
 	return &Llama3_3_70B{
-		client: openai.NewAPIClient(apiKey),
+		client: newAPIClient(apiKey, opts...),
 	}
 }
 
@@ -36,14 +40,24 @@ Context window: 128K tokens. Best for: complex reasoning, coding, and creative t
 Reference: https://console.groq.com/docs/models`
 }
 
+func (m *Llama3_3_70B) ContextWindow() int   { return 128_000 }
+func (m *Llama3_3_70B) MaxOutputTokens() int { return 32_000 }
+func (m *Llama3_3_70B) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 0.59, Output: 0.79}
+}
+
 func (m *Llama3_3_70B) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *Llama3_3_70B) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *Llama3_3_70B) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	return openai.GenerateContent(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *Llama3_3_70B) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	return openai.GenerateContentStream(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
@@ -11,13 +11,17 @@ import (
 type Mixtral8x7B struct {
 	systemInstruction []*assistant.TextContent
 	client            *openai.APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*Mixtral8x7B)(nil)
+var (
+	_ assistant.GenerativeModel = (*Mixtral8x7B)(nil)
+	_ assistant.ParamSetter     = (*Mixtral8x7B)(nil)
+)
 
-func NewMixtral8x7B(apiKey string) *Mixtral8x7B {
+func NewMixtral8x7B(apiKey string, opts ...openai.ClientOption) *Mixtral8x7B {
 	return &Mixtral8x7B{
-		client: openai.NewAPIClient(apiKey),
+		client: newAPIClient(apiKey, opts...),
 	}
 }
 
@@ -36,14 +40,24 @@ Context window: 32K tokens. Best for: general-purpose tasks, coding, and reasoni
 Reference: https://console.groq.com/docs/models`
 }
 
+func (m *Mixtral8x7B) ContextWindow() int   { return 32_000 }
+func (m *Mixtral8x7B) MaxOutputTokens() int { return 8_000 }
+func (m *Mixtral8x7B) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 0.24, Output: 0.24}
+}
+
 func (m *Mixtral8x7B) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *Mixtral8x7B) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *Mixtral8x7B) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	return openai.GenerateContent(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *Mixtral8x7B) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	return openai.GenerateContentStream(ctx, m.client, Endpoint, m.Name(), m.systemInstruction, msgs)
+	return openai.GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
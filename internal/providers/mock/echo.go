@@ -0,0 +1,52 @@
+package mock
+
+import (
+	"context"
+	"iter"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+const ModelNameEcho = "echo"
+
+// Echo replies with the text of the last user message.
+type Echo struct {
+	systemInstruction []*assistant.TextContent
+	opts              Options
+}
+
+var _ assistant.GenerativeModel = (*Echo)(nil)
+
+func NewEcho(opts Options) *Echo { return &Echo{opts: opts} }
+func (m *Echo) Provider() string { return ProviderName }
+func (m *Echo) Name() string     { return ModelNameEcho }
+func (m *Echo) Description() string {
+	return `Echo replies with the prompt it was given, i.e. the text of the last user message,
+including attached sources and context. No network access, no cost.
+Set $AICO_MOCK_DELAY (e.g. "20ms") to slow down streaming per token.`
+}
+
+func (m *Echo) ContextWindow() int         { return 200_000 }
+func (m *Echo) MaxOutputTokens() int       { return 200_000 }
+func (m *Echo) Pricing() assistant.Pricing { return assistant.Pricing{} }
+
+func (m *Echo) SetSystemInstruction(contents ...*assistant.TextContent) {
+	m.systemInstruction = contents
+}
+
+func (m *Echo) steps(msgs []assistant.Message) []Step {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].GetAuthor() == assistant.MessageAuthorUser {
+			return []Step{{Text: assistant.MessageText(msgs[i])}}
+		}
+	}
+	return nil
+}
+
+func (m *Echo) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
+	return collect(ctx, m.steps(msgs), m.systemInstruction, msgs)
+}
+
+func (m *Echo) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
+	return stream(ctx, m.steps(msgs), m.opts.Delay, m.systemInstruction, msgs), nil
+}
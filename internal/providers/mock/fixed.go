@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+const ModelNameFixed = "fixed"
+
+// DefaultFixedReply is the reply of [Fixed] when no file is given.
+const DefaultFixedReply = "This is a mock reply."
+
+// Fixed replies with the contents of a file, whatever the prompt.
+type Fixed struct {
+	systemInstruction []*assistant.TextContent
+	opts              Options
+}
+
+var _ assistant.GenerativeModel = (*Fixed)(nil)
+
+func NewFixed(opts Options) *Fixed { return &Fixed{opts: opts} }
+func (m *Fixed) Provider() string  { return ProviderName }
+func (m *Fixed) Name() string      { return ModelNameFixed }
+func (m *Fixed) Description() string {
+	return `Fixed replies with the contents of $AICO_MOCK_FILE, whatever the prompt,
+or with "` + DefaultFixedReply + `" if it is not set. No network access, no cost.
+Set $AICO_MOCK_DELAY (e.g. "20ms") to slow down streaming per token.`
+}
+
+func (m *Fixed) ContextWindow() int         { return 200_000 }
+func (m *Fixed) MaxOutputTokens() int       { return 200_000 }
+func (m *Fixed) Pricing() assistant.Pricing { return assistant.Pricing{} }
+
+func (m *Fixed) SetSystemInstruction(contents ...*assistant.TextContent) {
+	m.systemInstruction = contents
+}
+
+func (m *Fixed) steps() ([]Step, error) {
+	if m.opts.File == "" {
+		return []Step{{Text: DefaultFixedReply}}, nil
+	}
+	b, err := os.ReadFile(m.opts.File)
+	if err != nil {
+		return nil, fmt.Errorf("read mock reply: %w", err)
+	}
+	return []Step{{Text: string(b)}}, nil
+}
+
+func (m *Fixed) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
+	steps, err := m.steps()
+	if err != nil {
+		return nil, err
+	}
+	return collect(ctx, steps, m.systemInstruction, msgs)
+}
+
+func (m *Fixed) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
+	steps, err := m.steps()
+	if err != nil {
+		return nil, err
+	}
+	return stream(ctx, steps, m.opts.Delay, m.systemInstruction, msgs), nil
+}
@@ -0,0 +1,208 @@
+// Package mock provides generative models that never leave the machine.
+// They are meant for scripting and for testing clients end-to-end, such as
+// the CLI's streaming and session handling, without network or API keys.
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"strings"
+	"time"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// ProviderName is the name of this provider
+const ProviderName = "mock"
+
+// Environment variables read by [OptionsFromEnv]
+const (
+	EnvFile  = "AICO_MOCK_FILE"
+	EnvDelay = "AICO_MOCK_DELAY"
+)
+
+// Options configures the mock models.
+type Options struct {
+	// File is the canned reply of "fixed", or the script of "script".
+	File string
+
+	// Delay is the pause before each streamed token.
+	Delay time.Duration
+}
+
+// OptionsFromEnv reads the options from $AICO_MOCK_FILE and
+// $AICO_MOCK_DELAY (a duration such as "20ms").
+func OptionsFromEnv() (Options, error) {
+	opts := Options{File: os.Getenv(EnvFile)}
+	if s := os.Getenv(EnvDelay); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return Options{}, fmt.Errorf("%s: %w", EnvDelay, err)
+		}
+		opts.Delay = d
+	}
+	return opts, nil
+}
+
+// AvailableModels returns a list of available models
+func AvailableModels() []assistant.ModelDescriptor {
+	return []assistant.ModelDescriptor{
+		&Echo{},
+		&Fixed{},
+		&Script{},
+	}
+}
+
+func DescribeModel(modelName string) (desc string, found bool) {
+	m, ok := selectModel(modelName)
+	if !ok {
+		return "", false
+	}
+	return m.Description(), true
+}
+
+func selectModel(modelName string) (assistant.GenerativeModel, bool) {
+	switch modelName {
+	default:
+		return nil, false
+	case ModelNameEcho:
+		return &Echo{}, true
+	case ModelNameFixed:
+		return &Fixed{}, true
+	case ModelNameScript:
+		return &Script{}, true
+	}
+}
+
+// NewGenerativeModel creates a new instance of a generative model
+func NewGenerativeModel(modelName string, opts Options) (assistant.GenerativeModel, error) {
+	switch modelName {
+	case ModelNameEcho:
+		return NewEcho(opts), nil
+	case ModelNameFixed:
+		return NewFixed(opts), nil
+	case ModelNameScript:
+		return NewScript(opts), nil
+	}
+	return nil, fmt.Errorf("unsupported model name: %s", modelName)
+}
+
+// -----------------------------------------------------------------------------
+// Steps
+// -----------------------------------------------------------------------------
+
+// Step is a single event of a mock reply. Exactly one field should be set.
+type Step struct {
+	// Text is streamed token by token.
+	Text string `json:"text,omitempty"`
+
+	// Thinking is emitted as a single chunk of reasoning.
+	Thinking string `json:"thinking,omitempty"`
+
+	// ToolCall is emitted as a single chunk.
+	ToolCall *assistant.ToolCallContent `json:"tool_call,omitempty"`
+
+	// Error ends the stream with an error.
+	Error string `json:"error,omitempty"`
+
+	// Usage replaces the estimated usage reported at the end of the stream.
+	Usage *assistant.Usage `json:"usage,omitempty"`
+}
+
+// stream plays steps, pausing delay before each token. Unless a step sets
+// the usage, it is estimated from the prompt and the streamed text.
+func stream(ctx context.Context, steps []Step, delay time.Duration, system []*assistant.TextContent, msgs []assistant.Message) iter.Seq2[*assistant.GenerateContentResponse, error] {
+	return func(yield func(*assistant.GenerateContentResponse, error) bool) {
+		var (
+			usage  *assistant.Usage
+			output strings.Builder
+		)
+		for _, step := range steps {
+			switch {
+			case step.Error != "":
+				yield(nil, errors.New(step.Error))
+				return
+			case step.Thinking != "":
+				if !yield(&assistant.GenerateContentResponse{Content: &assistant.ThinkingContent{Text: step.Thinking}}, nil) {
+					return
+				}
+			case step.ToolCall != nil:
+				if !yield(&assistant.GenerateContentResponse{Content: step.ToolCall}, nil) {
+					return
+				}
+			case step.Usage != nil:
+				usage = step.Usage
+			default:
+				for _, token := range tokens(step.Text) {
+					if delay > 0 {
+						select {
+						case <-ctx.Done():
+							yield(nil, ctx.Err())
+							return
+						case <-time.After(delay):
+						}
+					}
+					output.WriteString(token)
+					if !yield(&assistant.GenerateContentResponse{Content: assistant.NewTextContent(token)}, nil) {
+						return
+					}
+				}
+			}
+		}
+		if usage == nil {
+			usage = &assistant.Usage{
+				InputTokens:  assistant.EstimatePromptTokens(system, msgs),
+				OutputTokens: assistant.EstimateTokens(output.String()),
+			}
+		}
+		yield(&assistant.GenerateContentResponse{Usage: usage}, nil)
+	}
+}
+
+// collect plays steps without delay and returns them as a single response.
+// Tool calls are only returned if there is no text; thinking is dropped.
+func collect(ctx context.Context, steps []Step, system []*assistant.TextContent, msgs []assistant.Message) (*assistant.GenerateContentResponse, error) {
+	var (
+		text     strings.Builder
+		toolCall assistant.MessageContent
+		usage    *assistant.Usage
+	)
+	for resp, err := range stream(ctx, steps, 0, system, msgs) {
+		if err != nil {
+			return nil, err
+		}
+		switch c := resp.Content.(type) {
+		case *assistant.TextContent:
+			text.WriteString(c.Text)
+		case *assistant.ToolCallContent:
+			toolCall = c
+		case nil:
+			usage = resp.Usage
+		}
+	}
+	var content assistant.MessageContent = assistant.NewTextContent(text.String())
+	if text.Len() == 0 && toolCall != nil {
+		content = toolCall
+	}
+	return &assistant.GenerateContentResponse{Content: content, Usage: usage}, nil
+}
+
+// tokens splits text into words, keeping the whitespace before each word,
+// so that joining them yields text again.
+func tokens(text string) []string {
+	var out []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i-1] != ' ' && text[i-1] != '\n' && (text[i] == ' ' || text[i] == '\n') {
+			out = append(out, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		out = append(out, text[start:])
+	}
+	return out
+}
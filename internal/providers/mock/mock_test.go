@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+// play collects the streamed text, tool calls, usage and error of seq.
+func play(t *testing.T, seq func(func(*assistant.GenerateContentResponse, error) bool)) (string, []*assistant.ToolCallContent, *assistant.Usage, error) {
+	t.Helper()
+	var (
+		text  strings.Builder
+		calls []*assistant.ToolCallContent
+		usage *assistant.Usage
+	)
+	for resp, err := range seq {
+		if err != nil {
+			return text.String(), calls, usage, err
+		}
+		switch c := resp.Content.(type) {
+		case *assistant.TextContent:
+			text.WriteString(c.Text)
+		case *assistant.ToolCallContent:
+			calls = append(calls, c)
+		case nil:
+			usage = resp.Usage
+		}
+	}
+	return text.String(), calls, usage, nil
+}
+
+func TestEcho(t *testing.T) {
+	m, err := NewGenerativeModel(ModelNameEcho, Options{})
+	require.NoError(t, err)
+	msgs := []assistant.Message{
+		assistant.NewUserMessage(assistant.NewTextContent("first")),
+		assistant.NewAssistantMessage(assistant.NewTextContent("reply")),
+		assistant.NewUserMessage(assistant.NewTextContent("hello mock  world\nbye")),
+	}
+
+	seq, err := m.GenerateContentStream(context.Background(), msgs...)
+	require.NoError(t, err)
+	text, _, usage, err := play(t, seq)
+	require.NoError(t, err)
+	require.Equal(t, "hello mock  world\nbye", text)
+	require.Equal(t, assistant.EstimateTokens(text), usage.OutputTokens)
+	require.Equal(t, assistant.EstimatePromptTokens(nil, msgs), usage.InputTokens)
+
+	resp, err := m.GenerateContent(context.Background(), msgs...)
+	require.NoError(t, err)
+	require.Equal(t, "hello mock  world\nbye", resp.Content.(*assistant.TextContent).Text)
+}
+
+func TestFixed(t *testing.T) {
+	msg := assistant.NewUserMessage(assistant.NewTextContent("anything"))
+
+	resp, err := NewFixed(Options{}).GenerateContent(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, DefaultFixedReply, resp.Content.(*assistant.TextContent).Text)
+
+	path := filepath.Join(t.TempDir(), "reply.txt")
+	require.NoError(t, os.WriteFile(path, []byte("canned reply\n"), 0644))
+	resp, err = NewFixed(Options{File: path}).GenerateContent(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, "canned reply\n", resp.Content.(*assistant.TextContent).Text)
+}
+
+func TestScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+	  "turns": [
+	    [{"text": "Hello there!"}, {"usage": {"input_tokens": 12, "output_tokens": 3}}],
+	    [{"tool_call": {"id": "call_1", "name": "get_weather", "arguments": {"city": "Tokyo"}}}],
+	    [{"text": "Partial"}, {"error": "connection reset"}]
+	  ]
+	}`), 0644))
+	m := NewScript(Options{File: path})
+
+	history := []assistant.Message{assistant.NewUserMessage(assistant.NewTextContent("hi"))}
+	seq, err := m.GenerateContentStream(context.Background(), history...)
+	require.NoError(t, err)
+	text, _, usage, err := play(t, seq)
+	require.NoError(t, err)
+	require.Equal(t, "Hello there!", text)
+	require.Equal(t, &assistant.Usage{InputTokens: 12, OutputTokens: 3}, usage)
+
+	history = append(history,
+		assistant.NewAssistantMessage(assistant.NewTextContent(text)),
+		assistant.NewUserMessage(assistant.NewTextContent("weather?")))
+	seq, err = m.GenerateContentStream(context.Background(), history...)
+	require.NoError(t, err)
+	_, calls, _, err := play(t, seq)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	require.Equal(t, "get_weather", calls[0].Name)
+	require.JSONEq(t, `{"city": "Tokyo"}`, string(calls[0].Arguments))
+
+	// The third and any later turn end with the scripted error.
+	history = append(history,
+		assistant.NewAssistantMessage(assistant.NewTextContent("sunny")),
+		assistant.NewUserMessage(assistant.NewTextContent("more")),
+		assistant.NewAssistantMessage(assistant.NewTextContent("Partial")),
+		assistant.NewUserMessage(assistant.NewTextContent("again")))
+	seq, err = m.GenerateContentStream(context.Background(), history...)
+	require.NoError(t, err)
+	text, _, _, err = play(t, seq)
+	require.EqualError(t, err, "connection reset")
+	require.Equal(t, "Partial", text)
+
+	_, err = NewScript(Options{}).GenerateContentStream(context.Background(), history...)
+	require.ErrorContains(t, err, EnvFile)
+}
+
+func TestStream_Delay(t *testing.T) {
+	m := NewEcho(Options{Delay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	seq, err := m.GenerateContentStream(ctx, assistant.NewUserMessage(assistant.NewTextContent("never arrives")))
+	require.NoError(t, err)
+	_, _, _, err = play(t, seq)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTokens(t *testing.T) {
+	for _, text := range []string{"", "one", "one two", " lead", "trail ", "a  b\n\nc"} {
+		require.Equal(t, text, strings.Join(tokens(text), ""), text)
+	}
+	require.Equal(t, []string{"one", " two", "\nthree"}, tokens("one two\nthree"))
+}
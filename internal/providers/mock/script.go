@@ -0,0 +1,112 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"time"
+
+	"micheam.com/aico/internal/assistant"
+)
+
+const ModelNameScript = "script"
+
+// ScriptFile is the format of the file played by [Script].
+//
+// Example:
+//
+//	{
+//	  "delay": "20ms",
+//	  "turns": [
+//	    [{"thinking": "A greeting."}, {"text": "Hello!"}, {"usage": {"input_tokens": 12, "output_tokens": 2}}],
+//	    [{"tool_call": {"name": "get_weather", "arguments": {"city": "Tokyo"}}}],
+//	    [{"text": "Partial"}, {"error": "connection reset"}]
+//	  ]
+//	}
+type ScriptFile struct {
+	// Delay is the pause before each streamed token, as a duration.
+	// $AICO_MOCK_DELAY takes precedence.
+	Delay string `json:"delay,omitempty"`
+
+	// Turns are the replies, in order.
+	Turns [][]Step `json:"turns"`
+}
+
+// Script follows a scripted sequence of replies. The n-th reply of a
+// conversation plays the n-th turn of the script (the last one once the
+// script is exhausted), so sessions resumed across invocations advance
+// through the script.
+type Script struct {
+	systemInstruction []*assistant.TextContent
+	opts              Options
+}
+
+var _ assistant.GenerativeModel = (*Script)(nil)
+
+func NewScript(opts Options) *Script { return &Script{opts: opts} }
+func (m *Script) Provider() string   { return ProviderName }
+func (m *Script) Name() string       { return ModelNameScript }
+func (m *Script) Description() string {
+	return `Script plays the replies scripted in the JSON file $AICO_MOCK_FILE: text
+streamed token by token, thinking, tool calls, errors and fake usage. The n-th reply of a
+conversation plays the n-th turn. No network access, no cost.`
+}
+
+func (m *Script) ContextWindow() int         { return 200_000 }
+func (m *Script) MaxOutputTokens() int       { return 200_000 }
+func (m *Script) Pricing() assistant.Pricing { return assistant.Pricing{} }
+
+func (m *Script) SetSystemInstruction(contents ...*assistant.TextContent) {
+	m.systemInstruction = contents
+}
+
+// turn loads the script and returns the steps of the reply to msgs.
+func (m *Script) turn(msgs []assistant.Message) ([]Step, time.Duration, error) {
+	if m.opts.File == "" {
+		return nil, 0, errors.New("mock:script needs a script file in $" + EnvFile)
+	}
+	b, err := os.ReadFile(m.opts.File)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read mock script: %w", err)
+	}
+	var script ScriptFile
+	if err := json.Unmarshal(b, &script); err != nil {
+		return nil, 0, fmt.Errorf("parse mock script %s: %w", m.opts.File, err)
+	}
+	if len(script.Turns) == 0 {
+		return nil, 0, fmt.Errorf("mock script %s has no turns", m.opts.File)
+	}
+	delay := m.opts.Delay
+	if delay == 0 && script.Delay != "" {
+		if delay, err = time.ParseDuration(script.Delay); err != nil {
+			return nil, 0, fmt.Errorf("mock script delay: %w", err)
+		}
+	}
+
+	var n int
+	for _, msg := range msgs {
+		if msg.GetAuthor() == assistant.MessageAuthorAssistant {
+			n++
+		}
+	}
+	return script.Turns[min(n, len(script.Turns)-1)], delay, nil
+}
+
+func (m *Script) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
+	steps, _, err := m.turn(msgs)
+	if err != nil {
+		return nil, err
+	}
+	return collect(ctx, steps, m.systemInstruction, msgs)
+}
+
+func (m *Script) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
+	steps, delay, err := m.turn(msgs)
+	if err != nil {
+		return nil, err
+	}
+	return stream(ctx, steps, delay, m.systemInstruction, msgs), nil
+}
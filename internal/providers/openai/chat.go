@@ -10,7 +10,9 @@ import (
 	"micheam.com/aico/internal/logging"
 )
 
-const endpoint = "https://api.openai.com/v1/chat/completions"
+// chatCompletionsPath is the path of the Chat API, relative to the base URL
+const chatCompletionsPath = "/chat/completions"
+
 const ProviderName = "openai"
 
 // AvailableModels returns a list of available models
@@ -53,20 +55,20 @@ func selectModel(modelName string) (assistant.GenerativeModel, bool) {
 }
 
 // NewGenerativeModel creates a new instance of a generative model
-func NewGenerativeModel(modelName, apiKey string) (assistant.GenerativeModel, error) {
+func NewGenerativeModel(modelName, apiKey string, opts ...ClientOption) (assistant.GenerativeModel, error) {
 	switch modelName {
 	case "gpt-5.2":
-		return NewGPT52(apiKey), nil
+		return NewGPT52(apiKey, opts...), nil
 	case "gpt-4.1":
-		return NewGPT41(apiKey), nil
+		return NewGPT41(apiKey, opts...), nil
 	case "gpt-4.1-mini":
-		return NewGPT41Mini(apiKey), nil
+		return NewGPT41Mini(apiKey, opts...), nil
 	case "o3":
-		return NewO3(apiKey), nil
+		return NewO3(apiKey, opts...), nil
 	case "o4-mini":
-		return NewO4Mini(apiKey), nil
+		return NewO4Mini(apiKey, opts...), nil
 	case "o3-mini":
-		return NewO3Mini(apiKey), nil
+		return NewO3Mini(apiKey, opts...), nil
 	}
 	return nil, fmt.Errorf("unsupported model name: %s", modelName)
 }
@@ -90,7 +92,7 @@ type ChatRequest struct {
 	// Higher values like 0.8 will make the output more random, while lower values
 	// like 0.2 will make it more focused and deterministic.
 	// We generally recommend altering this or top_p but not both.
-	Temperature float64 `json:"temperature,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
 
 	// top_p number Optional Defaults to 1
 	//
@@ -125,6 +127,17 @@ type ChatRequest struct {
 	// The total length of input tokens and generated tokens is limited by the model's context length.
 	MaxTokens int `json:"max_tokens,omitempty"`
 
+	// max_completion_tokens integer Optional
+	//
+	// An upper bound for the number of tokens that can be generated for a completion,
+	// including visible output tokens and reasoning tokens. Supersedes max_tokens.
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+
+	// reasoning_effort string Optional Defaults to medium
+	//
+	// Constrains effort on reasoning for reasoning models: low, medium or high.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
 	// presence_penalty number
 	// Optional
 	// Defaults to 0
@@ -220,6 +233,13 @@ func BuildChatRequest(ctx context.Context, modelName string, systemInstruction [
 	return req, nil
 }
 
+// setParams applies the generation parameters to r.
+func (r *ChatRequest) setParams(p assistant.GenerationParams) {
+	r.Temperature = p.Temperature
+	r.MaxCompletionTokens = p.MaxTokens
+	r.ReasoningEffort = string(p.Effort)
+}
+
 func convertToContentArray(contents []assistant.MessageContent) ([]Content, error) {
 	result := make([]Content, 0, len(contents))
 	for i, content := range contents {
@@ -266,27 +286,29 @@ func toUsage(src Usage) *assistant.Usage {
 }
 
 // GenerateContent is a shared implementation for generating content with OpenAI-compatible APIs
-func GenerateContent(ctx context.Context, client *APIClient, apiEndpoint string, modelName string, systemInstruction []*assistant.TextContent, msgs []assistant.Message) (*assistant.GenerateContentResponse, error) {
+func GenerateContent(ctx context.Context, client *APIClient, modelName string, systemInstruction []*assistant.TextContent, params assistant.GenerationParams, msgs []assistant.Message) (*assistant.GenerateContentResponse, error) {
 	req, err := BuildChatRequest(ctx, modelName, systemInstruction, msgs)
 	if err != nil {
 		return nil, fmt.Errorf("build chat request: %w", err)
 	}
+	req.setParams(params)
 	resp := new(ChatResponse)
-	if err := client.DoPost(ctx, apiEndpoint, req, resp); err != nil {
+	if err := client.DoPost(ctx, chatCompletionsPath, req, resp); err != nil {
 		return nil, err
 	}
 	return ToGenerateContentResponse(resp), nil
 }
 
 // GenerateContentStream is a shared implementation for streaming content with OpenAI-compatible APIs
-func GenerateContentStream(ctx context.Context, client *APIClient, apiEndpoint string, modelName string, systemInstruction []*assistant.TextContent, msgs []assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
+func GenerateContentStream(ctx context.Context, client *APIClient, modelName string, systemInstruction []*assistant.TextContent, params assistant.GenerationParams, msgs []assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
 	req, err := BuildChatRequest(ctx, modelName, systemInstruction, msgs)
 	if err != nil {
 		return nil, fmt.Errorf("build chat request: %w", err)
 	}
+	req.setParams(params)
 	req.Stream = true
 	req.StreamOptions = &StreamOptions{IncludeUsage: true}
-	iter, err := client.DoStream(ctx, apiEndpoint, req)
+	iter, err := client.DoStream(ctx, chatCompletionsPath, req)
 	if err != nil {
 		return nil, err
 	}
@@ -301,7 +323,8 @@ func GenerateContentStream(ctx context.Context, client *APIClient, apiEndpoint s
 				}
 				continue
 			}
-			if res.Usage.PromptTokensDetails != nil {
+			// With include_usage, the last chunk carries the usage and no choices.
+			if res.Usage.PromptTokens > 0 || res.Usage.CompletionTokens > 0 {
 				if !yield(&assistant.GenerateContentResponse{Usage: toUsage(res.Usage)}, nil) {
 					break
 				}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"micheam.com/aico/internal/assistant"
+	"micheam.com/aico/internal/httpfixture"
+)
+
+func newTestGPT41(t *testing.T, fixture string) *GPT41 {
+	httpClient, tr := httpfixture.NewTestClient(t, fixture)
+	apiKey := "test-key"
+	if tr.Recording() {
+		apiKey = os.Getenv("AICO_OPENAI_API_KEY")
+	}
+	m := NewGPT41(apiKey, WithHTTPClient(httpClient))
+	m.SetSystemInstruction(assistant.NewTextContent("You are a terse assistant."))
+	return m
+}
+
+func TestGPT41_GenerateContent(t *testing.T) {
+	m := newTestGPT41(t, "gpt-4.1")
+	resp, err := m.GenerateContent(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")))
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", resp.Content.(*assistant.TextContent).Text)
+	require.Equal(t, &assistant.Usage{InputTokens: 21, OutputTokens: 3}, resp.Usage)
+}
+
+func TestGPT41_GenerateContentStream(t *testing.T) {
+	m := newTestGPT41(t, "gpt-4.1_stream")
+	seq, err := m.GenerateContentStream(context.Background(),
+		assistant.NewUserMessage(assistant.NewTextContent("Say hello.")))
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var usage *assistant.Usage
+	for resp, err := range seq {
+		require.NoError(t, err)
+		if resp.Usage != nil {
+			usage = resp.Usage
+			continue
+		}
+		text.WriteString(resp.Content.(*assistant.TextContent).Text)
+	}
+	require.Equal(t, "Hello, world!", text.String())
+	require.Equal(t, &assistant.Usage{InputTokens: 1290, OutputTokens: 5, CachedInputTokens: 1152}, usage)
+}
+
+func TestChatRequest_SetParams(t *testing.T) {
+	var req ChatRequest
+	b, err := json.Marshal(req)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "temperature")
+
+	temp := 0.0
+	req.setParams(assistant.GenerationParams{Temperature: &temp, MaxTokens: 100, Effort: assistant.EffortHigh})
+	b, err = json.Marshal(req)
+	require.NoError(t, err)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(b, &got))
+	require.Equal(t, 0.0, got["temperature"])
+	require.EqualValues(t, 100, got["max_completion_tokens"])
+	require.Equal(t, "high", got["reasoning_effort"])
+}
@@ -8,24 +8,73 @@ import (
 	"fmt"
 	"iter"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+
+	"micheam.com/aico/internal/httpfixture"
 )
 
+// DefaultBaseURL is the base URL of the OpenAI API
+const DefaultBaseURL = "https://api.openai.com/v1"
+
 // APIClient is used to access the OpenAI API
 type APIClient struct {
 	apiKey     string // APIKey string Required
+	baseURL    string
 	httpClient *http.Client
+	maxRetries int
+}
+
+// ClientOption configures an APIClient.
+type ClientOption func(*APIClient)
+
+// WithBaseURL sets the base URL of an OpenAI-compatible API, such as
+// "https://api.groq.com/openai/v1". An empty URL keeps the current one.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *APIClient) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *APIClient) {
+		c.httpClient = httpClient
+	}
 }
 
-// NewAPIClient returns a new Client
-func NewAPIClient(apiKey string) *APIClient {
-	return &APIClient{
+// WithMaxRetries sets how many times a request is retried after a connection
+// error, a rate limit (429) or a server error (5xx), with exponential
+// backoff. Requests are not retried by default.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *APIClient) {
+		c.maxRetries = max(n, 0)
+	}
+}
+
+// NewAPIClient returns a new Client for [DefaultBaseURL], unless
+// configured otherwise.
+func NewAPIClient(apiKey string, opts ...ClientOption) *APIClient {
+	c := &APIClient{
 		apiKey:     apiKey,
+		baseURL:    DefaultBaseURL,
 		httpClient: http.DefaultClient,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the base URL requests are sent to.
+func (c *APIClient) BaseURL() string {
+	return c.baseURL
 }
 
 // SetHTTPClient is used to set the HTTP client
@@ -43,23 +92,16 @@ func (c *APIClient) SetHTTPClient(httpClient *http.Client) {
 	c.httpClient = httpClient
 }
 
-// DoPost is used to make a POST request to the OpenAI API
-func (c *APIClient) DoPost(_ context.Context, endpoint string, req any, resp any) error {
+// DoPost is used to make a POST request to path, relative to the base URL
+// (e.g. "/chat/completions")
+func (c *APIClient) DoPost(ctx context.Context, path string, req any, resp any) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
-	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	httpResp, err := c.httpClient.Do(httpReq)
+	httpResp, err := c.post(ctx, path, body)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return err
 	}
 	defer httpResp.Body.Close()
 
@@ -77,20 +119,16 @@ func (c *APIClient) DoPost(_ context.Context, endpoint string, req any, resp any
 	return json.NewDecoder(httpResp.Body).Decode(resp)
 }
 
-func (c *APIClient) DoStream(ctx context.Context, endpoint string, req any) (iter.Seq[string], error) {
+// DoStream is used to make a streaming POST request to path, relative to
+// the base URL. It yields the data of each server-sent event.
+func (c *APIClient) DoStream(ctx context.Context, path string, req any) (iter.Seq[string], error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	httpResp, err := c.post(ctx, path, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpResp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, err
 	}
 	// Handle HTTP Error
 	if httpResp.StatusCode != http.StatusOK {
@@ -118,8 +156,60 @@ func (c *APIClient) DoStream(ctx context.Context, endpoint string, req any) (ite
 	}, nil
 }
 
+// post sends body to path, retrying as configured with [WithMaxRetries]. The
+// caller must close the body of the response.
+func (c *APIClient) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		httpResp, err := c.httpClient.Do(httpReq)
+		retryable := err != nil || httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500
+		if !retryable || attempt >= c.maxRetries || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("failed to make request: %w", err)
+			}
+			return httpResp, nil
+		}
+		wait := retryDelay(attempt, httpResp)
+		if timeout := c.httpClient.Timeout; timeout > 0 {
+			wait = min(wait, timeout)
+		}
+		if httpResp != nil {
+			httpResp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to make request: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// maxRetryAfter caps the Retry-After of a response, so that a misbehaving
+// server cannot stall a request indefinitely. The timeout of the HTTP
+// client caps it further.
+const maxRetryAfter = time.Minute
+
+// retryDelay returns how long to wait before retrying after the given
+// attempt: the Retry-After of resp, if any, up to [maxRetryAfter], else
+// 0.5s doubling up to 8s.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, maxRetryAfter)
+		}
+	}
+	return min(500*time.Millisecond<<attempt, 8*time.Second)
+}
+
 // DebugTransport is a custom transport that outputs HTTP request and response
-// debugging information.
+// debugging information to stderr, in the format of httpfixture fixtures.
+// Request headers are left out, as they carry the API key.
 type DebugTransport struct {
 	Transport http.RoundTripper
 }
@@ -131,17 +221,13 @@ func NewDebugTransport() *DebugTransport {
 var _ http.RoundTripper = (*DebugTransport)(nil)
 
 func (d *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	var requestDump, responseDump []byte
-	defer func() {
-		fmt.Printf("Request: %s\n", requestDump)
-		fmt.Printf("Response: %s\n", responseDump)
-	}()
-	requestDump, _ = httputil.DumpRequest(req, true)
-	resp, err := d.Transport.RoundTrip(req)
+	resp, in, err := httpfixture.Capture(d.Transport, req)
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "Request: %s %s\nError: %v\n", req.Method, req.URL, err)
 		return nil, err
 	}
-	responseDump, _ = httputil.DumpResponse(resp, true)
+	b, _ := json.MarshalIndent(in, "", "  ")
+	fmt.Fprintf(os.Stderr, "%s\n", b)
 	return resp, nil
 }
 
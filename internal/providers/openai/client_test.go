@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClient_DoPostRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	var resp struct{ OK bool }
+	c := NewAPIClient("key", WithBaseURL(srv.URL))
+	require.ErrorContains(t, c.DoPost(context.Background(), "/chat/completions", struct{}{}, &resp), "503")
+	require.Equal(t, 1, calls)
+
+	calls = 0
+	c = NewAPIClient("key", WithBaseURL(srv.URL), WithMaxRetries(2))
+	require.NoError(t, c.DoPost(context.Background(), "/chat/completions", struct{}{}, &resp))
+	require.True(t, resp.OK)
+	require.Equal(t, 3, calls)
+}
+
+func TestAPIClient_DoPostCapsRetryAfter(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "86400")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	require.Equal(t, maxRetryAfter, retryDelay(0, &http.Response{Header: http.Header{"Retry-After": {"86400"}}}))
+
+	var resp struct{ OK bool }
+	c := NewAPIClient("key", WithBaseURL(srv.URL), WithMaxRetries(1))
+	c.SetHTTPClient(&http.Client{Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	require.NoError(t, c.DoPost(context.Background(), "/chat/completions", struct{}{}, &resp))
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, 2, calls)
+}
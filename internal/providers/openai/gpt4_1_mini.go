@@ -2,25 +2,26 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"iter"
 	"net/http"
 
 	"micheam.com/aico/internal/assistant"
-	"micheam.com/aico/internal/logging"
 )
 
 type GPT41Mini struct {
 	systemInstruction []*assistant.TextContent
 	client            *APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*GPT41Mini)(nil)
+var (
+	_ assistant.GenerativeModel = (*GPT41Mini)(nil)
+	_ assistant.ParamSetter     = (*GPT41Mini)(nil)
+)
 
-func NewGPT41Mini(apiKey string) *GPT41Mini {
+func NewGPT41Mini(apiKey string, opts ...ClientOption) *GPT41Mini {
 	return &GPT41Mini{
-		client: NewAPIClient(apiKey),
+		client: NewAPIClient(apiKey, opts...),
 	}
 }
 
@@ -39,52 +40,28 @@ Pricing: $0.40 / $1.60 per MTok (input / output).
 Reference: https://platform.openai.com/docs/models#gpt-4.1-mini`
 }
 
+func (m *GPT41Mini) ContextWindow() int   { return 1_000_000 }
+func (m *GPT41Mini) MaxOutputTokens() int { return 32_000 }
+func (m *GPT41Mini) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 0.4, Output: 1.6, CacheRead: 0.1}
+}
+
 func (m *GPT41Mini) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *GPT41Mini) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *GPT41Mini) SetHttpClient(c *http.Client) {
 	m.client.SetHTTPClient(c)
 }
 
 func (m *GPT41Mini) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	resp := new(ChatResponse)
-	if err := m.client.DoPost(ctx, endpoint, req, resp); err != nil {
-		return nil, err
-	}
-	return ToGenerateContentResponse(resp), nil
+	return GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *GPT41Mini) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	req.Stream = true
-	iter, err := m.client.DoStream(ctx, endpoint, req)
-	if err != nil {
-		return nil, err
-	}
-	return func(yield func(*assistant.GenerateContentResponse, error) bool) {
-		for s := range iter {
-			var res *ChatResponse
-			err := json.Unmarshal([]byte(s), &res)
-			if err != nil {
-				logging.LoggerFrom(ctx).Error(fmt.Sprintf("unmarshal error: %v", err))
-				yield(nil, fmt.Errorf("failed to unmarshal stream response: %w", err))
-				continue
-			}
-			if len(res.Choices) == 0 || res.Choices[0].Delta == nil {
-				continue
-			}
-			delta := assistant.NewTextContent(res.Choices[0].Delta.Content)
-			if !yield(&assistant.GenerateContentResponse{Content: delta}, nil) {
-				break
-			}
-		}
-	}, nil
+	return GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
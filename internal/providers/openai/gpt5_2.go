@@ -2,25 +2,26 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"iter"
 	"net/http"
 
 	"micheam.com/aico/internal/assistant"
-	"micheam.com/aico/internal/logging"
 )
 
 type GPT52 struct {
 	systemInstruction []*assistant.TextContent
 	client            *APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*GPT52)(nil)
+var (
+	_ assistant.GenerativeModel = (*GPT52)(nil)
+	_ assistant.ParamSetter     = (*GPT52)(nil)
+)
 
-func NewGPT52(apiKey string) *GPT52 {
+func NewGPT52(apiKey string, opts ...ClientOption) *GPT52 {
 	return &GPT52{
-		client: NewAPIClient(apiKey),
+		client: NewAPIClient(apiKey, opts...),
 	}
 }
 
@@ -40,55 +41,28 @@ Pricing: $1.75 / $14.00 per MTok (input / output).
 Reference: https://platform.openai.com/docs/models#gpt-5.2`
 }
 
+func (m *GPT52) ContextWindow() int   { return 400_000 }
+func (m *GPT52) MaxOutputTokens() int { return 128_000 }
+func (m *GPT52) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 1.75, Output: 14, CacheRead: 0.175}
+}
+
 func (m *GPT52) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *GPT52) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *GPT52) SetHttpClient(c *http.Client) {
 	m.client.SetHTTPClient(c)
 }
 
 func (m *GPT52) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	// Send request
-	resp := new(ChatResponse)
-	if err := m.client.DoPost(ctx, endpoint, req, resp); err != nil {
-		return nil, err
-	}
-	return ToGenerateContentResponse(resp), nil
+	return GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *GPT52) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	// Send request
-	req.Stream = true
-	iter, err := m.client.DoStream(ctx, endpoint, req)
-	if err != nil {
-		return nil, err
-	}
-	// return converter iter
-	return func(yield func(*assistant.GenerateContentResponse, error) bool) {
-		for s := range iter {
-			var res *ChatResponse
-			err := json.Unmarshal([]byte(s), &res)
-			if err != nil {
-				logging.LoggerFrom(ctx).Error(fmt.Sprintf("unmarshal error: %v", err))
-				yield(nil, fmt.Errorf("failed to unmarshal stream response: %w", err))
-				continue
-			}
-			if len(res.Choices) == 0 || res.Choices[0].Delta == nil {
-				continue
-			}
-			delta := assistant.NewTextContent(res.Choices[0].Delta.Content)
-			if !yield(&assistant.GenerateContentResponse{Content: delta}, nil) {
-				break
-			}
-		}
-	}, nil
+	return GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
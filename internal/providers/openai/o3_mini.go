@@ -2,25 +2,26 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"iter"
 	"net/http"
 
 	"micheam.com/aico/internal/assistant"
-	"micheam.com/aico/internal/logging"
 )
 
 type O3Mini struct {
 	systemInstruction []*assistant.TextContent
 	client            *APIClient
+	params            assistant.GenerationParams
 }
 
-var _ assistant.GenerativeModel = (*O3Mini)(nil)
+var (
+	_ assistant.GenerativeModel = (*O3Mini)(nil)
+	_ assistant.ParamSetter     = (*O3Mini)(nil)
+)
 
-func NewO3Mini(apiKey string) *O3Mini {
+func NewO3Mini(apiKey string, opts ...ClientOption) *O3Mini {
 	return &O3Mini{
-		client: NewAPIClient(apiKey),
+		client: NewAPIClient(apiKey, opts...),
 	}
 }
 
@@ -39,58 +40,32 @@ o3-mini is the most recent small reasoning model, providing high intelligence at
 It supports key developer features, including structured outputs, function calling, and the Batch API.
 Like other models in the o-series, it is optimised for science, math, and coding tasks.
 The knowledge cutoff date for o3-mini models is October 2023.
+It features a 200K context window and 100K max output tokens.
 Reference: https://platform.openai.com/docs/models#o3-mini`
 }
 
+func (m *O3Mini) ContextWindow() int   { return 200_000 }
+func (m *O3Mini) MaxOutputTokens() int { return 100_000 }
+func (m *O3Mini) Pricing() assistant.Pricing {
+	return assistant.Pricing{Input: 1.1, Output: 4.4, CacheRead: 0.55}
+}
+
 func (m *O3Mini) SetSystemInstruction(contents ...*assistant.TextContent) {
 	m.systemInstruction = contents
 }
 
+func (m *O3Mini) SetGenerationParams(p assistant.GenerationParams) {
+	m.params = p
+}
+
 func (m *O3Mini) SetHttpClient(c *http.Client) {
 	m.client.SetHTTPClient(c)
 }
 
 func (m *O3Mini) GenerateContent(ctx context.Context, msgs ...assistant.Message) (*assistant.GenerateContentResponse, error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	// Send request
-	resp := new(ChatResponse)
-	if err := m.client.DoPost(ctx, endpoint, req, resp); err != nil {
-		return nil, err
-	}
-	return ToGenerateContentResponse(resp), nil
+	return GenerateContent(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }
 
 func (m *O3Mini) GenerateContentStream(ctx context.Context, msgs ...assistant.Message) (iter.Seq2[*assistant.GenerateContentResponse, error], error) {
-	req, err := BuildChatRequest(ctx, m.Name(), m.systemInstruction, msgs)
-	if err != nil {
-		return nil, fmt.Errorf("build chat request: %w", err)
-	}
-	// Send request
-	req.Stream = true
-	iter, err := m.client.DoStream(ctx, endpoint, req)
-	if err != nil {
-		return nil, err
-	}
-	// return converter iter
-	return func(yield func(*assistant.GenerateContentResponse, error) bool) {
-		for s := range iter {
-			var res *ChatResponse
-			err := json.Unmarshal([]byte(s), &res)
-			if err != nil {
-				logging.LoggerFrom(ctx).Error(fmt.Sprintf("unmarshal error: %v", err))
-				yield(nil, fmt.Errorf("failed to unmarshal stream response: %w", err))
-				continue
-			}
-			if len(res.Choices) == 0 || res.Choices[0].Delta == nil {
-				continue
-			}
-			delta := assistant.NewTextContent(res.Choices[0].Delta.Content)
-			if !yield(&assistant.GenerateContentResponse{Content: delta}, nil) {
-				break
-			}
-		}
-	}, nil
+	return GenerateContentStream(ctx, m.client, m.Name(), m.systemInstruction, m.params, msgs)
 }